@@ -0,0 +1,46 @@
+package authz
+
+import "context"
+
+// Actor is the JWT "act" claim (RFC 8693). It is set on impersonation tokens
+// and identifies the admin acting on behalf of the token subject.
+type Actor struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the impersonating actor, if any.
+func WithActor(ctx context.Context, actor *Actor) context.Context {
+	if actor == nil || actor.Subject == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the impersonating actor stored by WithActor.
+func ActorFromContext(ctx context.Context) *Actor {
+	if ctx == nil {
+		return nil
+	}
+	actor, _ := ctx.Value(actorKey{}).(*Actor)
+	return actor
+}
+
+// ActorMetadata adds the impersonating actor to event metadata so consumers
+// can attribute the change to both identities.
+func ActorMetadata(ctx context.Context, metadata map[string]any) map[string]any {
+	actor := ActorFromContext(ctx)
+	if actor == nil {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["impersonator_id"] = actor.Subject
+	if actor.Email != "" {
+		metadata["impersonator_email"] = actor.Email
+	}
+	return metadata
+}
@@ -0,0 +1,158 @@
+package authz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidAPIKey means the auth service does not accept the key: it is
+	// unknown, revoked or expired, or its service account is disabled.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrAPIKeyUnverified means the auth service could not be asked.
+	ErrAPIKeyUnverified = errors.New("api key could not be verified")
+)
+
+// ServiceIdentity is the service account behind an API key.
+type ServiceIdentity struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope reports whether the key was issued with every required scope.
+func (s *ServiceIdentity) HasScope(required ...string) bool {
+	for _, want := range required {
+		found := false
+		for _, have := range s.Scopes {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// APIKeyVerifier asks the auth service which service account an API key
+// belongs to, so services other than auth can authenticate internal callers.
+// Answers are cached for sessionCacheTTL, so a revoked key may be accepted
+// for that long.
+type APIKeyVerifier struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]apiKeyEntry
+}
+
+type apiKeyEntry struct {
+	identity *ServiceIdentity
+	err      error
+	expires  time.Time
+}
+
+// NewAPIKeyVerifier checks keys against the auth service at authURL.
+func NewAPIKeyVerifier(authURL string) *APIKeyVerifier {
+	return &APIKeyVerifier{
+		url:    strings.TrimRight(authURL, "/") + "/auth/internal/service-identity",
+		client: &http.Client{Timeout: 5 * time.Second},
+		ttl:    sessionCacheTTL,
+		now:    time.Now,
+		cache:  map[string]apiKeyEntry{},
+	}
+}
+
+// NewAPIKeyVerifierFromEnv checks keys against AUTH_SERVICE_URL, defaulting
+// to the auth service's compose hostname.
+func NewAPIKeyVerifierFromEnv() *APIKeyVerifier {
+	url := strings.TrimSpace(os.Getenv("AUTH_SERVICE_URL"))
+	if url == "" {
+		url = "http://auth-service:8081"
+	}
+	return NewAPIKeyVerifier(url)
+}
+
+// Verify returns the service account behind key, ErrInvalidAPIKey when the
+// auth service rejects it, and ErrAPIKeyUnverified when the auth service
+// cannot be reached.
+func (v *APIKeyVerifier) Verify(ctx context.Context, key string) (*ServiceIdentity, error) {
+	if key == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+
+	v.mu.Lock()
+	entry, ok := v.cache[hash]
+	if ok && !v.now().Before(entry.expires) {
+		delete(v.cache, hash)
+		ok = false
+	}
+	v.mu.Unlock()
+	if ok {
+		return entry.identity, entry.err
+	}
+
+	identity, err := v.check(ctx, key)
+	if errors.Is(err, ErrAPIKeyUnverified) {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	v.sweep()
+	v.cache[hash] = apiKeyEntry{identity: identity, err: err, expires: v.now().Add(v.ttl)}
+	v.mu.Unlock()
+	return identity, err
+}
+
+// sweep drops expired answers so keys seen once do not stay cached. The
+// caller holds mu.
+func (v *APIKeyVerifier) sweep() {
+	now := v.now()
+	for hash, entry := range v.cache {
+		if !now.Before(entry.expires) {
+			delete(v.cache, hash)
+		}
+	}
+}
+
+func (v *APIKeyVerifier) check(ctx context.Context, key string) (*ServiceIdentity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAPIKeyUnverified, err)
+	}
+	req.Header.Set("X-API-Key", key)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAPIKeyUnverified, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		var identity ServiceIdentity
+		if err := json.NewDecoder(resp.Body).Decode(&identity); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAPIKeyUnverified, err)
+		}
+		return &identity, nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, ErrInvalidAPIKey
+	default:
+		return nil, fmt.Errorf("%w: auth service responded %s", ErrAPIKeyUnverified, resp.Status)
+	}
+}
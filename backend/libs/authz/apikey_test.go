@@ -0,0 +1,63 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyVerifier(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/auth/internal/service-identity" || r.Header.Get("X-API-Key") != "key-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"name": "booking-service", "scopes": ["notifications:send"]}`))
+	}))
+	t.Cleanup(srv.Close)
+	v := NewAPIKeyVerifier(srv.URL)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		identity, err := v.Verify(ctx, "key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.Name != "booking-service" || !identity.HasScope(ScopeNotificationsSend) || identity.HasScope(ScopeUsersRead) {
+			t.Fatalf("unexpected identity %+v", identity)
+		}
+	}
+	if _, err := v.Verify(ctx, "key-2"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("unknown key: got %v, want ErrInvalidAPIKey", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("auth service called %d times, want 2", calls.Load())
+	}
+
+	// Expired answers are dropped, not just ignored.
+	now = now.Add(sessionCacheTTL)
+	if _, err := v.Verify(ctx, "key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.cache) != 1 || calls.Load() != 3 {
+		t.Fatalf("cache holds %d entries after %d calls, want 1 after 3", len(v.cache), calls.Load())
+	}
+}
+
+func TestAPIKeyVerifierUnreachable(t *testing.T) {
+	v := NewAPIKeyVerifier("http://127.0.0.1:1")
+	if _, err := v.Verify(context.Background(), "key-1"); !errors.Is(err, ErrAPIKeyUnverified) {
+		t.Fatalf("got %v, want ErrAPIKeyUnverified", err)
+	}
+	if len(v.cache) != 0 {
+		t.Fatal("a failed lookup was cached")
+	}
+}
@@ -0,0 +1,123 @@
+package authz
+
+import "strings"
+
+// Built-in role names. Roles are stored as data in the auth service; these are
+// only the ones seeded on startup and used as a fallback for legacy tokens.
+const (
+	RoleUser  = "USER"
+	RoleAdmin = "ADMIN"
+)
+
+// Named permissions carried in the JWT "permissions" claim.
+const (
+	PermRoomRead        = "room:read"
+	PermRoomWrite       = "room:write"
+	PermBookingCreate   = "booking:create"
+	PermBookingManage   = "booking:manage"
+	PermBookingApprove  = "booking:approve"
+	PermAuditRead       = "audit:read"
+	PermUserRead        = "user:read"
+	PermUserManage      = "user:manage"
+	PermRoleManage      = "role:manage"
+	PermUserImpersonate = "user:impersonate"
+
+	PermServiceAccountManage = "service_account:manage"
+	PermGroupManage          = "group:manage"
+)
+
+// Scopes granted to service account API keys for service-to-service calls.
+const (
+	ScopeUsersRead         = "users:read"
+	ScopeUsersWrite        = "users:write"
+	ScopeNotificationsSend = "notifications:send"
+	ScopeGroupsRead        = "groups:read"
+)
+
+// AllScopes lists every scope an API key may be issued with.
+var AllScopes = []string{
+	ScopeUsersRead,
+	ScopeUsersWrite,
+	ScopeNotificationsSend,
+	ScopeGroupsRead,
+}
+
+// AllPermissions lists every permission known to the platform.
+var AllPermissions = []string{
+	PermRoomRead,
+	PermRoomWrite,
+	PermBookingCreate,
+	PermBookingManage,
+	PermBookingApprove,
+	PermAuditRead,
+	PermUserRead,
+	PermUserManage,
+	PermRoleManage,
+	PermUserImpersonate,
+	PermServiceAccountManage,
+	PermGroupManage,
+}
+
+// DefaultRolePermissions is the permission set seeded for the built-in roles.
+var DefaultRolePermissions = map[string][]string{
+	RoleUser: {
+		PermRoomRead,
+		PermBookingCreate,
+	},
+	RoleAdmin: AllPermissions,
+}
+
+// NormalizeRole canonicalises a role name so "admin" and "ADMIN" compare equal.
+func NormalizeRole(role string) string {
+	return strings.ToUpper(strings.TrimSpace(role))
+}
+
+// IsKnownPermission reports whether perm is one of AllPermissions.
+func IsKnownPermission(perm string) bool {
+	for _, p := range AllPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKnownScope reports whether scope is one of AllScopes.
+func IsKnownScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectivePermissions returns the permissions granted by a token. Tokens issued
+// before permissions were added only carry a role, so a nil list falls back to
+// the defaults for that role. An empty, non-nil list means every permission was
+// removed and is honoured as such.
+func EffectivePermissions(role string, permissions []string) []string {
+	if permissions != nil {
+		return permissions
+	}
+	return DefaultRolePermissions[NormalizeRole(role)]
+}
+
+// HasPermission reports whether the role/permission pair from a token grants
+// every one of the required permissions.
+func HasPermission(role string, permissions []string, required ...string) bool {
+	granted := EffectivePermissions(role, permissions)
+	for _, want := range required {
+		found := false
+		for _, have := range granted {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
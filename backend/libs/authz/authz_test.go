@@ -0,0 +1,52 @@
+package authz
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        string
+		permissions []string
+		want        []string
+	}{
+		{"legacy token falls back to role defaults", RoleUser, nil, DefaultRolePermissions[RoleUser]},
+		{"explicit permissions win", RoleUser, []string{PermAuditRead}, []string{PermAuditRead}},
+		{"explicitly empty list grants nothing", RoleAdmin, []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EffectivePermissions(tt.role, tt.permissions)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHasPermissionHonoursEmptyClaim(t *testing.T) {
+	// Services decode the claim from JSON, so check the distinction survives it.
+	var stripped, legacy struct {
+		Permissions []string `json:"permissions,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(`{"permissions":[]}`), &stripped); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{}`), &legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	if HasPermission(RoleUser, stripped.Permissions, PermBookingCreate) {
+		t.Error("token with an empty permissions claim must not get role defaults")
+	}
+	if !HasPermission(RoleUser, legacy.Permissions, PermBookingCreate) {
+		t.Error("legacy token without a permissions claim should get role defaults")
+	}
+}
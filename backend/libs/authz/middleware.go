@@ -0,0 +1,83 @@
+package authz
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/gofiber/fiber/v2"
+)
+
+// sessions is the process's SessionValidator, shared by SessionCheck,
+// CheckSession and RevokeSessions so revocations clear the cache requests
+// are served from.
+var sessions = sync.OnceValue(NewSessionValidatorFromEnv)
+
+// CheckSession asks the auth service whether token, issued to userID, is
+// still honoured. It returns a 401 fiber.Error when the session was revoked
+// and a 503 when it could not be verified; service names the caller in logs.
+func CheckSession(ctx context.Context, service, token, userID string) error {
+	err := sessions().Validate(ctx, token, userID)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrSessionRevoked):
+		return fiber.NewError(fiber.StatusUnauthorized, "session revoked")
+	default:
+		log.Printf("%s: %v", service, err)
+		return fiber.NewError(fiber.StatusServiceUnavailable, "session could not be verified")
+	}
+}
+
+// SessionCheck rejects revoked bearer tokens on every route of service,
+// including those that otherwise rely on the gateway's JWT check alone. The
+// signature is left to the auth service, which refuses forged tokens too.
+func SessionCheck(service string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := strings.TrimSpace(c.Get(fiber.HeaderAuthorization))
+		if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+			return c.Next()
+		}
+		token := strings.TrimSpace(header[7:])
+		if err := CheckSession(c.UserContext(), service, token, tokenSubject(token)); err != nil {
+			var fe *fiber.Error
+			errors.As(err, &fe)
+			return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+		}
+		return c.Next()
+	}
+}
+
+// RevokeSessions applies a revocation announced by the auth service, so the
+// user's next request is checked again rather than served from cache. It is
+// the handler services pass to events.ConsumeSessionEvents.
+func RevokeSessions(_ context.Context, evt events.SessionRevokedEvent) error {
+	sessions().Revoke(evt.UserID)
+	return nil
+}
+
+// tokenSubject reads the sub claim of a JWT without verifying it. It only
+// files the cached answer under a user for RevokeSessions; the auth service
+// decides whether the token is valid.
+func tokenSubject(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.Subject
+}
@@ -0,0 +1,144 @@
+package authz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSessionRevoked means the auth service no longer honours the token:
+	// the user was deactivated or erased, or their tokens were revoked.
+	ErrSessionRevoked = errors.New("session revoked")
+	// ErrSessionUnverified means the auth service could not be asked.
+	ErrSessionUnverified = errors.New("session could not be verified")
+)
+
+// sessionCacheTTL bounds how long an answer from the auth service is reused.
+// Revocation events clear entries sooner; the TTL only matters if one is lost.
+const sessionCacheTTL = 30 * time.Second
+
+// SessionValidator asks the auth service whether a token it issued is still
+// honoured. Services verify the JWT signature locally, but only the auth
+// service knows about deactivation and revocation, so every authenticated
+// request also goes through Validate.
+type SessionValidator struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]sessionEntry
+}
+
+type sessionEntry struct {
+	userID  string
+	err     error
+	expires time.Time
+}
+
+// NewSessionValidator checks sessions against the auth service at authURL.
+func NewSessionValidator(authURL string) *SessionValidator {
+	return &SessionValidator{
+		url:    strings.TrimRight(authURL, "/") + "/auth/session",
+		client: &http.Client{Timeout: 5 * time.Second},
+		ttl:    sessionCacheTTL,
+		now:    time.Now,
+		cache:  map[string]sessionEntry{},
+	}
+}
+
+// NewSessionValidatorFromEnv checks sessions against AUTH_SERVICE_URL,
+// defaulting to the auth service's compose hostname.
+func NewSessionValidatorFromEnv() *SessionValidator {
+	url := strings.TrimSpace(os.Getenv("AUTH_SERVICE_URL"))
+	if url == "" {
+		url = "http://auth-service:8081"
+	}
+	return NewSessionValidator(url)
+}
+
+// Validate returns nil when the auth service still honours token, which
+// belongs to userID, ErrSessionRevoked when it does not, and
+// ErrSessionUnverified when the auth service cannot be reached.
+func (v *SessionValidator) Validate(ctx context.Context, token, userID string) error {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	v.mu.Lock()
+	entry, ok := v.cache[key]
+	if ok && !v.now().Before(entry.expires) {
+		delete(v.cache, key)
+		ok = false
+	}
+	v.mu.Unlock()
+	if ok {
+		return entry.err
+	}
+
+	err := v.check(ctx, token)
+	if errors.Is(err, ErrSessionUnverified) {
+		return err
+	}
+
+	v.mu.Lock()
+	v.sweep()
+	v.cache[key] = sessionEntry{userID: userID, err: err, expires: v.now().Add(v.ttl)}
+	v.mu.Unlock()
+	return err
+}
+
+// sweep drops expired answers so tokens seen once do not stay cached. The
+// caller holds mu.
+func (v *SessionValidator) sweep() {
+	now := v.now()
+	for key, entry := range v.cache {
+		if !now.Before(entry.expires) {
+			delete(v.cache, key)
+		}
+	}
+}
+
+// Revoke drops every cached answer for userID so their next request is
+// checked with the auth service again.
+func (v *SessionValidator) Revoke(userID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key, entry := range v.cache {
+		if entry.userID == userID {
+			delete(v.cache, key)
+		}
+	}
+	v.sweep()
+}
+
+func (v *SessionValidator) check(ctx context.Context, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionUnverified, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionUnverified, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrSessionRevoked
+	default:
+		return fmt.Errorf("%w: auth service responded %s", ErrSessionUnverified, resp.Status)
+	}
+}
@@ -0,0 +1,110 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestValidator(t *testing.T, status *atomic.Int32, calls *atomic.Int32) *SessionValidator {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/auth/session" || r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(srv.Close)
+	return NewSessionValidator(srv.URL + "/")
+}
+
+func TestSessionValidatorCachesAndRevokes(t *testing.T) {
+	var status, calls atomic.Int32
+	status.Store(http.StatusNoContent)
+	v := newTestValidator(t, &status, &calls)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := v.Validate(ctx, "token-1", "user-1"); err != nil {
+			t.Fatalf("Validate: %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("auth service called %d times, want 1", calls.Load())
+	}
+
+	// A revocation event must take effect without waiting for the TTL.
+	status.Store(http.StatusUnauthorized)
+	v.Revoke("user-1")
+	if err := v.Validate(ctx, "token-1", "user-1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("after revoke got %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestSessionValidatorExpiresCache(t *testing.T) {
+	var status, calls atomic.Int32
+	status.Store(http.StatusNoContent)
+	v := newTestValidator(t, &status, &calls)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	if err := v.Validate(context.Background(), "token-1", "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	status.Store(http.StatusUnauthorized)
+	now = now.Add(sessionCacheTTL + time.Second)
+	if err := v.Validate(context.Background(), "token-1", "user-1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("got %v, want ErrSessionRevoked once the cache expired", err)
+	}
+}
+
+func TestSessionValidatorUnreachable(t *testing.T) {
+	var status, calls atomic.Int32
+	status.Store(http.StatusBadGateway)
+	v := newTestValidator(t, &status, &calls)
+
+	for i := 0; i < 2; i++ {
+		if err := v.Validate(context.Background(), "token-1", "user-1"); !errors.Is(err, ErrSessionUnverified) {
+			t.Fatalf("got %v, want ErrSessionUnverified", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("failed checks must not be cached; auth called %d times", calls.Load())
+	}
+}
+
+func TestSessionValidatorDropsExpiredEntries(t *testing.T) {
+	var status, calls atomic.Int32
+	status.Store(http.StatusNoContent)
+	v := newTestValidator(t, &status, &calls)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	if err := v.Validate(context.Background(), "token-1", "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(sessionCacheTTL)
+	v.Revoke("user-2")
+	if len(v.cache) != 0 {
+		t.Fatalf("cache holds %d entries after they expired, want 0", len(v.cache))
+	}
+}
+
+func TestTokenSubject(t *testing.T) {
+	// {"alg":"HS256","typ":"JWT"} . {"sub":"user-1"} . signature
+	token := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiJ1c2VyLTEifQ.c2ln"
+	if got := tokenSubject(token); got != "user-1" {
+		t.Errorf("tokenSubject = %q, want user-1", got)
+	}
+	for _, bad := range []string{"", "not-a-jwt", "a.!!!.c"} {
+		if got := tokenSubject(bad); got != "" {
+			t.Errorf("tokenSubject(%q) = %q, want empty", bad, got)
+		}
+	}
+}
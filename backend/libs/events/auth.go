@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AuthEventsExchange is a fanout exchange carrying every auth security event.
+// Forwarders (e.g. to a SIEM) bind their own queue to it.
+const AuthEventsExchange = "auth.events"
+
+// AuthEvent is one entry of the auth service's security audit log.
+type AuthEvent struct {
+	ID           string         `json:"id"`
+	Action       string         `json:"action"`
+	Outcome      string         `json:"outcome"`
+	ActorID      string         `json:"actor_id,omitempty"`
+	TargetUserID string         `json:"target_user_id,omitempty"`
+	IP           string         `json:"ip,omitempty"`
+	UserAgent    string         `json:"user_agent,omitempty"`
+	Details      map[string]any `json:"details,omitempty"`
+	Occurred     time.Time      `json:"occurred_at"`
+}
+
+func (p *RabbitPublisher) PublishAuthEvent(ctx context.Context, evt AuthEvent) error {
+	if p == nil || p.closed {
+		return fmt.Errorf("publisher closed")
+	}
+
+	if evt.Occurred.IsZero() {
+		evt.Occurred = time.Now().UTC()
+	}
+
+	if err := p.ch.ExchangeDeclare(AuthEventsExchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare exchange %q: %w", AuthEventsExchange, err)
+	}
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	return p.ch.PublishWithContext(
+		ctx,
+		AuthEventsExchange,
+		"",
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	)
+}
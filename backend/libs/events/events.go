@@ -21,6 +21,11 @@ const (
 	BookingApprovedEvent    = "booking.approved"
 	BookingDeniedEvent      = "booking.denied"
 	BookingTransferredEvent = "booking.transferred"
+	BookingRelocatedEvent   = "booking.relocated"
+	BookingCheckedInEvent   = "booking.checked_in"
+	// BookingWalkUpCodeEvent carries a one-time code for a walk-up booking
+	// at a kiosk. It has no booking ID yet.
+	BookingWalkUpCodeEvent = "booking.walk_up_code"
 )
 
 // BookingEvent captures changes in the booking lifecycle that downstream services can react to.
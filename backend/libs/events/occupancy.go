@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RoomOccupancyExchange is a fanout exchange carrying occupancy changes
+// reported by room sensors. It is separate from RoomEventsExchange so room
+// read models do not receive sensor traffic.
+const RoomOccupancyExchange = "room.occupancy"
+
+const RoomOccupancyChangedEvent = "room.occupancy_changed"
+
+// RoomOccupancyQueue is the queue a service consumes occupancy events from.
+func RoomOccupancyQueue(service string) string {
+	return "room_occupancy." + service
+}
+
+// RoomOccupancyEvent reports that a room became occupied or vacant, for
+// example so a check-in process can release a booking nobody showed up for.
+type RoomOccupancyEvent struct {
+	Event       string    `json:"event"`
+	RoomID      string    `json:"room_id"`
+	SensorID    string    `json:"sensor_id"`
+	Occupied    bool      `json:"occupied"`
+	PeopleCount *int      `json:"people_count,omitempty"`
+	RecordedAt  time.Time `json:"recorded_at"`
+	Occurred    time.Time `json:"occurred_at"`
+}
+
+func (p *RabbitPublisher) PublishRoomOccupancyEvent(ctx context.Context, evt RoomOccupancyEvent) error {
+	if p == nil || p.closed {
+		return fmt.Errorf("publisher closed")
+	}
+
+	if evt.Event == "" {
+		evt.Event = RoomOccupancyChangedEvent
+	}
+	if evt.Occurred.IsZero() {
+		evt.Occurred = time.Now().UTC()
+	}
+	return p.publishFanout(ctx, RoomOccupancyExchange, evt)
+}
+
+// ConsumeRoomOccupancyEvents binds RoomOccupancyQueue(service) to
+// RoomOccupancyExchange and passes every event to handle until ctx is done or
+// the connection drops.
+func ConsumeRoomOccupancyEvents(ctx context.Context, url, service string, handle func(ctx context.Context, evt RoomOccupancyEvent) error) error {
+	return consumeJSON(ctx, url, RoomOccupancyQueue(service), RoomOccupancyExchange, func(body []byte) error {
+		var evt RoomOccupancyEvent
+		if err := json.Unmarshal(body, &evt); err != nil {
+			return fmt.Errorf("decode occupancy event: %w", err)
+		}
+		return handle(ctx, evt)
+	})
+}
@@ -0,0 +1,209 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Data subject request types.
+const (
+	PrivacyExport  = "export"
+	PrivacyErasure = "erasure"
+)
+
+// PrivacyResultsQueue is where services report back to the auth service.
+const PrivacyResultsQueue = "privacy_results"
+
+// PrivacyRequestQueue is the queue a service consumes data subject requests from.
+func PrivacyRequestQueue(service string) string {
+	return "privacy_requests." + service
+}
+
+// PrivacyRequest asks a service to export or erase everything it stores about a user.
+type PrivacyRequest struct {
+	RequestID string    `json:"request_id"`
+	Type      string    `json:"type"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email,omitempty"`
+	Occurred  time.Time `json:"occurred_at"`
+}
+
+// PrivacyResult is a service's answer to a PrivacyRequest. Data holds the
+// exported records for export requests.
+type PrivacyResult struct {
+	RequestID string         `json:"request_id"`
+	Service   string         `json:"service"`
+	Type      string         `json:"type"`
+	Success   bool           `json:"success"`
+	Error     string         `json:"error,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+	Occurred  time.Time      `json:"occurred_at"`
+}
+
+// PrivacyHandler is implemented by every service that stores personal data.
+type PrivacyHandler interface {
+	ExportUserData(ctx context.Context, req PrivacyRequest) (map[string]any, error)
+	EraseUserData(ctx context.Context, req PrivacyRequest) error
+}
+
+func (p *RabbitPublisher) PublishPrivacyRequest(ctx context.Context, service string, req PrivacyRequest) error {
+	if req.Occurred.IsZero() {
+		req.Occurred = time.Now().UTC()
+	}
+	return publishJSON(ctx, p, PrivacyRequestQueue(service), req)
+}
+
+func (p *RabbitPublisher) PublishPrivacyResult(ctx context.Context, res PrivacyResult) error {
+	if res.Occurred.IsZero() {
+		res.Occurred = time.Now().UTC()
+	}
+	return publishJSON(ctx, p, PrivacyResultsQueue, res)
+}
+
+func publishJSON(ctx context.Context, p *RabbitPublisher, queue string, payload any) error {
+	if p == nil || p.closed {
+		return fmt.Errorf("publisher closed")
+	}
+
+	if _, err := p.ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare queue %q: %w", queue, err)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	return p.ch.PublishWithContext(
+		ctx,
+		"",
+		queue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	)
+}
+
+// RunPrivacyWorker consumes data subject requests for service and reports the
+// outcome on PrivacyResultsQueue. It blocks until ctx is done or the
+// connection drops.
+func RunPrivacyWorker(ctx context.Context, url, service string, handler PrivacyHandler) error {
+	publisher, err := NewRabbitPublisher(url, WithQueueName(PrivacyResultsQueue))
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	return consumeJSON(ctx, url, PrivacyRequestQueue(service), "", func(body []byte) error {
+		var req PrivacyRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("decode privacy request: %w", err)
+		}
+
+		res := PrivacyResult{RequestID: req.RequestID, Service: service, Type: req.Type}
+		switch req.Type {
+		case PrivacyExport:
+			res.Data, err = handler.ExportUserData(ctx, req)
+		case PrivacyErasure:
+			err = handler.EraseUserData(ctx, req)
+		default:
+			err = fmt.Errorf("unknown privacy request type %q", req.Type)
+		}
+		res.Success = err == nil
+		if err != nil {
+			res.Error = err.Error()
+			log.Printf("%s: privacy request %s failed: %v", service, req.RequestID, err)
+		}
+
+		return publisher.PublishPrivacyResult(ctx, res)
+	})
+}
+
+// ConsumePrivacyResults passes every PrivacyResult to handle until ctx is done
+// or the connection drops.
+func ConsumePrivacyResults(ctx context.Context, url string, handle func(ctx context.Context, res PrivacyResult) error) error {
+	return consumeJSON(ctx, url, PrivacyResultsQueue, "", func(body []byte) error {
+		var res PrivacyResult
+		if err := json.Unmarshal(body, &res); err != nil {
+			return fmt.Errorf("decode privacy result: %w", err)
+		}
+		return handle(ctx, res)
+	})
+}
+
+// consumeJSON consumes queue, first binding it to the fanout exchange when
+// exchange is not empty.
+func consumeJSON(ctx context.Context, url, queue, exchange string, handle func(body []byte) error) error {
+	if url == "" {
+		url = defaultRabbitURL
+	}
+
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return fmt.Errorf("connect rabbitmq: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("open channel: %w", err)
+	}
+	defer ch.Close()
+
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare queue %q: %w", queue, err)
+	}
+	if exchange != "" {
+		if err := ch.ExchangeDeclare(exchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("declare exchange %q: %w", exchange, err)
+		}
+		if err := ch.QueueBind(queue, "", exchange, false, nil); err != nil {
+			return fmt.Errorf("bind queue %q to %q: %w", queue, exchange, err)
+		}
+	}
+
+	deliveries, err := ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume %q: %w", queue, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("consumer for %q closed", queue)
+			}
+			if err := handle(msg.Body); err != nil {
+				log.Printf("%s: %v", queue, err)
+			}
+			if err := msg.Ack(false); err != nil {
+				log.Printf("%s: failed to ack message: %v", queue, err)
+			}
+		}
+	}
+}
+
+// RunUntilDone keeps a consumer such as RunPrivacyWorker running, reconnecting
+// after a short delay whenever it returns, until ctx is done.
+func RunUntilDone(ctx context.Context, name string, run func(ctx context.Context) error) {
+	for ctx.Err() == nil {
+		if err := run(ctx); err != nil {
+			log.Printf("%s stopped: %v; restarting in 5s", name, err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RoomEventsExchange is a fanout exchange carrying room lifecycle events.
+// Services that keep a local copy of room data bind their own queue to it.
+const RoomEventsExchange = "room.events"
+
+const (
+	RoomCreatedEvent  = "room.created"
+	RoomUpdatedEvent  = "room.updated"
+	RoomArchivedEvent = "room.archived"
+	RoomRestoredEvent = "room.restored"
+)
+
+// RoomEventsQueue is the queue a service consumes room events from.
+func RoomEventsQueue(service string) string {
+	return "room_events." + service
+}
+
+// RoomSnapshot is the state of a room after the change an event reports.
+type RoomSnapshot struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Capacity   int        `json:"capacity"`
+	Features   []string   `json:"features"`
+	Building   string     `json:"building"`
+	Floor      int        `json:"floor"`
+	RoomNumber string     `json:"room_number"`
+	Accessible bool       `json:"accessible"`
+	TimeZone   string     `json:"time_zone"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// RoomEvent carries the full room so consumers never need to read the rooms
+// table to apply it.
+type RoomEvent struct {
+	Event    string       `json:"event"`
+	Room     RoomSnapshot `json:"room"`
+	Occurred time.Time    `json:"occurred_at"`
+}
+
+func (p *RabbitPublisher) PublishRoomEvent(ctx context.Context, evt RoomEvent) error {
+	if p == nil || p.closed {
+		return fmt.Errorf("publisher closed")
+	}
+
+	if evt.Occurred.IsZero() {
+		evt.Occurred = time.Now().UTC()
+	}
+	return p.publishFanout(ctx, RoomEventsExchange, evt)
+}
+
+// publishFanout declares a durable fanout exchange and publishes evt to it as
+// JSON.
+func (p *RabbitPublisher) publishFanout(ctx context.Context, exchange string, evt any) error {
+	if err := p.ch.ExchangeDeclare(exchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare exchange %q: %w", exchange, err)
+	}
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	return p.ch.PublishWithContext(
+		ctx,
+		exchange,
+		"",
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	)
+}
+
+// ConsumeRoomEvents binds RoomEventsQueue(service) to RoomEventsExchange and
+// passes every event to handle until ctx is done or the connection drops.
+func ConsumeRoomEvents(ctx context.Context, url, service string, handle func(ctx context.Context, evt RoomEvent) error) error {
+	return consumeJSON(ctx, url, RoomEventsQueue(service), RoomEventsExchange, func(body []byte) error {
+		var evt RoomEvent
+		if err := json.Unmarshal(body, &evt); err != nil {
+			return fmt.Errorf("decode room event: %w", err)
+		}
+		return handle(ctx, evt)
+	})
+}
+
+// RoomDirectory is an in-memory read model of rooms kept current by room
+// events. Rooms it has not seen yet are reported missing, so callers fall back
+// to their own lookup and Put the result.
+type RoomDirectory struct {
+	mu    sync.RWMutex
+	rooms map[string]RoomSnapshot
+}
+
+func NewRoomDirectory() *RoomDirectory {
+	return &RoomDirectory{rooms: map[string]RoomSnapshot{}}
+}
+
+// Apply records the room state carried by evt. Archived rooms are kept so
+// bookings made before archival still resolve their name.
+func (d *RoomDirectory) Apply(_ context.Context, evt RoomEvent) error {
+	if evt.Room.ID == "" {
+		return fmt.Errorf("room event %q without room id", evt.Event)
+	}
+	d.Put(evt.Room)
+	return nil
+}
+
+func (d *RoomDirectory) Put(room RoomSnapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rooms[room.ID] = room
+}
+
+func (d *RoomDirectory) Get(id string) (RoomSnapshot, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	room, ok := d.rooms[id]
+	return room, ok
+}
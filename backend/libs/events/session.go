@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SessionEventsExchange is a fanout exchange announcing that a user's tokens
+// stopped being valid before they expire. Services that cache session checks
+// bind their own queue to it and drop the user's cached entries.
+const SessionEventsExchange = "auth.sessions"
+
+// Reasons carried by SessionRevokedEvent.
+const (
+	SessionRevokedDeactivated   = "deactivated"
+	SessionRevokedRoleChanged   = "role_changed"
+	SessionRevokedErased        = "erased"
+	SessionRevokedImpersonation = "impersonation_ended"
+)
+
+// SessionEventsQueue is the queue a service consumes session events from.
+func SessionEventsQueue(service string) string {
+	return "session_events." + service
+}
+
+// SessionRevokedEvent reports that tokens issued to UserID before RevokedAt
+// are no longer honoured.
+type SessionRevokedEvent struct {
+	UserID    string    `json:"user_id"`
+	Reason    string    `json:"reason"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+func (p *RabbitPublisher) PublishSessionRevoked(ctx context.Context, evt SessionRevokedEvent) error {
+	if p == nil || p.closed {
+		return fmt.Errorf("publisher closed")
+	}
+
+	if evt.RevokedAt.IsZero() {
+		evt.RevokedAt = time.Now().UTC()
+	}
+	return p.publishFanout(ctx, SessionEventsExchange, evt)
+}
+
+// ConsumeSessionEvents binds SessionEventsQueue(service) to
+// SessionEventsExchange and passes every event to handle until ctx is done or
+// the connection drops.
+func ConsumeSessionEvents(ctx context.Context, url, service string, handle func(ctx context.Context, evt SessionRevokedEvent) error) error {
+	return consumeJSON(ctx, url, SessionEventsQueue(service), SessionEventsExchange, func(body []byte) error {
+		var evt SessionRevokedEvent
+		if err := json.Unmarshal(body, &evt); err != nil {
+			return fmt.Errorf("decode session event: %w", err)
+		}
+		return handle(ctx, evt)
+	})
+}
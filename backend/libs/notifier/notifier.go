@@ -11,14 +11,14 @@ import (
 )
 
 type Client struct {
-	baseURL      string
-	channel      string
-	serviceToken string
-	httpClient   *http.Client
-	enabled      bool
+	baseURL    string
+	channel    string
+	apiKey     string
+	httpClient *http.Client
+	enabled    bool
 }
 
-func New(baseURL, channel, serviceToken string) *Client {
+func New(baseURL, channel, apiKey string) *Client {
 	baseURL = strings.TrimSpace(baseURL)
 	if baseURL == "" {
 		return &Client{enabled: false}
@@ -27,11 +27,11 @@ func New(baseURL, channel, serviceToken string) *Client {
 		channel = "email"
 	}
 	return &Client{
-		baseURL:      strings.TrimSuffix(baseURL, "/"),
-		channel:      strings.ToLower(channel),
-		serviceToken: strings.TrimSpace(serviceToken),
-		httpClient:   &http.Client{Timeout: 5 * time.Second},
-		enabled:      true,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		channel:    strings.ToLower(channel),
+		apiKey:     strings.TrimSpace(apiKey),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		enabled:    true,
 	}
 }
 
@@ -78,8 +78,8 @@ func (c *Client) post(ctx context.Context, path string, payload any) error {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if c.serviceToken != "" {
-		req.Header.Set("X-Service-Token", c.serviceToken)
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
 	}
 
 	resp, err := c.httpClient.Do(req)
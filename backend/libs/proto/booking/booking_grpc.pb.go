@@ -29,6 +29,7 @@ type BookingServiceClient interface {
 	UpdateBooking(ctx context.Context, in *UpdateBookingRequest, opts ...grpc.CallOption) (*UpdateBookingResponse, error)
 	TransferBooking(ctx context.Context, in *TransferBookingRequest, opts ...grpc.CallOption) (*TransferBookingResponse, error)
 	AdminListBookings(ctx context.Context, in *AdminListBookingsRequest, opts ...grpc.CallOption) (*AdminListBookingsResponse, error)
+	DisplaceBookings(ctx context.Context, in *DisplaceBookingsRequest, opts ...grpc.CallOption) (*DisplaceBookingsResponse, error)
 }
 
 type bookingServiceClient struct {
@@ -102,6 +103,15 @@ func (c *bookingServiceClient) AdminListBookings(ctx context.Context, in *AdminL
 	return out, nil
 }
 
+func (c *bookingServiceClient) DisplaceBookings(ctx context.Context, in *DisplaceBookingsRequest, opts ...grpc.CallOption) (*DisplaceBookingsResponse, error) {
+	out := new(DisplaceBookingsResponse)
+	err := c.cc.Invoke(ctx, "/proto.BookingService/DisplaceBookings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BookingServiceServer is the server API for BookingService service.
 // All implementations should embed UnimplementedBookingServiceServer
 // for forward compatibility
@@ -113,6 +123,7 @@ type BookingServiceServer interface {
 	UpdateBooking(context.Context, *UpdateBookingRequest) (*UpdateBookingResponse, error)
 	TransferBooking(context.Context, *TransferBookingRequest) (*TransferBookingResponse, error)
 	AdminListBookings(context.Context, *AdminListBookingsRequest) (*AdminListBookingsResponse, error)
+	DisplaceBookings(context.Context, *DisplaceBookingsRequest) (*DisplaceBookingsResponse, error)
 }
 
 // UnimplementedBookingServiceServer should be embedded to have forward compatible implementations.
@@ -140,6 +151,9 @@ func (UnimplementedBookingServiceServer) TransferBooking(context.Context, *Trans
 func (UnimplementedBookingServiceServer) AdminListBookings(context.Context, *AdminListBookingsRequest) (*AdminListBookingsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AdminListBookings not implemented")
 }
+func (UnimplementedBookingServiceServer) DisplaceBookings(context.Context, *DisplaceBookingsRequest) (*DisplaceBookingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisplaceBookings not implemented")
+}
 
 // UnsafeBookingServiceServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to BookingServiceServer will
@@ -278,6 +292,24 @@ func _BookingService_AdminListBookings_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BookingService_DisplaceBookings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisplaceBookingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookingServiceServer).DisplaceBookings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.BookingService/DisplaceBookings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookingServiceServer).DisplaceBookings(ctx, req.(*DisplaceBookingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BookingService_ServiceDesc is the grpc.ServiceDesc for BookingService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -313,6 +345,10 @@ var BookingService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AdminListBookings",
 			Handler:    _BookingService_AdminListBookings_Handler,
 		},
+		{
+			MethodName: "DisplaceBookings",
+			Handler:    _BookingService_DisplaceBookings_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/booking.proto",
@@ -0,0 +1,261 @@
+// Package schedule resolves room opening hours and closure dates into the
+// periods a room can be booked. Rules come in three scopes: a single room, a
+// whole building, or global (campus-wide). The most specific scope that
+// defines anything wins.
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DateLayout is the format of Exception.Date.
+const DateLayout = "2006-01-02"
+
+// MinutesPerDay is the Closes value meaning "until midnight".
+const MinutesPerDay = 24 * 60
+
+// Hours opens a room on one weekday. Opens and Closes are minutes since local
+// midnight. A weekday may have several Hours rows, e.g. around a lunch break.
+type Hours struct {
+	RoomID   string
+	Building string
+	Weekday  time.Weekday
+	Opens    int
+	Closes   int
+}
+
+// Exception overrides the weekly hours on one date: either closed all day or
+// open with special hours. Holidays imported from a calendar are exceptions.
+type Exception struct {
+	RoomID   string
+	Building string
+	Date     string
+	Closed   bool
+	Opens    int
+	Closes   int
+	Reason   string
+}
+
+// Period is a span of absolute time.
+type Period struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Calendar is the resolved schedule of a single room.
+type Calendar struct {
+	loc        *time.Location
+	weekly     map[time.Weekday][]Hours
+	hasWeekly  bool
+	exceptions map[string][]Exception
+}
+
+// scopeRank orders scopes from least (global) to most (room) specific.
+func scopeRank(roomID, building string) int {
+	switch {
+	case roomID != "":
+		return 2
+	case building != "":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// NewCalendar builds the calendar of a room in loc from every rule that may
+// apply to it. Callers pass room, building and global rules together; rules
+// belonging to other rooms or buildings must already be filtered out.
+func NewCalendar(loc *time.Location, hours []Hours, exceptions []Exception) *Calendar {
+	if loc == nil {
+		loc = time.UTC
+	}
+	c := &Calendar{
+		loc:        loc,
+		weekly:     map[time.Weekday][]Hours{},
+		exceptions: map[string][]Exception{},
+	}
+
+	best := -1
+	for _, h := range hours {
+		if rank := scopeRank(h.RoomID, h.Building); rank > best {
+			best = rank
+		}
+	}
+	for _, h := range hours {
+		if scopeRank(h.RoomID, h.Building) == best {
+			c.weekly[h.Weekday] = append(c.weekly[h.Weekday], h)
+			c.hasWeekly = true
+		}
+	}
+
+	bestByDate := map[string]int{}
+	for _, e := range exceptions {
+		if rank := scopeRank(e.RoomID, e.Building); rank >= bestByDate[e.Date] {
+			bestByDate[e.Date] = rank
+		}
+	}
+	for _, e := range exceptions {
+		if scopeRank(e.RoomID, e.Building) == bestByDate[e.Date] {
+			c.exceptions[e.Date] = append(c.exceptions[e.Date], e)
+		}
+	}
+
+	return c
+}
+
+// Location is the time zone the calendar's rules are expressed in.
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// OpenOn returns the open periods of the local date containing day, and the
+// reason when the room is closed all day.
+func (c *Calendar) OpenOn(day time.Time) ([]Period, string) {
+	day = day.In(c.loc)
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, c.loc)
+	at := func(minutes int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, c.loc)
+	}
+
+	if exceptions := c.exceptions[midnight.Format(DateLayout)]; len(exceptions) > 0 {
+		var open []Period
+		reason := ""
+		for _, e := range exceptions {
+			if reason == "" {
+				reason = e.Reason
+			}
+			if !e.Closed && e.Closes > e.Opens {
+				open = append(open, Period{Start: at(e.Opens), End: at(e.Closes)})
+			}
+		}
+		if reason == "" {
+			reason = "closed"
+		}
+		return mergePeriods(open), reason
+	}
+
+	if !c.hasWeekly {
+		return []Period{{Start: midnight, End: at(MinutesPerDay)}}, ""
+	}
+
+	var open []Period
+	for _, h := range c.weekly[midnight.Weekday()] {
+		if h.Closes > h.Opens {
+			open = append(open, Period{Start: at(h.Opens), End: at(h.Closes)})
+		}
+	}
+	return mergePeriods(open), "outside opening hours"
+}
+
+// ClosedPeriods returns the closed spans between from and to.
+func (c *Calendar) ClosedPeriods(from, to time.Time) []Period {
+	var closed []Period
+	cursor := from
+	for day := from.In(c.loc); day.Before(to); day = nextDay(day, c.loc) {
+		open, reason := c.OpenOn(day)
+		for _, p := range open {
+			if !p.End.After(cursor) || !p.Start.Before(to) {
+				continue
+			}
+			if p.Start.After(cursor) {
+				closed = append(closed, Period{Start: cursor, End: p.Start, Reason: reason})
+			}
+			cursor = p.End
+		}
+		dayEnd := nextDay(day, c.loc)
+		if dayEnd.After(to) {
+			dayEnd = to
+		}
+		if cursor.Before(dayEnd) {
+			closed = append(closed, Period{Start: cursor, End: dayEnd, Reason: reason})
+			cursor = dayEnd
+		}
+	}
+	return mergeClosed(closed)
+}
+
+// Check reports whether [start, end) lies entirely within opening hours. The
+// error explains the first closed span it overlaps.
+func (c *Calendar) Check(start, end time.Time) error {
+	closed := c.ClosedPeriods(start, end)
+	if len(closed) == 0 {
+		return nil
+	}
+	first := closed[0]
+	return &ClosedError{Period: first, Location: c.loc}
+}
+
+// ClosedError is returned by Check for bookings outside opening hours.
+type ClosedError struct {
+	Period   Period
+	Location *time.Location
+}
+
+func (e *ClosedError) Error() string {
+	const layout = "2006-01-02 15:04"
+	return fmt.Sprintf("room is closed from %s to %s (%s): %s",
+		e.Period.Start.In(e.Location).Format(layout),
+		e.Period.End.In(e.Location).Format(layout),
+		e.Location.String(),
+		e.Period.Reason)
+}
+
+func nextDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+}
+
+func mergePeriods(periods []Period) []Period {
+	if len(periods) < 2 {
+		return periods
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	merged := []Period{periods[0]}
+	for _, p := range periods[1:] {
+		last := &merged[len(merged)-1]
+		if !p.Start.After(last.End) {
+			if p.End.After(last.End) {
+				last.End = p.End
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// mergeClosed joins adjacent closed spans with the same reason, so a holiday
+// followed by the closed night reads as one period per reason.
+func mergeClosed(periods []Period) []Period {
+	var merged []Period
+	for _, p := range periods {
+		if n := len(merged); n > 0 && merged[n-1].End.Equal(p.Start) && merged[n-1].Reason == p.Reason {
+			merged[n-1].End = p.End
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// ParseClock parses "HH:MM" into minutes since midnight. "24:00" is accepted
+// as the end of the day.
+func ParseClock(value string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(value, "%d:%d", &h, &m); err != nil || len(value) != 5 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	minutes := h*60 + m
+	if h < 0 || m < 0 || m > 59 || minutes > MinutesPerDay {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return minutes, nil
+}
+
+// FormatClock formats minutes since midnight as "HH:MM".
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"os"
 	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/approval/config"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/approval/internal"
@@ -48,7 +50,19 @@ func main() {
 	}
 	defer publisher.Close()
 
-	service := internal.NewApprovalService(repo, publisher)
+	service := internal.NewApprovalService(repo, publisher, internal.NewRoomClient())
+
+	go events.RunUntilDone(context.Background(), "approval-service: room events consumer", func(ctx context.Context) error {
+		return events.ConsumeRoomEvents(ctx, rabbitURL, "approval", service.HandleRoomEvent)
+	})
+
+	privacy := internal.NewPrivacyHandler(repo)
+	go events.RunUntilDone(context.Background(), "approval-service: session events", func(ctx context.Context) error {
+		return events.ConsumeSessionEvents(ctx, rabbitURL, "approval", authz.RevokeSessions)
+	})
+	go events.RunUntilDone(context.Background(), "approval-service: privacy worker", func(ctx context.Context) error {
+		return events.RunPrivacyWorker(ctx, rabbitURL, "approval", privacy)
+	})
 	handler := internal.NewApprovalHandler(service)
 
 	httpPort := os.Getenv("APPROVAL_HTTP_PORT")
@@ -58,6 +72,8 @@ func main() {
 
 	go func() {
 		app := fiber.New()
+		app.Use(authz.SessionCheck("approval-service"))
+		app.Use(internal.ImpersonationContext())
 		app.Get("/approvals/pending", handler.ListPending)
 		app.Get("/approvals/approved", handler.ListApproved)
 		app.Post("/approvals/:booking_id/approve", handler.Approve)
@@ -6,6 +6,7 @@ toolchain go1.24.7
 
 require (
 	github.com/JJnvn/Software-Arch-CPRoom/backend v0.0.0
+	github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto v0.0.0
 	github.com/gofiber/fiber/v2 v2.52.9
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/uuid v1.6.0
@@ -42,3 +43,5 @@ require (
 )
 
 replace github.com/JJnvn/Software-Arch-CPRoom/backend => ../../
+
+replace github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto => ../../libs/proto
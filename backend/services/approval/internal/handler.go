@@ -3,11 +3,13 @@ package internal
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/services/approval/proto"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -24,7 +26,7 @@ func NewApprovalHandler(service *ApprovalService) *ApprovalHandler {
 }
 
 func (h *ApprovalHandler) ListPending(c *fiber.Ctx) error {
-	if _, err := requireAdminClaims(c); err != nil {
+	if _, err := requirePermission(c, authz.PermBookingApprove); err != nil {
 		return respondError(c, err)
 	}
 
@@ -36,9 +38,18 @@ func (h *ApprovalHandler) ListPending(c *fiber.Ctx) error {
 	}
 
 	// Enrich with room_name and user_name for frontend convenience
+	roomIDs := make([]string, 0, len(resp.GetPending()))
+	for _, p := range resp.GetPending() {
+		roomIDs = append(roomIDs, p.GetRoomId())
+	}
+	roomNames, err := h.service.GetRoomNames(c.Context(), roomIDs)
+	if err != nil {
+		log.Printf("failed to resolve room names: %v", err)
+	}
+
 	enriched := make([]fiber.Map, 0, len(resp.GetPending()))
 	for _, p := range resp.GetPending() {
-		roomName := fetchRoomName(p.GetRoomId())
+		roomName := strings.TrimSpace(roomNames[p.GetRoomId()])
 		userName := fetchUserName(p.GetUserId())
 		enriched = append(enriched, fiber.Map{
 			"booking_id": p.GetBookingId(),
@@ -55,7 +66,7 @@ func (h *ApprovalHandler) ListPending(c *fiber.Ctx) error {
 
 // ListApproved returns approved bookings enriched with room_name and user_name.
 func (h *ApprovalHandler) ListApproved(c *fiber.Ctx) error {
-	if _, err := requireAdminClaims(c); err != nil {
+	if _, err := requirePermission(c, authz.PermBookingApprove); err != nil {
 		return respondError(c, err)
 	}
 
@@ -70,7 +81,10 @@ func (h *ApprovalHandler) ListApproved(c *fiber.Ctx) error {
 		roomIDs = append(roomIDs, r.RoomID.String())
 		userIDs = append(userIDs, r.UserID.String())
 	}
-	roomNames, _ := h.service.GetRoomNames(roomIDs)
+	roomNames, err := h.service.GetRoomNames(c.Context(), roomIDs)
+	if err != nil {
+		log.Printf("failed to resolve room names: %v", err)
+	}
 	userNames, _ := h.service.GetUserNames(userIDs)
 
 	items := make([]fiber.Map, 0, len(rows))
@@ -88,28 +102,6 @@ func (h *ApprovalHandler) ListApproved(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"approved": items})
 }
 
-func fetchRoomName(roomID string) string {
-	if roomID == "" {
-		return ""
-	}
-	client := &http.Client{Timeout: 2 * time.Second}
-	url := fmt.Sprintf("http://room-service:8082/rooms/%s", roomID)
-	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		if resp != nil {
-			resp.Body.Close()
-		}
-		return ""
-	}
-	defer resp.Body.Close()
-	var body struct {
-		Name string `json:"name"`
-	}
-	_ = json.NewDecoder(resp.Body).Decode(&body)
-	return strings.TrimSpace(body.Name)
-}
-
 func fetchUserName(userID string) string {
 	if userID == "" {
 		return ""
@@ -117,9 +109,9 @@ func fetchUserName(userID string) string {
 	client := &http.Client{Timeout: 2 * time.Second}
 	url := fmt.Sprintf("http://auth-service:8081/auth/users/%s", userID)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	// Use service token if configured
-	if tok := strings.TrimSpace(os.Getenv("SERVICE_API_TOKEN")); tok != "" {
-		req.Header.Set("X-Service-Token", tok)
+	// Authenticate as the approval-service account
+	if key := strings.TrimSpace(os.Getenv("SERVICE_API_KEY")); key != "" {
+		req.Header.Set("X-API-Key", key)
 	}
 	resp, err := client.Do(req)
 	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -137,7 +129,7 @@ func fetchUserName(userID string) string {
 }
 
 func (h *ApprovalHandler) Approve(c *fiber.Ctx) error {
-	claims, err := requireAdminClaims(c)
+	claims, err := requirePermission(c, authz.PermBookingApprove)
 	if err != nil {
 		return respondError(c, err)
 	}
@@ -147,7 +139,7 @@ func (h *ApprovalHandler) Approve(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "token subject missing"})
 	}
 
-	resp, err := h.service.ApproveBooking(c.Context(), &pb.ApproveRequest{
+	resp, err := h.service.ApproveBooking(c.UserContext(), &pb.ApproveRequest{
 		BookingId: c.Params("booking_id"),
 		StaffId:   staffID,
 	})
@@ -158,7 +150,7 @@ func (h *ApprovalHandler) Approve(c *fiber.Ctx) error {
 }
 
 func (h *ApprovalHandler) Deny(c *fiber.Ctx) error {
-	claims, err := requireAdminClaims(c)
+	claims, err := requirePermission(c, authz.PermBookingApprove)
 	if err != nil {
 		return respondError(c, err)
 	}
@@ -176,7 +168,7 @@ func (h *ApprovalHandler) Deny(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "token subject missing"})
 	}
 
-	resp, err := h.service.DenyBooking(c.Context(), &pb.DenyRequest{
+	resp, err := h.service.DenyBooking(c.UserContext(), &pb.DenyRequest{
 		BookingId: c.Params("booking_id"),
 		StaffId:   staffID,
 		Reason:    req.Reason,
@@ -188,7 +180,7 @@ func (h *ApprovalHandler) Deny(c *fiber.Ctx) error {
 }
 
 func (h *ApprovalHandler) AuditTrail(c *fiber.Ctx) error {
-	if _, err := requireAdminClaims(c); err != nil {
+	if _, err := requirePermission(c, authz.PermAuditRead); err != nil {
 		return respondError(c, err)
 	}
 
@@ -202,22 +194,43 @@ func (h *ApprovalHandler) AuditTrail(c *fiber.Ctx) error {
 }
 
 type jwtClaims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	Email       string       `json:"email"`
+	Role        string       `json:"role"`
+	Permissions []string     `json:"permissions,omitempty"`
+	Groups      []string     `json:"groups,omitempty"`
+	Act         *authz.Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func requireAdminClaims(c *fiber.Ctx) (*jwtClaims, error) {
+func requirePermission(c *fiber.Ctx, permissions ...string) (*jwtClaims, error) {
 	claims, err := parseJWTClaims(c)
 	if err != nil {
 		return nil, err
 	}
-	if !strings.EqualFold(claims.Role, "ADMIN") {
-		return nil, fiber.NewError(fiber.StatusForbidden, "admin role required")
+	if !authz.HasPermission(claims.Role, claims.Permissions, permissions...) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "missing permission "+strings.Join(permissions, ", "))
 	}
 	return claims, nil
 }
 
+// ImpersonationContext records the "act" claim of impersonation tokens on the
+// request context so changes are attributed to both the user and the admin.
+func ImpersonationContext() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" {
+			return c.Next()
+		}
+		claims, err := parseJWTClaims(c)
+		if err != nil || claims.Act == nil {
+			return c.Next()
+		}
+
+		c.SetUserContext(authz.WithActor(c.UserContext(), claims.Act))
+		log.Printf("%s %s by %s on behalf of %s", c.Method(), c.Path(), claims.Act.Subject, claims.Subject)
+		return c.Next()
+	}
+}
+
 func parseJWTClaims(c *fiber.Ctx) (*jwtClaims, error) {
 	authHeader := strings.TrimSpace(c.Get("Authorization"))
 	if authHeader == "" {
@@ -262,6 +275,9 @@ func parseJWTClaims(c *fiber.Ctx) (*jwtClaims, error) {
 		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token issuer")
 	}
 
+	if err := authz.CheckSession(c.UserContext(), "approval-service", tokenString, claims.Subject); err != nil {
+		return nil, err
+	}
 	return claims, nil
 }
 
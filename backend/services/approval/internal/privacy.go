@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/google/uuid"
+)
+
+// PrivacyHandler answers data subject requests for approval decisions made by
+// the user.
+type PrivacyHandler struct {
+	repo *ApprovalRepository
+}
+
+func NewPrivacyHandler(repo *ApprovalRepository) *PrivacyHandler {
+	return &PrivacyHandler{repo: repo}
+}
+
+func (h *PrivacyHandler) ExportUserData(ctx context.Context, req events.PrivacyRequest) (map[string]any, error) {
+	staffID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id: %w", err)
+	}
+
+	audits, err := h.repo.ListAuditsByStaff(staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]map[string]any, 0, len(audits))
+	for _, a := range audits {
+		out = append(out, map[string]any{
+			"booking_id":      a.BookingID.String(),
+			"action":          a.Action,
+			"reason":          a.Reason,
+			"as_impersonator": a.ImpersonatorID != nil && *a.ImpersonatorID == staffID,
+			"created_at":      a.CreatedAt,
+		})
+	}
+	return map[string]any{"approval_decisions": out}, nil
+}
+
+func (h *PrivacyHandler) EraseUserData(ctx context.Context, req events.PrivacyRequest) error {
+	staffID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+	return h.repo.EraseStaffAudits(staffID)
+}
@@ -52,23 +52,6 @@ func (r *ApprovalRepository) ListApprovedBookings() ([]PendingBooking, error) {
     return rows, err
 }
 
-// GetRoomNames returns a map of roomID -> name for the provided IDs.
-func (r *ApprovalRepository) GetRoomNames(ids []string) (map[string]string, error) {
-    if len(ids) == 0 {
-        return map[string]string{}, nil
-    }
-    type row struct{ ID, Name string }
-    var rows []row
-    if err := r.db.Table("rooms").Select("id, name").Where("id IN ?", ids).Scan(&rows).Error; err != nil {
-        return nil, err
-    }
-    out := make(map[string]string, len(rows))
-    for _, r := range rows {
-        out[r.ID] = r.Name
-    }
-    return out, nil
-}
-
 // GetUserNames returns a map of userID -> name for the provided IDs.
 func (r *ApprovalRepository) GetUserNames(ids []string) (map[string]string, error) {
     if len(ids) == 0 {
@@ -86,7 +69,7 @@ func (r *ApprovalRepository) GetUserNames(ids []string) (map[string]string, erro
     return out, nil
 }
 
-func (r *ApprovalRepository) setBookingStatus(bookingID uuid.UUID, status string, staffID uuid.UUID, reason string, action string) (*models.Booking, error) {
+func (r *ApprovalRepository) setBookingStatus(bookingID uuid.UUID, status string, staffID uuid.UUID, impersonatorID *uuid.UUID, reason string, action string) (*models.Booking, error) {
 	var updated *models.Booking
 	err := r.db.Transaction(func(tx *gorm.DB) error {
 		var booking models.Booking
@@ -115,11 +98,12 @@ func (r *ApprovalRepository) setBookingStatus(bookingID uuid.UUID, status string
 		booking.UpdatedAt = now
 
 		event := &models.ApprovalAudit{
-			ID:        uuid.New(),
-			BookingID: bookingID,
-			StaffID:   staffID,
-			Action:    action,
-			Reason:    reason,
+			ID:             uuid.New(),
+			BookingID:      bookingID,
+			StaffID:        staffID,
+			ImpersonatorID: impersonatorID,
+			Action:         action,
+			Reason:         reason,
 		}
 		if err := tx.Create(event).Error; err != nil {
 			return err
@@ -131,12 +115,12 @@ func (r *ApprovalRepository) setBookingStatus(bookingID uuid.UUID, status string
 	return updated, err
 }
 
-func (r *ApprovalRepository) ApproveBooking(bookingID, staffID uuid.UUID) (*models.Booking, error) {
-	return r.setBookingStatus(bookingID, models.StatusConfirmed, staffID, "", models.AuditActionApproved)
+func (r *ApprovalRepository) ApproveBooking(bookingID, staffID uuid.UUID, impersonatorID *uuid.UUID) (*models.Booking, error) {
+	return r.setBookingStatus(bookingID, models.StatusConfirmed, staffID, impersonatorID, "", models.AuditActionApproved)
 }
 
-func (r *ApprovalRepository) DenyBooking(bookingID, staffID uuid.UUID, reason string) (*models.Booking, error) {
-	return r.setBookingStatus(bookingID, models.StatusDenied, staffID, reason, models.AuditActionDenied)
+func (r *ApprovalRepository) DenyBooking(bookingID, staffID uuid.UUID, impersonatorID *uuid.UUID, reason string) (*models.Booking, error) {
+	return r.setBookingStatus(bookingID, models.StatusDenied, staffID, impersonatorID, reason, models.AuditActionDenied)
 }
 
 func (r *ApprovalRepository) GetAuditTrail(bookingID uuid.UUID) ([]models.ApprovalAudit, error) {
@@ -146,3 +130,26 @@ func (r *ApprovalRepository) GetAuditTrail(bookingID uuid.UUID) ([]models.Approv
 		Find(&events).Error
 	return events, err
 }
+
+func (r *ApprovalRepository) ListAuditsByStaff(staffID uuid.UUID) ([]models.ApprovalAudit, error) {
+	var audits []models.ApprovalAudit
+	err := r.db.Where("staff_id = ? OR impersonator_id = ?", staffID, staffID).
+		Order("created_at ASC").
+		Find(&audits).Error
+	return audits, err
+}
+
+// EraseStaffAudits detaches approval decisions from the user. The decisions
+// themselves stay so a booking's history remains complete.
+func (r *ApprovalRepository) EraseStaffAudits(staffID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ApprovalAudit{}).
+			Where("staff_id = ?", staffID).
+			Update("staff_id", uuid.Nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ApprovalAudit{}).
+			Where("impersonator_id = ?", staffID).
+			Update("impersonator_id", nil).Error
+	})
+}
@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"log"
+	"os"
+
+	roompb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/room"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// NewRoomClient connects to the room service's gRPC API. It returns nil when
+// the address cannot be used; room names are then left empty.
+func NewRoomClient() roompb.RoomServiceClient {
+	port := os.Getenv("ROOM_GRPC_PORT")
+	if port == "" {
+		port = "50053"
+	}
+
+	conn, err := grpc.NewClient("room-service:"+port, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Printf("Warning: failed to connect to room service: %v", err)
+		return nil
+	}
+	return roompb.NewRoomServiceClient(conn)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"log"
+	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	roompb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/room"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/approval/models"
 	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/services/approval/proto"
 	"github.com/google/uuid"
@@ -15,17 +18,81 @@ import (
 )
 
 type ApprovalService struct {
-	repo      *ApprovalRepository
-	publisher events.Publisher
+	repo       *ApprovalRepository
+	publisher  events.Publisher
+	rooms      *events.RoomDirectory
+	roomClient roompb.RoomServiceClient
 	pb.UnimplementedApprovalServiceServer
 }
 
-func NewApprovalService(repo *ApprovalRepository, publisher events.Publisher) *ApprovalService {
-	return &ApprovalService{repo: repo, publisher: publisher}
+func NewApprovalService(repo *ApprovalRepository, publisher events.Publisher, roomClient roompb.RoomServiceClient) *ApprovalService {
+	return &ApprovalService{
+		repo:       repo,
+		publisher:  publisher,
+		rooms:      events.NewRoomDirectory(),
+		roomClient: roomClient,
+	}
 }
 
-func (s *ApprovalService) GetRoomNames(ids []string) (map[string]string, error) {
-	return s.repo.GetRoomNames(ids)
+// HandleRoomEvent keeps the local room read model current. It is fed by
+// events.ConsumeRoomEvents.
+func (s *ApprovalService) HandleRoomEvent(ctx context.Context, evt events.RoomEvent) error {
+	return s.rooms.Apply(ctx, evt)
+}
+
+// GetRoomNames answers from the room read model and asks the room service
+// only for rooms no event has been seen for yet, remembering the result.
+func (s *ApprovalService) GetRoomNames(ctx context.Context, ids []string) (map[string]string, error) {
+	names := make(map[string]string, len(ids))
+	var missing []string
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if room, ok := s.rooms.Get(id); ok {
+			names[id] = room.Name
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return names, nil
+	}
+	if s.roomClient == nil {
+		return names, errors.New("room service unavailable")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	resp, err := s.roomClient.BatchGetRooms(ctx, &roompb.BatchGetRoomsRequest{Ids: missing})
+	if err != nil {
+		return names, err
+	}
+	for id, room := range resp.GetRooms() {
+		s.rooms.Put(roomSnapshot(room))
+		names[id] = room.GetName()
+	}
+	return names, nil
+}
+
+func roomSnapshot(room *roompb.Room) events.RoomSnapshot {
+	snapshot := events.RoomSnapshot{
+		ID:         room.GetId(),
+		Name:       room.GetName(),
+		Capacity:   int(room.GetCapacity()),
+		Features:   room.GetFeatures(),
+		Building:   room.GetBuilding(),
+		Floor:      int(room.GetFloor()),
+		RoomNumber: room.GetRoomNumber(),
+		Accessible: room.GetAccessible(),
+		TimeZone:   room.GetTimeZone(),
+	}
+	if room.GetArchivedAt() != nil {
+		archivedAt := room.GetArchivedAt().AsTime()
+		snapshot.ArchivedAt = &archivedAt
+	}
+	return snapshot
 }
 
 func (s *ApprovalService) GetUserNames(ids []string) (map[string]string, error) {
@@ -43,7 +110,7 @@ func (s *ApprovalService) publishBookingEvent(ctx context.Context, booking *mode
 
 	// Fetch room name
 	roomID := booking.RoomID.String()
-	roomNames, err := s.repo.GetRoomNames([]string{roomID})
+	roomNames, err := s.GetRoomNames(ctx, []string{roomID})
 	roomName := roomID // Fallback to room ID
 	if err == nil && len(roomNames) > 0 {
 		if name, ok := roomNames[roomID]; ok && name != "" {
@@ -60,13 +127,26 @@ func (s *ApprovalService) publishBookingEvent(ctx context.Context, booking *mode
 		Status:    booking.Status,
 		StartTime: booking.StartTime,
 		EndTime:   booking.EndTime,
-		Metadata:  metadata,
+		Metadata:  authz.ActorMetadata(ctx, metadata),
 	}
 	if err := s.publisher.PublishBookingEvent(ctx, payload); err != nil {
 		log.Printf("failed to publish approval event %s: %v", event, err)
 	}
 }
 
+// impersonatorID returns the admin behind an impersonation token, if any.
+func impersonatorID(ctx context.Context) *uuid.UUID {
+	actor := authz.ActorFromContext(ctx)
+	if actor == nil {
+		return nil
+	}
+	id, err := uuid.Parse(actor.Subject)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
 func (s *ApprovalService) ListPending(ctx context.Context, req *pb.ListPendingRequest) (*pb.ListPendingResponse, error) {
 	bookings, err := s.repo.ListPendingBookings()
 	if err != nil {
@@ -97,7 +177,7 @@ func (s *ApprovalService) ApproveBooking(ctx context.Context, req *pb.ApproveReq
 		return nil, status.Error(codes.InvalidArgument, "invalid staff_id")
 	}
 
-	booking, err := s.repo.ApproveBooking(bookingID, staffID)
+	booking, err := s.repo.ApproveBooking(bookingID, staffID, impersonatorID(ctx))
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrBookingNotFound):
@@ -134,7 +214,7 @@ func (s *ApprovalService) DenyBooking(ctx context.Context, req *pb.DenyRequest)
 		return nil, status.Error(codes.InvalidArgument, "reason is required when denying a booking")
 	}
 
-	booking, err := s.repo.DenyBooking(bookingID, staffID, reason)
+	booking, err := s.repo.DenyBooking(bookingID, staffID, impersonatorID(ctx), reason)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrBookingNotFound):
@@ -15,7 +15,9 @@ type ApprovalAudit struct {
 	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
 	BookingID uuid.UUID `gorm:"type:uuid;index"`
 	StaffID   uuid.UUID `gorm:"type:uuid"`
-	Action    string    `gorm:"type:varchar(20)"`
-	Reason    string    `gorm:"type:text"`
-	CreatedAt time.Time
+	// ImpersonatorID is the admin who acted as StaffID, if any.
+	ImpersonatorID *uuid.UUID `gorm:"type:uuid"`
+	Action         string     `gorm:"type:varchar(20)"`
+	Reason         string     `gorm:"type:text"`
+	CreatedAt      time.Time
 }
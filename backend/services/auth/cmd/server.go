@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/config"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/internal"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/middleware"
@@ -20,14 +25,65 @@ func main() {
 
 	// DB
 	db := config.ConnectDB()
-	db.AutoMigrate(&models.User{})
+	db.AutoMigrate(
+		&models.User{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.UserIdentity{},
+		&models.ServiceAccount{},
+		&models.APIKey{},
+		&models.AuditLog{},
+		&models.Group{},
+		&models.GroupMember{},
+		&models.ImpersonationSession{},
+		&models.DataSubjectRequest{},
+		&models.DataSubjectTask{},
+	)
+	config.ProtectAuditLog(db)
+	config.SeedRoles(db)
 	config.SeedAdmin(db)
+	config.SeedServiceAccounts(db)
 
-	oauthCfg := config.GitHubOauthConfig()
+	providers := internal.NewProviderRegistry(internal.NewGitHubProvider(config.GitHubOauthConfig()))
+	for _, cfg := range config.OIDCProviderConfigs() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		provider, err := internal.NewOIDCProvider(ctx, cfg)
+		cancel()
+		if err != nil {
+			log.Printf("Skipping OIDC provider %s: %v", cfg.Name, err)
+			continue
+		}
+		providers.Register(provider)
+	}
+
+	// Event bus. Auth keeps serving logins without it; only data subject
+	// requests need it.
+	rabbitURL := os.Getenv("RABBITMQ_URL")
+	var publisher internal.EventPublisher
+	for attempt := 1; attempt <= 5; attempt++ {
+		p, err := events.NewRabbitPublisher(rabbitURL)
+		if err == nil {
+			defer p.Close()
+			publisher = p
+			break
+		}
+		wait := time.Duration(attempt) * time.Second
+		log.Printf("auth-service: failed to connect event publisher (attempt %d/5): %v; retrying in %s", attempt, err, wait)
+		time.Sleep(wait)
+	}
+	if publisher == nil {
+		log.Println("auth-service: event bus unavailable; data subject requests disabled")
+	}
 
 	// Layers
 	repo := internal.NewAuthRepository(db)
-	service := internal.NewAuthService(repo, oauthCfg)
+	service := internal.NewAuthService(repo, providers, publisher)
+
+	if publisher != nil {
+		go events.RunUntilDone(context.Background(), "privacy results consumer", func(ctx context.Context) error {
+			return events.ConsumePrivacyResults(ctx, rabbitURL, service.HandlePrivacyResult)
+		})
+	}
 	handler := internal.NewAuthHandler(service)
 
 	// Fiber
@@ -37,18 +93,77 @@ func main() {
 	app.Post("/auth/register", handler.Register)
 	app.Post("/auth/login", handler.Login)
 
-	// github login
-	app.Get("/auth/github/login", handler.GitHubLogin)
-	app.Get("/auth/github/callback", handler.GitHubCallback)
+	// external identity providers (GitHub and any configured OIDC issuer)
+	app.Get("/auth/github/login", handler.OAuthLogin)
+	app.Get("/auth/github/callback", handler.OAuthCallback)
+	app.Get("/auth/oauth/providers", handler.ListProviders)
+	app.Get("/auth/oauth/:provider/login", handler.OAuthLogin)
+	app.Get("/auth/oauth/:provider/callback", handler.OAuthCallback)
 
 	app.Get("/auth/my-profile", middleware.AuthMiddleware(service, models.USER, models.ADMIN), handler.MyProfile)
 	app.Put("/auth/profile", middleware.AuthMiddleware(service, models.USER, models.ADMIN), handler.UpdateProfile)
+	app.Get("/auth/my-profile/impersonations", middleware.AuthMiddleware(service), handler.MyImpersonations)
+	app.Post("/auth/impersonation/end", middleware.AuthMiddleware(service), handler.EndCurrentImpersonation)
 	app.Get("/auth/logout", handler.Logout)
-	app.Get("/auth/users/:id", handler.GetUserByID)
-	app.Put("/auth/users/:id", handler.UpdateUserByID)
+	// other services confirm a token is still honoured before trusting it
+	app.Get("/auth/session", middleware.AuthMiddleware(service), handler.ValidateSession)
+
+	// personal data export and erasure
+	app.Post("/auth/privacy/requests", middleware.AuthMiddleware(service), handler.RequestMyPrivacy)
+	app.Get("/auth/privacy/requests", middleware.AuthMiddleware(service), handler.ListMyPrivacyRequests)
+	app.Get("/auth/privacy/requests/:id", middleware.AuthMiddleware(service), handler.GetMyPrivacyRequest)
+	app.Get("/auth/privacy/requests/:id/export", middleware.AuthMiddleware(service), handler.DownloadMyPrivacyExport)
+
+	// single-user lookups: the user themselves, an admin, or a service API key
+	app.Get("/auth/users/:id", middleware.RequireUserAccess(service, authz.ScopeUsersRead, authz.PermUserRead), handler.GetUserByID)
+	app.Put("/auth/users/:id", middleware.RequireUserAccess(service, authz.ScopeUsersWrite, authz.PermUserManage), handler.UpdateUserByID)
 
 	// admin routes
-	app.Post("/auth/admin/register", middleware.AuthMiddleware(service, models.ADMIN), handler.AdminRegister)
+	app.Post("/auth/admin/register", middleware.RequirePermission(service, authz.PermUserManage), handler.AdminRegister)
+	app.Get("/auth/admin/roles", middleware.RequirePermission(service, authz.PermRoleManage), handler.ListRoles)
+	app.Put("/auth/admin/roles/:name", middleware.RequirePermission(service, authz.PermRoleManage), handler.SaveRole)
+	app.Put("/auth/admin/users/:id/role", middleware.RequirePermission(service, authz.PermRoleManage), handler.AssignRole)
+	app.Get("/auth/admin/users", middleware.RequirePermission(service, authz.PermUserRead), handler.ListUsers)
+	app.Post("/auth/admin/users/:id/deactivate", middleware.RequirePermission(service, authz.PermUserManage), handler.DeactivateUser)
+	app.Post("/auth/admin/users/:id/reactivate", middleware.RequirePermission(service, authz.PermUserManage), handler.ReactivateUser)
+	app.Get("/auth/admin/audit-logs", middleware.RequirePermission(service, authz.PermAuditRead), handler.ListAuditLogs)
+
+	// helpdesk impersonation
+	app.Post("/auth/admin/users/:id/impersonate", middleware.RequirePermission(service, authz.PermUserImpersonate), handler.Impersonate)
+	app.Get("/auth/admin/impersonations", middleware.RequirePermission(service, authz.PermAuditRead), handler.ListImpersonations)
+	app.Post("/auth/admin/impersonations/:id/end", middleware.RequirePermission(service, authz.PermUserImpersonate), handler.EndImpersonation)
+
+	// data subject requests on behalf of users
+	app.Post("/auth/admin/privacy/requests", middleware.RequirePermission(service, authz.PermUserManage), handler.AdminRequestPrivacy)
+	app.Get("/auth/admin/privacy/requests", middleware.RequirePermission(service, authz.PermUserRead), handler.AdminListPrivacyRequests)
+	app.Get("/auth/admin/privacy/requests/:id", middleware.RequirePermission(service, authz.PermUserRead), handler.AdminGetPrivacyRequest)
+	app.Get("/auth/admin/privacy/requests/:id/export", middleware.RequirePermission(service, authz.PermUserManage), handler.AdminDownloadPrivacyExport)
+	app.Post("/auth/admin/privacy/requests/:id/retry", middleware.RequirePermission(service, authz.PermUserManage), handler.AdminRetryPrivacyRequest)
+
+	// service accounts and API keys for service-to-service calls
+	serviceAccountAdmin := middleware.RequirePermission(service, authz.PermServiceAccountManage)
+	app.Get("/auth/admin/service-accounts", serviceAccountAdmin, handler.ListServiceAccounts)
+	app.Post("/auth/admin/service-accounts", serviceAccountAdmin, handler.CreateServiceAccount)
+	app.Post("/auth/admin/service-accounts/:id/keys", serviceAccountAdmin, handler.IssueAPIKey)
+	app.Post("/auth/admin/service-accounts/:id/keys/:keyId/rotate", serviceAccountAdmin, handler.RotateAPIKey)
+	app.Delete("/auth/admin/service-accounts/:id/keys/:keyId", serviceAccountAdmin, handler.RevokeAPIKey)
+
+	// departments and teams
+	groupAdmin := middleware.RequirePermission(service, authz.PermGroupManage)
+	app.Get("/auth/admin/groups", groupAdmin, handler.ListGroups)
+	app.Post("/auth/admin/groups", groupAdmin, handler.CreateGroup)
+	app.Put("/auth/admin/groups/:id", groupAdmin, handler.UpdateGroup)
+	app.Delete("/auth/admin/groups/:id", groupAdmin, handler.DeleteGroup)
+	app.Get("/auth/admin/groups/:id/members", groupAdmin, handler.ListGroupMembers)
+	app.Post("/auth/admin/groups/:id/members", groupAdmin, handler.AddGroupMember)
+	app.Delete("/auth/admin/groups/:id/members/:userId", groupAdmin, handler.RemoveGroupMember)
+
+	// other services authenticate their internal callers' API keys here
+	app.Get("/auth/internal/service-identity", handler.ServiceIdentity)
+
+	// group lookups for other services (API key with groups:read)
+	app.Get("/auth/internal/users/:id/groups", handler.UserGroups)
+	app.Get("/auth/internal/groups/:id/members", handler.InternalGroupMembers)
 
 	log.Println("Auth service running on :8081")
 	if err := app.Listen(":8081"); err != nil {
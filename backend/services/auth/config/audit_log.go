@@ -0,0 +1,50 @@
+package config
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// ProtectAuditLog installs a trigger that rejects DELETE on the audit log and
+// any UPDATE other than erasing personal data from an entry: clearing its IP
+// and user agent and dropping email keys from its details. Entries can be
+// appended and pseudonymised, never rewritten.
+func ProtectAuditLog(db *gorm.DB) {
+	if db == nil {
+		log.Println("ProtectAuditLog skipped: database handle is nil")
+		return
+	}
+
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION auth_audit_logs_append_only() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'UPDATE'
+				AND NEW.id = OLD.id
+				AND NEW.actor_id IS NOT DISTINCT FROM OLD.actor_id
+				AND NEW.action = OLD.action
+				AND NEW.outcome = OLD.outcome
+				AND NEW.target_user_id IS NOT DISTINCT FROM OLD.target_user_id
+				AND NEW.created_at = OLD.created_at
+				AND (NEW.ip IS NOT DISTINCT FROM OLD.ip OR NEW.ip = '')
+				AND (NEW.user_agent IS NOT DISTINCT FROM OLD.user_agent OR NEW.user_agent = '')
+				AND (NEW.details IS NOT DISTINCT FROM OLD.details
+					OR NEW.details IS NOT DISTINCT FROM OLD.details - ARRAY['email', 'email_hash'])
+			THEN
+				RETURN NEW;
+			END IF;
+			RAISE EXCEPTION 'auth_audit_logs is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS auth_audit_logs_append_only ON auth_audit_logs`,
+		`CREATE TRIGGER auth_audit_logs_append_only
+		BEFORE UPDATE OR DELETE ON auth_audit_logs
+		FOR EACH ROW EXECUTE FUNCTION auth_audit_logs_append_only()`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			log.Printf("Failed to protect audit log: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// PrivacyServices lists the services that must confirm every data subject
+// request, from PRIVACY_SERVICES (comma separated).
+func PrivacyServices() []string {
+	raw := os.Getenv("PRIVACY_SERVICES")
+	if strings.TrimSpace(raw) == "" {
+		raw = "booking,approval,notification"
+	}
+
+	var services []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			services = append(services, s)
+		}
+	}
+	return services
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -12,7 +13,7 @@ const (
 	defaultAdminEmail    = "admin@admin.com"
 	defaultAdminPassword = "Secured1"
 	defaultAdminName     = "System Admin"
-	defaultAdminRole     = "admin"
+	defaultAdminRole     = authz.RoleAdmin
 )
 
 func SeedAdmin(db *gorm.DB) {
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"log"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var defaultRoleDescriptions = map[string]string{
+	authz.RoleUser:  "Regular user who can search and book rooms",
+	authz.RoleAdmin: "Administrator with every permission",
+}
+
+// SeedRoles makes sure the built-in roles exist with at least their default
+// permissions and normalises legacy lower-case role names on users.
+func SeedRoles(db *gorm.DB) {
+	if db == nil {
+		log.Println("SeedRoles skipped: database handle is nil")
+		return
+	}
+
+	for name, perms := range authz.DefaultRolePermissions {
+		role := models.Role{Name: name, Description: defaultRoleDescriptions[name]}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&role).Error; err != nil {
+			log.Printf("Failed to seed role %s: %v", name, err)
+			continue
+		}
+
+		for _, p := range perms {
+			grant := models.RolePermission{RoleName: name, Permission: p}
+			if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error; err != nil {
+				log.Printf("Failed to seed permission %s for role %s: %v", p, name, err)
+			}
+		}
+	}
+
+	if err := db.Exec(`UPDATE users SET role = UPPER(role) WHERE role <> UPPER(role)`).Error; err != nil {
+		log.Printf("Failed to normalise user roles: %v", err)
+	}
+
+	log.Println("Default roles seeded (or already existed).")
+}
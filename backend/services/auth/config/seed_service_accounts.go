@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
+	"gorm.io/gorm"
+)
+
+// Internal callers of the auth service. Each gets its own service account; the
+// key is read from the environment so docker-compose can hand the same value
+// to the calling service.
+var bootstrapServiceAccounts = []struct {
+	name   string
+	envKey string
+	scopes []string
+}{
+	{"notification-service", "NOTIFICATION_SERVICE_API_KEY", []string{authz.ScopeUsersRead}},
+	{"approval-service", "APPROVAL_SERVICE_API_KEY", []string{authz.ScopeUsersRead}},
+}
+
+func SeedServiceAccounts(db *gorm.DB) {
+	if db == nil {
+		log.Println("SeedServiceAccounts skipped: database handle is nil")
+		return
+	}
+
+	for _, sa := range bootstrapServiceAccounts {
+		var account models.ServiceAccount
+		err := db.Where("name = ?", sa.name).First(&account).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			account = models.ServiceAccount{Name: sa.name, Description: "Bootstrap account for " + sa.name}
+			err = db.Create(&account).Error
+		}
+		if err != nil {
+			log.Printf("Failed to seed service account %s: %v", sa.name, err)
+			continue
+		}
+
+		key := strings.TrimSpace(os.Getenv(sa.envKey))
+		if key == "" {
+			continue
+		}
+
+		hash := models.HashAPIKey(key)
+		var count int64
+		if err := db.Model(&models.APIKey{}).Where("hash = ?", hash).Count(&count).Error; err != nil {
+			log.Printf("Failed to check API key for %s: %v", sa.name, err)
+			continue
+		}
+		if count > 0 {
+			continue
+		}
+
+		prefix := key
+		if len(prefix) > 8 {
+			prefix = prefix[:8]
+		}
+		apiKey := models.APIKey{
+			ServiceAccountID: account.ID,
+			Prefix:           prefix,
+			Hash:             hash,
+			Scopes:           strings.Join(sa.scopes, " "),
+		}
+		if err := db.Create(&apiKey).Error; err != nil {
+			log.Printf("Failed to seed API key for %s: %v", sa.name, err)
+		}
+	}
+
+	log.Println("Bootstrap service accounts seeded (or already existed).")
+}
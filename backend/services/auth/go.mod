@@ -3,6 +3,7 @@ module github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth
 go 1.24.7
 
 require (
+	github.com/JJnvn/Software-Arch-CPRoom/backend v0.0.0
 	github.com/gofiber/fiber/v2 v2.52.9
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/joho/godotenv v1.5.1
@@ -25,6 +26,7 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/rabbitmq/amqp091-go v1.10.0 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.51.0 // indirect
@@ -34,3 +36,4 @@ require (
 	golang.org/x/text v0.26.0 // indirect
 )
 
+replace github.com/JJnvn/Software-Arch-CPRoom/backend => ../../
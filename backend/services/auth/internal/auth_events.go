@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
+)
+
+var ErrInvalidOutcome = errors.New("outcome must be success or failure")
+
+// EventPublisher is the part of the event bus the auth service publishes to.
+type EventPublisher interface {
+	PublishPrivacyRequest(ctx context.Context, service string, req events.PrivacyRequest) error
+	PublishAuthEvent(ctx context.Context, evt events.AuthEvent) error
+	PublishSessionRevoked(ctx context.Context, evt events.SessionRevokedEvent) error
+}
+
+// RequestMeta identifies who made a request and from where, for the audit log.
+type RequestMeta struct {
+	ActorID   string
+	IP        string
+	UserAgent string
+}
+
+func (m RequestMeta) apply(entry *models.AuditLog) {
+	if entry.ActorID == "" {
+		entry.ActorID = m.ActorID
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = models.OutcomeSuccess
+	}
+	entry.IP = m.IP
+	entry.UserAgent = m.UserAgent
+}
+
+// auditEmailHash stands in for an email in audit details, so repeated
+// attempts against one address can be correlated without the log holding
+// the address. It is keyed with AUDIT_HASH_KEY, or JWT_SECRET if unset, so
+// the hashes cannot be reversed by hashing a list of known emails.
+func auditEmailHash(email string) string {
+	key := os.Getenv("AUDIT_HASH_KEY")
+	if key == "" {
+		key = os.Getenv("JWT_SECRET")
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// audited runs fn and records entry in one transaction, then publishes the
+// entry on the event bus.
+func (s *AuthService) audited(meta RequestMeta, entry *models.AuditLog, fn func(repo *AuthRepository) error) error {
+	meta.apply(entry)
+	if err := s.repo.Audited(entry, fn); err != nil {
+		return err
+	}
+	s.publishAuditLog(entry)
+	return nil
+}
+
+// RecordAuthEvent appends a standalone entry, such as a login attempt, to the
+// audit log. Failures are logged rather than returned so auditing never
+// blocks sign-in.
+func (s *AuthService) RecordAuthEvent(meta RequestMeta, action, outcome, targetUserID string, details models.JSONMap) {
+	entry := &models.AuditLog{
+		Action:       action,
+		Outcome:      outcome,
+		TargetUserID: targetUserID,
+		Details:      details,
+	}
+	meta.apply(entry)
+	if err := s.repo.CreateAuditLog(entry); err != nil {
+		log.Printf("failed to record auth event %s: %v", action, err)
+		return
+	}
+	s.publishAuditLog(entry)
+}
+
+func (s *AuthService) publishAuditLog(entry *models.AuditLog) {
+	if s.events == nil {
+		return
+	}
+
+	evt := events.AuthEvent{
+		ID:           entry.ID,
+		Action:       entry.Action,
+		Outcome:      entry.Outcome,
+		ActorID:      entry.ActorID,
+		TargetUserID: entry.TargetUserID,
+		IP:           entry.IP,
+		UserAgent:    entry.UserAgent,
+		Details:      entry.Details,
+		Occurred:     entry.CreatedAt,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.events.PublishAuthEvent(ctx, evt); err != nil {
+		log.Printf("failed to publish auth event %s: %v", entry.Action, err)
+	}
+}
+
+// publishSessionRevoked tells the other services to stop honouring tokens
+// issued to userID before at, instead of waiting for them to expire.
+func (s *AuthService) publishSessionRevoked(userID, reason string, at time.Time) {
+	if s.events == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	evt := events.SessionRevokedEvent{UserID: userID, Reason: reason, RevokedAt: at.UTC()}
+	if err := s.events.PublishSessionRevoked(ctx, evt); err != nil {
+		log.Printf("failed to publish session revocation for user %s: %v", userID, err)
+	}
+}
+
+func (s *AuthService) ListAuditLogs(filter AuditFilter) ([]models.AuditLog, int64, error) {
+	switch filter.Outcome {
+	case "", models.OutcomeSuccess, models.OutcomeFailure:
+	default:
+		return nil, 0, ErrInvalidOutcome
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 200 {
+		filter.PageSize = 50
+	}
+
+	return s.repo.ListAuditLogs(filter)
+}
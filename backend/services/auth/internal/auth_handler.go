@@ -2,13 +2,15 @@ package internal
 
 import (
 	"errors"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/oauth2"
 	"gorm.io/gorm"
 )
 
@@ -33,15 +35,18 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
     }
 
     if err := h.service.Register(req.Name, req.Email, req.Password, models.USER); err != nil {
+        h.service.RecordAuthEvent(requestMeta(c), models.AuditUserRegistered, models.OutcomeFailure, "", models.JSONMap{"email_hash": auditEmailHash(req.Email), "reason": err.Error()})
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
     }
 
     // Auto-login after successful registration: issue JWT, set cookie, return user+token
     user, token, err := h.service.Login(req.Email, req.Password)
     if err != nil {
+        h.service.RecordAuthEvent(requestMeta(c), models.AuditUserRegistered, models.OutcomeSuccess, "", models.JSONMap{"email_hash": auditEmailHash(req.Email)})
         // Fallback: user was created but login failed for some reason
         return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "user registered"})
     }
+    h.service.RecordAuthEvent(requestMeta(c), models.AuditUserRegistered, models.OutcomeSuccess, user.ID, models.JSONMap{"email_hash": auditEmailHash(user.Email)})
 
     h.setAuthCookie(c, token)
 
@@ -69,9 +74,17 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 
 	user, token, err := h.service.Login(req.Email, req.Password)
 	if err != nil {
+		h.service.RecordAuthEvent(requestMeta(c), models.AuditLogin, models.OutcomeFailure, "", models.JSONMap{"email_hash": auditEmailHash(req.Email), "reason": err.Error()})
+		if errors.Is(err, ErrUserDeactivated) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	meta := requestMeta(c)
+	meta.ActorID = user.ID
+	h.service.RecordAuthEvent(meta, models.AuditLogin, models.OutcomeSuccess, user.ID, models.JSONMap{"method": "password"})
+
 	h.setAuthCookie(c, token)
 
 	return c.JSON(fiber.Map{
@@ -86,55 +99,111 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
-func (h *AuthHandler) GitHubLogin(c *fiber.Ctx) error {
-	url := h.service.oauthCfg.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	return c.Redirect(url)
+const oauthStateCookie = "OAUTH_STATE"
+
+// ListProviders returns the names of the configured external login providers.
+func (h *AuthHandler) ListProviders(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"providers": h.service.providers.Names()})
 }
 
-func (h *AuthHandler) GitHubCallback(c *fiber.Ctx) error {
+// OAuthLogin redirects to the provider named by :provider (GitHub when the
+// route has no provider segment).
+func (h *AuthHandler) OAuthLogin(c *fiber.Ctx) error {
+	redirectURL, stateToken, err := h.service.BeginOAuth(c.Params("provider", "github"))
+	if err != nil {
+		if errors.Is(err, ErrUnknownProvider) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    stateToken,
+		Path:     "/auth",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+	})
+
+	return c.Redirect(redirectURL)
+}
+
+func (h *AuthHandler) OAuthCallback(c *fiber.Ctx) error {
 	code := c.Query("code")
 	errorParam := c.Query("error")
+	frontendURL := frontendBaseURL()
+	provider := c.Params("provider", "github")
+
+	fail := func(reason string) error {
+		h.service.RecordAuthEvent(requestMeta(c), models.AuditOAuthCallback, models.OutcomeFailure, "", models.JSONMap{"provider": provider, "reason": reason})
+		return c.Redirect(frontendURL + "/login?error=" + url.QueryEscape(reason))
+	}
+
+	stateToken := c.Cookies(oauthStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/auth",
+		MaxAge:   -1,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
 
 	// Handle OAuth error
 	if errorParam != "" {
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL == "" {
-			frontendURL = "http://localhost:5173"
-		}
-		return c.Redirect(frontendURL + "/login?error=" + errorParam)
+		return fail(errorParam)
 	}
 
 	if code == "" {
+		h.service.RecordAuthEvent(requestMeta(c), models.AuditOAuthCallback, models.OutcomeFailure, "", models.JSONMap{"provider": provider, "reason": "missing_code"})
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "authorization code is required"})
 	}
 
-	user, err := h.service.HandleGitHubCallback(code)
+	// A user who is already signed in links the provider to their account.
+	linkUserID := ""
+	if existing := c.Cookies(models.TOKEN); existing != "" {
+		if claims, err := h.service.ParseJWT(existing); err == nil {
+			linkUserID = claims.Subject
+		}
+	}
+
+	user, err := h.service.CompleteOAuth(c.Context(), provider, c.Query("state"), stateToken, code, linkUserID)
 	if err != nil {
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL == "" {
-			frontendURL = "http://localhost:5173"
+		switch {
+		case errors.Is(err, ErrInvalidOAuthState):
+			return fail("invalid_state")
+		case errors.Is(err, ErrIdentityLinked), errors.Is(err, ErrEmailNotVerified):
+			return fail("account_link_required")
+		case errors.Is(err, ErrUserDeactivated):
+			return fail("account_deactivated")
 		}
-		return c.Redirect(frontendURL + "/login?error=oauth_failed")
+		return fail("oauth_failed")
 	}
 
 	token, err := h.service.GenerateJWT(user)
 	if err != nil {
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL == "" {
-			frontendURL = "http://localhost:5173"
-		}
-		return c.Redirect(frontendURL + "/login?error=token_generation_failed")
+		return fail("token_generation_failed")
 	}
 
+	meta := requestMeta(c)
+	meta.ActorID = user.ID
+	h.service.RecordAuthEvent(meta, models.AuditOAuthCallback, models.OutcomeSuccess, user.ID, models.JSONMap{"provider": provider, "linked": linkUserID != ""})
+
 	h.setAuthCookie(c, token)
 
 	// Redirect to frontend with token
+	return c.Redirect(frontendURL + "/auth/callback?token=" + token)
+}
+
+func frontendBaseURL() string {
 	frontendURL := os.Getenv("FRONTEND_URL")
 	if frontendURL == "" {
 		frontendURL = "http://localhost:5173"
 	}
-
-	return c.Redirect(frontendURL + "/auth/callback?token=" + token)
+	return frontendURL
 }
 
 func (h *AuthHandler) MyProfile(c *fiber.Ctx) error {
@@ -146,18 +215,16 @@ func (h *AuthHandler) MyProfile(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"id":    user.ID,
-		"name":  user.Name,
-		"email": email,
-		"role":  user.Role,
+		"id":           user.ID,
+		"name":         user.Name,
+		"email":        email,
+		"role":         user.Role,
+		"permissions":  c.Locals("permissions"),
+		"impersonator": c.Locals("impersonator"),
 	})
 }
 
 func (h *AuthHandler) GetUserByID(c *fiber.Ctx) error {
-	if err := h.enforceServiceToken(c); err != nil {
-		return err
-	}
-
 	id := c.Params("id")
 	if id == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is required"})
@@ -198,6 +265,7 @@ func (h *AuthHandler) UpdateUserByID(c *fiber.Ctx) error {
 
 	// call service to update user fields
 	updatedUser, err := h.service.UpdateByID(id, body.Name, body.Email)
+	h.recordUpdate(c, models.AuditUserUpdated, id, body.Name, body.Email, err)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
@@ -250,6 +318,7 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 
 	// Update name and email
 	updatedUser, err := h.service.UpdateByID(user.ID, newName, newEmail)
+	h.recordUpdate(c, models.AuditProfileUpdated, user.ID, body.Name, body.Email, err)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -262,8 +331,10 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to hash password"})
 		}
 		if err := h.service.repo.UpdatePassword(user.ID, string(hashed)); err != nil {
+			h.service.RecordAuthEvent(requestMeta(c), models.AuditPasswordChanged, models.OutcomeFailure, user.ID, nil)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update password"})
 		}
+		h.service.RecordAuthEvent(requestMeta(c), models.AuditPasswordChanged, models.OutcomeSuccess, user.ID, nil)
 	}
 
 	return c.JSON(fiber.Map{
@@ -277,25 +348,35 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 	})
 }
 
-func (h *AuthHandler) enforceServiceToken(c *fiber.Ctx) error {
-	expected := strings.TrimSpace(os.Getenv("SERVICE_API_TOKEN"))
-	if expected == "" {
-		return nil
+// APIKeyFromRequest returns the service account API key sent as X-API-Key or
+// "Authorization: ApiKey <key>", or "" when the request carries none.
+func APIKeyFromRequest(c *fiber.Ctx) string {
+	if provided := strings.TrimSpace(c.Get("X-API-Key")); provided != "" {
+		return provided
+	}
+	authHeader := c.Get("Authorization")
+	if strings.HasPrefix(strings.ToLower(authHeader), "apikey ") {
+		return strings.TrimSpace(authHeader[7:])
 	}
+	return ""
+}
 
-	provided := strings.TrimSpace(c.Get("X-Service-Token"))
-	if provided == "" {
-		authHeader := c.Get("Authorization")
-		if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
-			provided = strings.TrimSpace(authHeader[7:])
+// requireServiceScopes authenticates a service account API key and checks its
+// scopes.
+func (h *AuthHandler) requireServiceScopes(c *fiber.Ctx, scopes ...string) (*ServiceIdentity, error) {
+	identity, err := h.service.AuthenticateAPIKey(APIKeyFromRequest(c))
+	if err != nil {
+		if errors.Is(err, ErrInvalidAPIKey) {
+			return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid api key")
 		}
+		return nil, err
 	}
-
-	if provided == "" || provided != expected {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid service token"})
+	if !identity.HasScope(scopes...) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "api key missing scope "+strings.Join(scopes, ", "))
 	}
 
-	return nil
+	c.Locals("serviceAccount", identity.Name)
+	return identity, nil
 }
 
 func (h *AuthHandler) AdminRegister(c *fiber.Ctx) error {
@@ -309,18 +390,645 @@ func (h *AuthHandler) AdminRegister(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
 	}
 
-	if err := h.service.Register(req.Name, req.Email, req.Password, models.ADMIN); err != nil {
+	if err := h.service.RegisterAdmin(requestMeta(c), req.Name, req.Email, req.Password); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "admin created"})
 }
 
+func (h *AuthHandler) ListRoles(c *fiber.Ctx) error {
+	roles, err := h.service.ListRoles()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	response := make([]fiber.Map, len(roles))
+	for i := range roles {
+		response[i] = roleResponse(&roles[i])
+	}
+	return c.JSON(fiber.Map{"roles": response, "available_permissions": authz.AllPermissions})
+}
+
+// SaveRole creates a role or replaces the permissions of an existing one.
+func (h *AuthHandler) SaveRole(c *fiber.Ctx) error {
+	var req struct {
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	role, err := h.service.SaveRole(requestMeta(c), c.Params("name"), req.Description, req.Permissions)
+	if err != nil {
+		if errors.Is(err, ErrUnknownPermission) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(roleResponse(role))
+}
+
+// AssignRole changes the role of the user identified by :id.
+func (h *AuthHandler) AssignRole(c *fiber.Ctx) error {
+	var req struct {
+		Role string `json:"role"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+	if strings.TrimSpace(req.Role) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role is required"})
+	}
+
+	user, err := h.service.AssignRole(requestMeta(c), c.Params("id"), req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownRole):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown role"})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"id":    user.ID,
+		"name":  user.Name,
+		"email": user.Email,
+		"role":  user.Role,
+	})
+}
+
+// ListUsers is the paginated admin user directory. Supports q (name or email
+// search), role, status, page and page_size query params.
+func (h *AuthHandler) ListUsers(c *fiber.Ctx) error {
+	filter := UserFilter{
+		Query:    c.Query("q"),
+		Role:     c.Query("role"),
+		Status:   c.Query("status"),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", 20),
+	}
+
+	users, total, err := h.service.ListUsers(filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidStatus) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	response := make([]fiber.Map, len(users))
+	for i := range users {
+		response[i] = userResponse(&users[i])
+	}
+
+	page := filter.Page
+	if page <= 0 {
+		page = 1
+	}
+	return c.JSON(fiber.Map{
+		"users": response,
+		"total": total,
+		"page":  page,
+	})
+}
+
+func (h *AuthHandler) DeactivateUser(c *fiber.Ctx) error {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		}
+	}
+
+	user, err := h.service.DeactivateUser(requestMeta(c), c.Params("id"), strings.TrimSpace(req.Reason))
+	if err != nil {
+		return respondUserError(c, err)
+	}
+	return c.JSON(userResponse(user))
+}
+
+func (h *AuthHandler) ReactivateUser(c *fiber.Ctx) error {
+	user, err := h.service.ReactivateUser(requestMeta(c), c.Params("id"))
+	if err != nil {
+		return respondUserError(c, err)
+	}
+	return c.JSON(userResponse(user))
+}
+
+// ListAuditLogs queries the security audit log. from/to are RFC 3339 times.
+func (h *AuthHandler) ListAuditLogs(c *fiber.Ctx) error {
+	filter := AuditFilter{
+		Action:       c.Query("action"),
+		Outcome:      c.Query("outcome"),
+		ActorID:      c.Query("actor_id"),
+		TargetUserID: c.Query("target_user_id"),
+		IP:           c.Query("ip"),
+		Page:         c.QueryInt("page", 1),
+		PageSize:     c.QueryInt("page_size", 50),
+	}
+	for param, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid " + param + " time"})
+		}
+		*dst = &t
+	}
+
+	logs, total, err := h.service.ListAuditLogs(filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOutcome) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	page := filter.Page
+	if page <= 0 {
+		page = 1
+	}
+	return c.JSON(fiber.Map{
+		"entries": logs,
+		"total":   total,
+		"page":    page,
+	})
+}
+
+func userResponse(user *models.User) fiber.Map {
+	status := user.Status
+	if status == "" {
+		status = models.StatusActive
+	}
+	return fiber.Map{
+		"id":         user.ID,
+		"name":       user.Name,
+		"email":      user.Email,
+		"role":       user.Role,
+		"status":     status,
+		"created_at": user.CreatedAt,
+		"updated_at": user.UpdatedAt,
+	}
+}
+
+func respondUserError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+	case errors.Is(err, ErrSelfDeactivation), errors.Is(err, ErrImpersonationReason):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrImpersonationForbidden), errors.Is(err, ErrUserDeactivated):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+// actorID returns the authenticated user ID set by the auth middleware, or
+// "service:<name>" when the caller used a service account API key.
+func actorID(c *fiber.Ctx) string {
+	if id, _ := c.Locals("userID").(string); id != "" {
+		return id
+	}
+	if name, _ := c.Locals("serviceAccount").(string); name != "" {
+		return "service:" + name
+	}
+	return ""
+}
+
+// recordUpdate audits a name/email change. Only the names of changed fields
+// are logged, not their values.
+func (h *AuthHandler) recordUpdate(c *fiber.Ctx, action, userID, name, email string, err error) {
+	var fields []string
+	if name != "" {
+		fields = append(fields, "name")
+	}
+	if email != "" {
+		fields = append(fields, "email")
+	}
+	details := models.JSONMap{"fields": fields}
+
+	outcome := models.OutcomeSuccess
+	if err != nil {
+		outcome = models.OutcomeFailure
+		details["reason"] = err.Error()
+	}
+	h.service.RecordAuthEvent(requestMeta(c), action, outcome, userID, details)
+}
+
+// requestMeta describes the caller for the audit log. Kong sits in front of
+// the service and appends the address it was reached from to
+// X-Forwarded-For, so the client address is the rightmost entry; the ones
+// before it come from the client and cannot be trusted.
+func requestMeta(c *fiber.Ctx) RequestMeta {
+	ip := c.IP()
+	if ips := c.IPs(); len(ips) > 0 && ips[len(ips)-1] != "" {
+		ip = ips[len(ips)-1]
+	}
+	return RequestMeta{
+		ActorID:   actorID(c),
+		IP:        ip,
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+}
+
+func roleResponse(role *models.Role) fiber.Map {
+	return fiber.Map{
+		"name":        role.Name,
+		"description": role.Description,
+		"permissions": role.PermissionNames(),
+	}
+}
+
+func (h *AuthHandler) CreateServiceAccount(c *fiber.Ctx) error {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	account, err := h.service.CreateServiceAccount(req.Name, req.Description)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate key") {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "service account name already exists"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(account)
+}
+
+func (h *AuthHandler) ListServiceAccounts(c *fiber.Ctx) error {
+	accounts, err := h.service.ListServiceAccounts()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	response := make([]fiber.Map, len(accounts))
+	for i, account := range accounts {
+		keys := make([]fiber.Map, len(account.Keys))
+		for j := range account.Keys {
+			keys[j] = apiKeyResponse(&account.Keys[j])
+		}
+		response[i] = fiber.Map{
+			"id":          account.ID,
+			"name":        account.Name,
+			"description": account.Description,
+			"disabled":    account.Disabled,
+			"created_at":  account.CreatedAt,
+			"keys":        keys,
+		}
+	}
+	return c.JSON(fiber.Map{"service_accounts": response, "available_scopes": authz.AllScopes})
+}
+
+// IssueAPIKey creates a key for the service account. The plaintext key is only
+// included in this response.
+func (h *AuthHandler) IssueAPIKey(c *fiber.Ctx) error {
+	var req struct {
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	plaintext, key, err := h.service.IssueAPIKey(c.Params("id"), req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return respondAPIKeyError(c, err)
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = plaintext
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// RotateAPIKey replaces a key. The old key stays valid for grace_period_seconds.
+func (h *AuthHandler) RotateAPIKey(c *fiber.Ctx) error {
+	var req struct {
+		GracePeriodSeconds int `json:"grace_period_seconds"`
+	}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		}
+	}
+	if req.GracePeriodSeconds < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "grace_period_seconds must be >= 0"})
+	}
+
+	plaintext, key, err := h.service.RotateAPIKey(c.Params("id"), c.Params("keyId"), time.Duration(req.GracePeriodSeconds)*time.Second)
+	if err != nil {
+		return respondAPIKeyError(c, err)
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = plaintext
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+func (h *AuthHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	if err := h.service.RevokeAPIKey(c.Params("id"), c.Params("keyId")); err != nil {
+		return respondAPIKeyError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func apiKeyResponse(key *models.APIKey) fiber.Map {
+	return fiber.Map{
+		"id":           key.ID,
+		"prefix":       key.Prefix,
+		"scopes":       key.ScopeList(),
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"revoked_at":   key.RevokedAt,
+		"created_at":   key.CreatedAt,
+	}
+}
+
+func respondAPIKeyError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "service account or key not found"})
+	case errors.Is(err, ErrAPIKeyInactive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrUnknownScope), errors.Is(err, ErrInvalidKeyRequest):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func (h *AuthHandler) Impersonate(c *fiber.Ctx) error {
+	if _, ok := c.Locals("impersonator").(*authz.Actor); ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "cannot impersonate while impersonating"})
+	}
+
+	var req struct {
+		Reason     string `json:"reason"`
+		TTLMinutes int    `json:"ttl_minutes"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	token, session, err := h.service.Impersonate(requestMeta(c), c.Params("id"), req.Reason, time.Duration(req.TTLMinutes)*time.Minute)
+	if err != nil {
+		return respondUserError(c, err)
+	}
+
+	// The token is returned rather than set as a cookie so the admin's own
+	// session stays intact.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token":   token,
+		"session": impersonationResponse(session),
+	})
+}
+
+func (h *AuthHandler) EndCurrentImpersonation(c *fiber.Ctx) error {
+	claims, _ := c.Locals("claims").(*Claims)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing token"})
+	}
+
+	session, err := h.service.EndCurrentImpersonation(requestMeta(c), claims)
+	if err != nil {
+		if errors.Is(err, ErrNotImpersonating) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(impersonationResponse(session))
+}
+
+func (h *AuthHandler) EndImpersonation(c *fiber.Ctx) error {
+	session, err := h.service.EndImpersonation(requestMeta(c), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "impersonation session not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(impersonationResponse(session))
+}
+
+// MyImpersonations lets a user see every time staff acted on their behalf.
+func (h *AuthHandler) MyImpersonations(c *fiber.Ctx) error {
+	return h.listImpersonations(c, actorID(c))
+}
+
+func (h *AuthHandler) ListImpersonations(c *fiber.Ctx) error {
+	return h.listImpersonations(c, c.Query("user_id"))
+}
+
+func (h *AuthHandler) listImpersonations(c *fiber.Ctx, targetUserID string) error {
+	sessions, err := h.service.ListImpersonations(targetUserID, c.QueryInt("limit", 50))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	out := make([]fiber.Map, 0, len(sessions))
+	for i := range sessions {
+		out = append(out, impersonationResponse(&sessions[i]))
+	}
+	return c.JSON(fiber.Map{"sessions": out})
+}
+
+func impersonationResponse(session *models.ImpersonationSession) fiber.Map {
+	resp := fiber.Map{
+		"id":             session.ID,
+		"actor_id":       session.ActorID,
+		"target_user_id": session.TargetUserID,
+		"reason":         session.Reason,
+		"expires_at":     session.ExpiresAt,
+		"ended_at":       session.EndedAt,
+		"created_at":     session.CreatedAt,
+	}
+	if session.Actor != nil {
+		resp["actor"] = fiber.Map{"id": session.Actor.ID, "name": session.Actor.Name, "email": session.Actor.Email}
+	}
+	if session.Target != nil {
+		resp["target"] = fiber.Map{"id": session.Target.ID, "name": session.Target.Name, "email": session.Target.Email}
+	}
+	return resp
+}
+
+type groupRequest struct {
+	Name        string  `json:"name"`
+	Kind        string  `json:"kind"`
+	Description string  `json:"description"`
+	ParentID    *string `json:"parent_id"`
+}
+
+func (r groupRequest) input() GroupInput {
+	return GroupInput{Name: r.Name, Kind: r.Kind, Description: r.Description, ParentID: r.ParentID}
+}
+
+func (h *AuthHandler) ListGroups(c *fiber.Ctx) error {
+	groups, err := h.service.ListGroups(c.Query("kind"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"groups": groups})
+}
+
+func (h *AuthHandler) CreateGroup(c *fiber.Ctx) error {
+	var req groupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	group, err := h.service.CreateGroup(requestMeta(c), req.input())
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(group)
+}
+
+func (h *AuthHandler) UpdateGroup(c *fiber.Ctx) error {
+	var req groupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	group, err := h.service.UpdateGroup(requestMeta(c), c.Params("id"), req.input())
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.JSON(group)
+}
+
+func (h *AuthHandler) DeleteGroup(c *fiber.Ctx) error {
+	if err := h.service.DeleteGroup(requestMeta(c), c.Params("id")); err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AuthHandler) ListGroupMembers(c *fiber.Ctx) error {
+	permission := c.Query("permission")
+	if permission != "" && !authz.IsKnownPermission(permission) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown permission"})
+	}
+
+	members, err := h.service.GroupMembers(c.Params("id"), c.QueryBool("recursive"), permission)
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+
+	out := make([]fiber.Map, 0, len(members))
+	for i := range members {
+		out = append(out, fiber.Map{
+			"id":    members[i].ID,
+			"name":  members[i].Name,
+			"email": members[i].Email,
+			"role":  members[i].Role,
+		})
+	}
+	return c.JSON(fiber.Map{"members": out})
+}
+
+func (h *AuthHandler) AddGroupMember(c *fiber.Ctx) error {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.UserID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "user_id is required"})
+	}
+
+	if err := h.service.AddGroupMember(requestMeta(c), c.Params("id"), req.UserID); err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AuthHandler) RemoveGroupMember(c *fiber.Ctx) error {
+	if err := h.service.RemoveGroupMember(requestMeta(c), c.Params("id"), c.Params("userId")); err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// UserGroups lets other services resolve a user's departments and teams,
+// e.g. to route approvals to the right department.
+func (h *AuthHandler) UserGroups(c *fiber.Ctx) error {
+	if _, err := h.requireServiceScopes(c, authz.ScopeGroupsRead); err != nil {
+		return respondError(c, err)
+	}
+
+	groups, err := h.service.GroupsForUser(c.Params("id"))
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.JSON(fiber.Map{"groups": groups})
+}
+
+// ServiceIdentity reports the service account and scopes of the presented API
+// key, so other services can authenticate their internal callers.
+func (h *AuthHandler) ServiceIdentity(c *fiber.Ctx) error {
+	identity, err := h.requireServiceScopes(c)
+	if err != nil {
+		return respondError(c, err)
+	}
+	scopes := identity.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return c.JSON(fiber.Map{"name": identity.Name, "scopes": scopes})
+}
+
+// InternalGroupMembers is ListGroupMembers for service API keys.
+func (h *AuthHandler) InternalGroupMembers(c *fiber.Ctx) error {
+	if _, err := h.requireServiceScopes(c, authz.ScopeGroupsRead); err != nil {
+		return respondError(c, err)
+	}
+	return h.ListGroupMembers(c)
+}
+
+func respondGroupError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "group or user not found"})
+	case errors.Is(err, ErrInvalidGroup):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case strings.Contains(strings.ToLower(err.Error()), "duplicate key"):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "group name already exists"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func respondError(c *fiber.Ctx, err error) error {
+	if fe, ok := err.(*fiber.Error); ok {
+		return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	h.clearAuthCookie(c)
 	return c.JSON(fiber.Map{"message": "logged out"})
 }
 
+// ValidateSession answers 204 when the bearer token is still honoured. The
+// route's middleware does the checking; anything else gets 401 there.
+func (h *AuthHandler) ValidateSession(c *fiber.Ctx) error {
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
 func (h *AuthHandler) setAuthCookie(c *fiber.Ctx, token string) {
 	c.Cookie(&fiber.Cookie{
 		Name:     models.TOKEN,
@@ -344,3 +1052,132 @@ func (h *AuthHandler) clearAuthCookie(c *fiber.Ctx) {
 		SameSite: "Lax",
 	})
 }
+
+func (h *AuthHandler) RequestMyPrivacy(c *fiber.Ctx) error {
+	// Staff acting as the user must not be able to export or erase their data.
+	if _, ok := c.Locals("impersonator").(*authz.Actor); ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "not allowed while impersonating"})
+	}
+
+	var req struct {
+		Type string `json:"type"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	dsr, err := h.service.RequestPrivacy(c.UserContext(), requestMeta(c), actorID(c), strings.ToLower(strings.TrimSpace(req.Type)))
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(dsr)
+}
+
+func (h *AuthHandler) ListMyPrivacyRequests(c *fiber.Ctx) error {
+	reqs, err := h.service.ListPrivacyRequests(actorID(c), "")
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return c.JSON(fiber.Map{"requests": reqs})
+}
+
+func (h *AuthHandler) GetMyPrivacyRequest(c *fiber.Ctx) error {
+	dsr, err := h.ownPrivacyRequest(c)
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return c.JSON(dsr)
+}
+
+func (h *AuthHandler) DownloadMyPrivacyExport(c *fiber.Ctx) error {
+	dsr, err := h.ownPrivacyRequest(c)
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return h.sendExportArchive(c, dsr)
+}
+
+// ownPrivacyRequest loads a request of the signed-in user; other users'
+// requests are reported as not found.
+func (h *AuthHandler) ownPrivacyRequest(c *fiber.Ctx) (*models.DataSubjectRequest, error) {
+	dsr, err := h.service.GetPrivacyRequest(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	if dsr.UserID != actorID(c) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return dsr, nil
+}
+
+func (h *AuthHandler) AdminRequestPrivacy(c *fiber.Ctx) error {
+	var req struct {
+		UserID string `json:"user_id"`
+		Type   string `json:"type"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.UserID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "user_id and type are required"})
+	}
+
+	dsr, err := h.service.RequestPrivacy(c.UserContext(), requestMeta(c), req.UserID, strings.ToLower(strings.TrimSpace(req.Type)))
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(dsr)
+}
+
+func (h *AuthHandler) AdminListPrivacyRequests(c *fiber.Ctx) error {
+	reqs, err := h.service.ListPrivacyRequests(c.Query("user_id"), c.Query("status"))
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return c.JSON(fiber.Map{"requests": reqs})
+}
+
+func (h *AuthHandler) AdminGetPrivacyRequest(c *fiber.Ctx) error {
+	dsr, err := h.service.GetPrivacyRequest(c.Params("id"))
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return c.JSON(dsr)
+}
+
+func (h *AuthHandler) AdminDownloadPrivacyExport(c *fiber.Ctx) error {
+	dsr, err := h.service.GetPrivacyRequest(c.Params("id"))
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return h.sendExportArchive(c, dsr)
+}
+
+func (h *AuthHandler) AdminRetryPrivacyRequest(c *fiber.Ctx) error {
+	dsr, err := h.service.RetryPrivacyRequest(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	return c.JSON(dsr)
+}
+
+func (h *AuthHandler) sendExportArchive(c *fiber.Ctx, dsr *models.DataSubjectRequest) error {
+	archive, err := h.service.ExportArchive(dsr)
+	if err != nil {
+		return respondPrivacyError(c, err)
+	}
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="personal-data-`+dsr.ID+`.zip"`)
+	return c.Send(archive)
+}
+
+func respondPrivacyError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "request or user not found"})
+	case errors.Is(err, ErrInvalidPrivacyType):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrPrivacyRequestOpen), errors.Is(err, ErrExportNotReady):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrEventsUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
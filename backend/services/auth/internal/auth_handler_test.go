@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestMetaUsesTheHopKongAppended(t *testing.T) {
+	tests := []struct {
+		name         string
+		forwardedFor string
+		wantIP       string
+	}{
+		{"no header", "", "0.0.0.0"},
+		{"kong only", "203.0.113.7", "203.0.113.7"},
+		{"spoofed by the client", "10.0.0.1, 203.0.113.7", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RequestMeta
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				got = requestMeta(c)
+				return nil
+			})
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.forwardedFor != "" {
+				req.Header.Set(fiber.HeaderXForwardedFor, tt.forwardedFor)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatal(err)
+			}
+			if got.IP != tt.wantIP {
+				t.Errorf("ip = %q, want %q", got.IP, tt.wantIP)
+			}
+		})
+	}
+}
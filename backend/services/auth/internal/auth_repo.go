@@ -1,8 +1,12 @@
 package internal
 
 import (
+	"strings"
+	"time"
+
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthRepository struct {
@@ -53,3 +57,569 @@ func (r *AuthRepository) UpdateByID(id string, name string, email string) (*mode
 func (r *AuthRepository) UpdatePassword(id string, hashedPassword string) error {
 	return r.db.Model(&models.User{}).Where("id = ?", id).Update("password", hashedPassword).Error
 }
+
+func (r *AuthRepository) FindRole(name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *AuthRepository) ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").Order("name ASC").Find(&roles).Error
+	return roles, err
+}
+
+// SaveRole creates or updates a role and replaces its permission set.
+// SaveRole replaces the role's permissions and revokes the tokens of every
+// user holding it, whose claims still carry the old permissions. It returns
+// those users' IDs and the revocation time.
+func (r *AuthRepository) SaveRole(role *models.Role, permissions []string) ([]string, time.Time, error) {
+	var holders []string
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(role).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Pluck("id", &holders).Error; err != nil {
+			return err
+		}
+		if len(holders) > 0 {
+			if err := tx.Model(&models.User{}).Where("id IN ?", holders).Update("tokens_revoked_at", now).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("role_name = ?", role.Name).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		role.Permissions = make([]models.RolePermission, 0, len(permissions))
+		for _, p := range permissions {
+			role.Permissions = append(role.Permissions, models.RolePermission{RoleName: role.Name, Permission: p})
+		}
+		if len(role.Permissions) == 0 {
+			return nil
+		}
+		return tx.Create(&role.Permissions).Error
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return holders, now, nil
+}
+
+// UpdateRole changes a user's role and revokes their existing tokens so the
+// old permissions stop working immediately.
+func (r *AuthRepository) UpdateRole(id string, role string) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := r.db.Model(&user).Updates(map[string]any{"role": role, "tokens_revoked_at": now}).Error; err != nil {
+		return nil, err
+	}
+	user.Role = role
+	user.TokensRevokedAt = &now
+
+	return &user, nil
+}
+
+func (r *AuthRepository) FindIdentity(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *AuthRepository) CreateIdentity(identity *models.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateUserWithIdentity creates a user and links the external identity in one transaction.
+func (r *AuthRepository) CreateUserWithIdentity(user *models.User, identity *models.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (r *AuthRepository) CreateServiceAccount(account *models.ServiceAccount) error {
+	return r.db.Create(account).Error
+}
+
+func (r *AuthRepository) FindServiceAccount(id string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := r.db.Preload("Keys", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	}).First(&account, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *AuthRepository) FindServiceAccountByName(name string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := r.db.Where("name = ?", name).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *AuthRepository) ListServiceAccounts() ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	err := r.db.Preload("Keys", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	}).Order("name ASC").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *AuthRepository) CreateAPIKey(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *AuthRepository) FindAPIKey(accountID, keyID string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("id = ? AND service_account_id = ?", keyID, accountID).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *AuthRepository) FindAPIKeyByHash(hash string) (*models.APIKey, *models.ServiceAccount, error) {
+	var key models.APIKey
+	if err := r.db.Where("hash = ?", hash).First(&key).Error; err != nil {
+		return nil, nil, err
+	}
+	var account models.ServiceAccount
+	if err := r.db.First(&account, "id = ?", key.ServiceAccountID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &key, &account, nil
+}
+
+// RotateAPIKey stores the replacement key and sets the old key to expire at
+// oldExpiresAt in a single transaction.
+func (r *AuthRepository) RotateAPIKey(oldKey *models.APIKey, oldExpiresAt time.Time, newKey *models.APIKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newKey).Error; err != nil {
+			return err
+		}
+		return tx.Model(oldKey).Update("expires_at", oldExpiresAt).Error
+	})
+}
+
+func (r *AuthRepository) RevokeAPIKey(key *models.APIKey, at time.Time) error {
+	return r.db.Model(key).Update("revoked_at", at).Error
+}
+
+func (r *AuthRepository) TouchAPIKey(id string, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+// UserFilter narrows the admin user directory.
+type UserFilter struct {
+	Query    string
+	Role     string
+	Status   string
+	Page     int
+	PageSize int
+}
+
+// likeEscaper makes a search term match literally inside a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *AuthRepository) ListUsers(filter UserFilter) ([]models.User, int64, error) {
+	query := r.db.Model(&models.User{})
+	if filter.Query != "" {
+		like := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		query = query.Where(`LOWER(name) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\'`, like, like)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	err := query.Order("name ASC, email ASC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&users).Error
+	return users, total, err
+}
+
+func (r *AuthRepository) UpdateStatus(id string, status string, tokensRevokedAt *time.Time) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]any{"status": status}
+	if tokensRevokedAt != nil {
+		updates["tokens_revoked_at"] = *tokensRevokedAt
+	}
+	if err := r.db.Model(&user).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(id)
+}
+
+type AuditFilter struct {
+	Action       string
+	Outcome      string
+	ActorID      string
+	TargetUserID string
+	IP           string
+	From         *time.Time
+	To           *time.Time
+	Page         int
+	PageSize     int
+}
+
+// ListAuditLogs returns matching entries newest first.
+func (r *AuthRepository) ListAuditLogs(filter AuditFilter) ([]models.AuditLog, int64, error) {
+	query := r.db.Model(&models.AuditLog{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetUserID != "" {
+		query = query.Where("target_user_id = ?", filter.TargetUserID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []models.AuditLog
+	err := query.Order("created_at DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&logs).Error
+	return logs, total, err
+}
+
+func (r *AuthRepository) CreateAuditLog(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+// Audited runs fn inside a transaction and records entry only if fn succeeds,
+// so a change and its audit record are never out of sync.
+func (r *AuthRepository) Audited(entry *models.AuditLog, fn func(repo *AuthRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(&AuthRepository{db: tx}); err != nil {
+			return err
+		}
+		return tx.Create(entry).Error
+	})
+}
+
+func (r *AuthRepository) CreateGroup(group *models.Group) error {
+	return r.db.Create(group).Error
+}
+
+func (r *AuthRepository) SaveGroup(group *models.Group) error {
+	return r.db.Save(group).Error
+}
+
+func (r *AuthRepository) DeleteGroup(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Re-parent children to the deleted group's parent so the tree stays connected.
+		var group models.Group
+		if err := tx.First(&group, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Group{}).Where("parent_id = ?", id).Update("parent_id", group.ParentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", id).Delete(&models.GroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+}
+
+func (r *AuthRepository) FindGroup(id string) (*models.Group, error) {
+	var group models.Group
+	if err := r.db.First(&group, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *AuthRepository) ListGroups(kind string) ([]models.Group, error) {
+	var groups []models.Group
+	query := r.db.Order("name ASC")
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	err := query.Find(&groups).Error
+	return groups, err
+}
+
+// GroupAncestorIDs returns the IDs of every ancestor of the group (not the group itself).
+func (r *AuthRepository) GroupAncestorIDs(id string) ([]string, error) {
+	var ids []string
+	err := r.db.Raw(`
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id FROM groups WHERE id = ?
+			UNION
+			SELECT g.parent_id FROM groups g JOIN ancestors a ON g.id = a.id
+		)
+		SELECT id FROM ancestors WHERE id IS NOT NULL`, id).Scan(&ids).Error
+	return ids, err
+}
+
+func (r *AuthRepository) AddGroupMember(member *models.GroupMember) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(member).Error
+}
+
+func (r *AuthRepository) RemoveGroupMember(groupID, userID string) error {
+	result := r.db.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&models.GroupMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UserGroupIDs returns the groups a user belongs to directly or through a
+// nested child group.
+func (r *AuthRepository) UserGroupIDs(userID string) ([]string, error) {
+	var ids []string
+	err := r.db.Raw(`
+		WITH RECURSIVE memberships AS (
+			SELECT g.id, g.parent_id FROM groups g
+			JOIN group_members m ON m.group_id = g.id
+			WHERE m.user_id = ?
+			UNION
+			SELECT g.id, g.parent_id FROM groups g JOIN memberships ms ON g.id = ms.parent_id
+		)
+		SELECT DISTINCT id FROM memberships ORDER BY id`, userID).Scan(&ids).Error
+	return ids, err
+}
+
+func (r *AuthRepository) FindGroupsByIDs(ids []string) ([]models.Group, error) {
+	var groups []models.Group
+	if len(ids) == 0 {
+		return groups, nil
+	}
+	err := r.db.Where("id IN ?", ids).Order("name ASC").Find(&groups).Error
+	return groups, err
+}
+
+// GroupMembers lists the members of a group. With recursive set, members of
+// descendant groups are included. A non-empty role filter keeps only users
+// whose role is in the list.
+func (r *AuthRepository) GroupMembers(groupID string, recursive bool, roles []string) ([]models.User, error) {
+	groupIDs := r.db.Raw(`SELECT ?::uuid AS id`, groupID)
+	if recursive {
+		groupIDs = r.db.Raw(`
+			WITH RECURSIVE descendants AS (
+				SELECT id FROM groups WHERE id = ?
+				UNION
+				SELECT g.id FROM groups g JOIN descendants d ON g.parent_id = d.id
+			)
+			SELECT id FROM descendants`, groupID)
+	}
+
+	query := r.db.Model(&models.User{}).
+		Where("id IN (?)", r.db.Model(&models.GroupMember{}).Select("user_id").Where("group_id IN (?)", groupIDs))
+	if len(roles) > 0 {
+		query = query.Where("role IN ?", roles)
+	}
+
+	var users []models.User
+	err := query.Order("name ASC").Find(&users).Error
+	return users, err
+}
+
+func (r *AuthRepository) CreateImpersonation(session *models.ImpersonationSession) error {
+	return r.db.Create(session).Error
+}
+
+func (r *AuthRepository) FindImpersonation(id string) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	if err := r.db.First(&session, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// EndImpersonation marks a session as ended. Ending an already ended session
+// is a no-op so the original end time is kept.
+func (r *AuthRepository) EndImpersonation(id string, at time.Time) (*models.ImpersonationSession, error) {
+	if err := r.db.Model(&models.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL", id).
+		Update("ended_at", at).Error; err != nil {
+		return nil, err
+	}
+	return r.FindImpersonation(id)
+}
+
+// ListImpersonations returns sessions newest first, optionally only those
+// targeting one user.
+func (r *AuthRepository) ListImpersonations(targetUserID string, limit int) ([]models.ImpersonationSession, error) {
+	var sessions []models.ImpersonationSession
+	query := r.db.Preload("Actor").Preload("Target").Order("created_at DESC")
+	if targetUserID != "" {
+		query = query.Where("target_user_id = ?", targetUserID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *AuthRepository) CreatePrivacyRequest(req *models.DataSubjectRequest) error {
+	return r.db.Create(req).Error
+}
+
+func (r *AuthRepository) FindPrivacyRequest(id string) (*models.DataSubjectRequest, error) {
+	var req models.DataSubjectRequest
+	if err := r.db.Preload("Tasks").First(&req, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// OpenPrivacyRequest returns the user's unfinished request of the given type.
+func (r *AuthRepository) OpenPrivacyRequest(userID, requestType string) (*models.DataSubjectRequest, error) {
+	var req models.DataSubjectRequest
+	if err := r.db.
+		Where("user_id = ? AND type = ? AND status = ?", userID, requestType, models.PrivacyStatusPending).
+		First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *AuthRepository) ListPrivacyRequests(userID, status string) ([]models.DataSubjectRequest, error) {
+	var reqs []models.DataSubjectRequest
+	query := r.db.Preload("Tasks").Order("created_at DESC")
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&reqs).Error; err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+func (r *AuthRepository) SavePrivacyTask(task *models.DataSubjectTask) error {
+	return r.db.Save(task).Error
+}
+
+func (r *AuthRepository) UpdatePrivacyRequestStatus(id, status string, completedAt *time.Time) error {
+	return r.db.Model(&models.DataSubjectRequest{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       status,
+		"completed_at": completedAt,
+	}).Error
+}
+
+func (r *AuthRepository) ListIdentities(userID string) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := r.db.Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *AuthRepository) ListAuditLogsForUser(userID string) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	if err := r.db.
+		Where("target_user_id = ? OR actor_id = ?", userID, userID).
+		Order("created_at ASC").
+		Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// erasePersonalAuditData strips the user's email from audit entries about
+// them, and the IP and user agent from entries of requests they made, either
+// signed in or, like a failed login, anonymously with their email.
+func erasePersonalAuditData(tx *gorm.DB, userID, email string) error {
+	byEmail := tx.Where("details->>'email_hash' = ?", auditEmailHash(email)).
+		Or("LOWER(details->>'email') = LOWER(?)", email)
+
+	if err := tx.Model(&models.AuditLog{}).
+		Where(tx.Where("actor_id = ?", userID).
+			Or(tx.Where("actor_id = '' OR actor_id IS NULL").Where(tx.Where("target_user_id = ?", userID).Or(byEmail)))).
+		Updates(map[string]any{"ip": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.AuditLog{}).
+		Where("jsonb_exists_any(details, array['email', 'email_hash'])").
+		Where(tx.Where("actor_id = ? OR target_user_id = ?", userID, userID).Or(byEmail)).
+		Update("details", gorm.Expr("details - ARRAY['email', 'email_hash']")).Error
+}
+
+// EraseUser pseudonymises the user row and deletes data that only exists to
+// identify them. Audit records keep the now-anonymous user ID but lose the
+// email and, where the user made the request, the IP and user agent; email
+// is the address being erased.
+func (r *AuthRepository) EraseUser(userID, email, placeholderEmail string, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := erasePersonalAuditData(tx, userID, email); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"name":              "Deleted user",
+			"email":             placeholderEmail,
+			"password":          "",
+			"status":            models.StatusDeactivated,
+			"tokens_revoked_at": at,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.GroupMember{}).Error; err != nil {
+			return err
+		}
+		// Earlier exports hold a copy of the data being erased.
+		return tx.Model(&models.DataSubjectTask{}).
+			Where("request_id IN (?)", tx.Model(&models.DataSubjectRequest{}).Select("id").Where("user_id = ?", userID)).
+			Update("data", nil).Error
+	})
+}
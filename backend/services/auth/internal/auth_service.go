@@ -2,37 +2,89 @@ package internal
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
-	"io"
+	"fmt"
+	"log"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	oauthStateAudience = "cproom-oauth-state"
+	oauthStateTTL      = 10 * time.Minute
+	apiKeyPrefix       = "cpk_"
 )
 
 type AuthService struct {
-	repo     *AuthRepository
-	oauthCfg *oauth2.Config
+	repo      *AuthRepository
+	providers *ProviderRegistry
+	events    EventPublisher
 }
 
+var (
+	ErrUnknownRole       = errors.New("unknown role")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrInvalidOAuthState = errors.New("invalid or expired oauth state")
+	ErrIdentityLinked    = errors.New("external identity is linked to another user")
+	ErrEmailNotVerified  = errors.New("the provider has not verified this email; sign in and link the provider instead")
+	ErrUnknownScope      = errors.New("unknown scope")
+	ErrInvalidAPIKey     = errors.New("invalid api key")
+	ErrAPIKeyInactive    = errors.New("api key is revoked or expired")
+	ErrInvalidKeyRequest = errors.New("invalid api key request")
+	ErrUserDeactivated   = errors.New("account is deactivated")
+	ErrTokenRevoked      = errors.New("token has been revoked")
+	ErrInvalidStatus     = errors.New("status must be active or deactivated")
+	ErrSelfDeactivation  = errors.New("cannot deactivate your own account")
+	ErrInvalidGroup      = errors.New("invalid group")
+
+	ErrImpersonationReason    = errors.New("a reason is required to impersonate a user")
+	ErrImpersonationForbidden = errors.New("this user cannot be impersonated")
+	ErrNotImpersonating       = errors.New("token is not an impersonation token")
+)
+
+const (
+	defaultImpersonationTTL = 15 * time.Minute
+	maxImpersonationTTL     = time.Hour
+)
+
 type Claims struct {
 	Email string `json:"email"`
 	Role  string `json:"role"`
+	// Permissions is always encoded, even when empty, so other services can
+	// tell a role stripped of every permission from a legacy role-only token.
+	Permissions []string `json:"permissions"`
+	Groups      []string `json:"groups,omitempty"`
+	// Act is set on impersonation tokens and names the admin behind them.
+	Act *authz.Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// HasPermission reports whether the token grants every required permission.
+func (c *Claims) HasPermission(required ...string) bool {
+	return authz.HasPermission(c.Role, c.Permissions, required...)
+}
+
 func NewAuthService(
 	repo *AuthRepository,
-	cfg *oauth2.Config,
+	providers *ProviderRegistry,
+	publisher EventPublisher,
 ) *AuthService {
 	return &AuthService{
-		repo:     repo,
-		oauthCfg: cfg,
+		repo:      repo,
+		providers: providers,
+		events:    publisher,
 	}
 }
 
@@ -47,7 +99,7 @@ func (s *AuthService) Register(name, email, password, role string) error {
 		Name:     name,
 		Email:    email,
 		Password: string(hashed),
-		Role:     strings.ToUpper(role),
+		Role:     authz.NormalizeRole(role),
 	}
 
 	return s.repo.CreateUser(user)
@@ -63,6 +115,10 @@ func (s *AuthService) Login(email, password string) (*models.User, string, error
 		return nil, "", errors.New("invalid email or password")
 	}
 
+	if !user.Active() {
+		return nil, "", ErrUserDeactivated
+	}
+
 	token, err := s.GenerateJWT(user)
 	if err != nil {
 		return nil, "", err
@@ -71,52 +127,175 @@ func (s *AuthService) Login(email, password string) (*models.User, string, error
 	return user, token, nil
 }
 
-func (s *AuthService) HandleGitHubCallback(code string) (*models.User, error) {
-	ctx := context.Background()
-	token, err := s.oauthCfg.Exchange(ctx, code)
+// BeginOAuth starts a login with the named provider. It returns the provider
+// URL to redirect to and a signed state token that must be stored in a cookie
+// and presented again on the callback.
+func (s *AuthService) BeginOAuth(providerName string) (string, string, error) {
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	req := AuthRequest{
+		State:    oauth2.GenerateVerifier(),
+		Verifier: oauth2.GenerateVerifier(),
+		Nonce:    oauth2.GenerateVerifier(),
+	}
+	stateToken, err := s.signOAuthState(provider.Name(), req)
+	if err != nil {
+		return "", "", err
+	}
+
+	return provider.AuthCodeURL(req), stateToken, nil
+}
+
+// CompleteOAuth validates the callback state, exchanges the code and returns
+// the local user for the external identity. When linkUserID is set the
+// identity is attached to that (already signed-in) user.
+func (s *AuthService) CompleteOAuth(ctx context.Context, providerName, state, stateToken, code, linkUserID string) (*models.User, error) {
+	provider, err := s.providers.Get(providerName)
 	if err != nil {
 		return nil, err
 	}
 
-	client := s.oauthCfg.Client(ctx, token)
-	resp, err := client.Get("https://api.github.com/user")
+	req, err := s.parseOAuthState(provider.Name(), stateToken)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if subtle.ConstantTimeCompare([]byte(req.State), []byte(state)) != 1 {
+		return nil, ErrInvalidOAuthState
+	}
 
-	data, _ := io.ReadAll(resp.Body)
-	var ghUser struct {
-		Login string `json:"login"`
-		Email string `json:"email"`
-		Name  string `json:"name"`
+	external, err := provider.Exchange(ctx, code, *req)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(data, &ghUser); err != nil {
+
+	return s.resolveExternalIdentity(external, linkUserID)
+}
+
+func (s *AuthService) resolveExternalIdentity(external *ExternalIdentity, linkUserID string) (*models.User, error) {
+	identity, err := s.repo.FindIdentity(external.Provider, external.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
+	if identity != nil {
+		if linkUserID != "" && identity.UserID != linkUserID {
+			return nil, ErrIdentityLinked
+		}
+		user, err := s.repo.FindByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !user.Active() {
+			return nil, ErrUserDeactivated
+		}
+		return user, nil
+	}
 
-	email := ghUser.Email
-	if email == "" {
-		email = ghUser.Login + "@github.com"
+	link := &models.UserIdentity{
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
 	}
 
-	user, _ := s.repo.FindByEmail(email)
-	if user != nil {
-		return user, nil
+	// Explicit linking from an authenticated session.
+	if linkUserID != "" {
+		user, err := s.repo.FindByID(linkUserID)
+		if err != nil {
+			return nil, err
+		}
+		link.UserID = user.ID
+		return user, s.repo.CreateIdentity(link)
 	}
 
+	// Only a verified email may match or create an account. Otherwise anyone
+	// could claim an existing account, or register an unverified address first
+	// and be linked to its real owner's account when they sign in later.
+	if !external.EmailVerified || external.Email == "" {
+		return nil, ErrEmailNotVerified
+	}
+	if user, err := s.repo.FindByEmail(external.Email); err == nil {
+		if !user.Active() {
+			return nil, ErrUserDeactivated
+		}
+		link.UserID = user.ID
+		return user, s.repo.CreateIdentity(link)
+	}
+
+	name := external.Name
+	if name == "" {
+		name = external.Email
+	}
 	newUser := &models.User{
-		Name:  ghUser.Name,
-		Email: email,
+		Name:  name,
+		Email: external.Email,
 		Role:  models.USER,
 	}
-	if err := s.repo.CreateUser(newUser); err != nil {
+	if err := s.repo.CreateUserWithIdentity(newUser, link); err != nil {
 		return nil, err
 	}
 
 	return newUser, nil
 }
 
+type oauthStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func (s *AuthService) signOAuthState(provider string, req AuthRequest) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "", errors.New("jwt secret missing")
+	}
+
+	now := time.Now()
+	claims := &oauthStateClaims{
+		Provider: provider,
+		State:    req.State,
+		Verifier: req.Verifier,
+		Nonce:    req.Nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oauthStateAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oauthStateTTL)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+func (s *AuthService) parseOAuthState(provider, tokenString string) (*AuthRequest, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("jwt secret missing")
+	}
+	if tokenString == "" {
+		return nil, ErrInvalidOAuthState
+	}
+
+	claims := &oauthStateClaims{}
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return []byte(secret), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(oauthStateAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid || claims.Provider != provider {
+		return nil, ErrInvalidOAuthState
+	}
+
+	return &AuthRequest{State: claims.State, Verifier: claims.Verifier, Nonce: claims.Nonce}, nil
+}
+
 func (s *AuthService) GetByEmail(email string) (*models.User, error) {
 	return s.repo.FindByEmail(email)
 }
@@ -142,7 +321,232 @@ func (s *AuthService) UpdateByID(id string, name string, email string) (*models.
 	return s.repo.UpdateByID(id, name, email)
 }
 
+// PermissionsForRole resolves the permissions currently granted to a role.
+// Roles missing from the roles table fall back to the built-in defaults.
+func (s *AuthService) PermissionsForRole(name string) ([]string, error) {
+	name = authz.NormalizeRole(name)
+	role, err := s.repo.FindRole(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return authz.DefaultRolePermissions[name], nil
+		}
+		return nil, err
+	}
+	return role.PermissionNames(), nil
+}
+
+func (s *AuthService) ListRoles() ([]models.Role, error) {
+	return s.repo.ListRoles()
+}
+
+// SaveRole creates or replaces a role and its permission set.
+func (s *AuthService) SaveRole(meta RequestMeta, name, description string, permissions []string) (*models.Role, error) {
+	name = authz.NormalizeRole(name)
+	if name == "" {
+		return nil, errors.New("role name is required")
+	}
+
+	seen := make(map[string]bool, len(permissions))
+	unique := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if !authz.IsKnownPermission(p) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+
+	role := &models.Role{Name: name, Description: strings.TrimSpace(description)}
+	entry := &models.AuditLog{
+		ActorID: meta.ActorID,
+		Action:  models.AuditRoleSaved,
+		Details: models.JSONMap{"role": name, "permissions": unique},
+	}
+	var holders []string
+	var revokedAt time.Time
+	if err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		var err error
+		holders, revokedAt, err = repo.SaveRole(role, unique)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	for _, userID := range holders {
+		s.publishSessionRevoked(userID, events.SessionRevokedRoleChanged, revokedAt)
+	}
+	return role, nil
+}
+
+// AssignRole changes the role of a user. The role must already exist.
+func (s *AuthService) AssignRole(meta RequestMeta, userID, roleName string) (*models.User, error) {
+	if userID == "" {
+		return nil, errors.New("id is required")
+	}
+
+	roleName = authz.NormalizeRole(roleName)
+	if _, err := s.repo.FindRole(roleName); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnknownRole
+		}
+		return nil, err
+	}
+
+	current, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *models.User
+	entry := &models.AuditLog{
+		ActorID:      meta.ActorID,
+		Action:       models.AuditUserRoleChanged,
+		TargetUserID: userID,
+		Details:      models.JSONMap{"from": current.Role, "to": roleName},
+	}
+	err = s.audited(meta, entry, func(repo *AuthRepository) error {
+		var err error
+		updated, err = repo.UpdateRole(userID, roleName)
+		return err
+	})
+	if err == nil {
+		s.publishSessionRevoked(userID, events.SessionRevokedRoleChanged, *updated.TokensRevokedAt)
+	}
+	return updated, err
+}
+
+// RegisterAdmin creates an administrator account on behalf of meta.ActorID.
+func (s *AuthService) RegisterAdmin(meta RequestMeta, name, email, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{
+		Name:     name,
+		Email:    email,
+		Password: string(hashed),
+		Role:     models.ADMIN,
+	}
+	entry := &models.AuditLog{
+		ActorID: meta.ActorID,
+		Action:  models.AuditAdminRegistered,
+		Details: models.JSONMap{"email_hash": auditEmailHash(email)},
+	}
+	return s.audited(meta, entry, func(repo *AuthRepository) error {
+		if err := repo.CreateUser(user); err != nil {
+			return err
+		}
+		entry.TargetUserID = user.ID
+		return nil
+	})
+}
+
+// ListUsers returns one page of the user directory and the total match count.
+func (s *AuthService) ListUsers(filter UserFilter) ([]models.User, int64, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Role != "" {
+		filter.Role = authz.NormalizeRole(filter.Role)
+	}
+	switch filter.Status {
+	case "", models.StatusActive, models.StatusDeactivated:
+	default:
+		return nil, 0, ErrInvalidStatus
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	return s.repo.ListUsers(filter)
+}
+
+// DeactivateUser blocks the user from signing in and revokes every token
+// issued to them so far.
+func (s *AuthService) DeactivateUser(meta RequestMeta, userID, reason string) (*models.User, error) {
+	if meta.ActorID == userID {
+		return nil, ErrSelfDeactivation
+	}
+
+	now := time.Now()
+	var updated *models.User
+	entry := &models.AuditLog{
+		ActorID:      meta.ActorID,
+		Action:       models.AuditUserDeactivated,
+		TargetUserID: userID,
+		Details:      models.JSONMap{"reason": reason},
+	}
+	err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		var err error
+		updated, err = repo.UpdateStatus(userID, models.StatusDeactivated, &now)
+		return err
+	})
+	if err == nil {
+		s.publishSessionRevoked(userID, events.SessionRevokedDeactivated, now)
+	}
+	return updated, err
+}
+
+func (s *AuthService) ReactivateUser(meta RequestMeta, userID string) (*models.User, error) {
+	var updated *models.User
+	entry := &models.AuditLog{
+		ActorID:      meta.ActorID,
+		Action:       models.AuditUserReactivated,
+		TargetUserID: userID,
+	}
+	err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		var err error
+		updated, err = repo.UpdateStatus(userID, models.StatusActive, nil)
+		return err
+	})
+	return updated, err
+}
+
+// ValidateSession rejects tokens of deactivated users and tokens issued before
+// the user's tokens were revoked.
+func (s *AuthService) ValidateSession(claims *Claims) error {
+	user, err := s.repo.FindByID(claims.Subject)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	if !user.Active() {
+		return ErrUserDeactivated
+	}
+	if user.TokensRevokedAt != nil && claims.IssuedAt != nil &&
+		claims.IssuedAt.Time.Before(user.TokensRevokedAt.Truncate(time.Second)) {
+		return ErrTokenRevoked
+	}
+	if claims.Act != nil {
+		session, err := s.repo.FindImpersonation(claims.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTokenRevoked
+			}
+			return err
+		}
+		if !session.Active(time.Now()) || session.ActorID != claims.Act.Subject {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
 func (s *AuthService) GenerateJWT(user *models.User) (string, error) {
+	now := time.Now()
+	return s.signUserToken(user, jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(24 * time.Hour)),
+	}, nil)
+}
+
+func (s *AuthService) signUserToken(user *models.User, registered jwt.RegisteredClaims, act *authz.Actor) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		return "", errors.New("jwt secret missing")
@@ -153,16 +557,28 @@ func (s *AuthService) GenerateJWT(user *models.User) (string, error) {
 		issuer = "cproom-auth"
 	}
 
-	now := time.Now()
+	permissions, err := s.PermissionsForRole(user.Role)
+	if err != nil {
+		return "", err
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	groups, err := s.repo.UserGroupIDs(user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	registered.Subject = user.ID
+	registered.Issuer = issuer
 	claims := &Claims{
-		Email: user.Email,
-		Role:  user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   user.ID,
-			Issuer:    issuer,
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(24 * time.Hour)),
-		},
+		Email:            user.Email,
+		Role:             authz.NormalizeRole(user.Role),
+		Permissions:      permissions,
+		Groups:           groups,
+		Act:              act,
+		RegisteredClaims: registered,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -201,3 +617,471 @@ func (s *AuthService) ParseJWT(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ServiceIdentity is the caller resolved from a service account API key.
+type ServiceIdentity struct {
+	AccountID string
+	Name      string
+	KeyID     string
+	Scopes    []string
+}
+
+// HasScope reports whether the key was issued with every required scope.
+func (s *ServiceIdentity) HasScope(required ...string) bool {
+	for _, want := range required {
+		found := false
+		for _, have := range s.Scopes {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *AuthService) CreateServiceAccount(name, description string) (*models.ServiceAccount, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	account := &models.ServiceAccount{Name: name, Description: strings.TrimSpace(description)}
+	if err := s.repo.CreateServiceAccount(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *AuthService) ListServiceAccounts() ([]models.ServiceAccount, error) {
+	return s.repo.ListServiceAccounts()
+}
+
+// IssueAPIKey creates a new key for a service account and returns the
+// plaintext value, which is not stored and cannot be retrieved again.
+func (s *AuthService) IssueAPIKey(accountID string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if _, err := s.repo.FindServiceAccount(accountID); err != nil {
+		return "", nil, err
+	}
+
+	plaintext, key, err := newAPIKey(accountID, scopes, expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.repo.CreateAPIKey(key); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// RotateAPIKey issues a replacement with the same scopes and expiry and lets
+// the old key keep working for the grace period so callers can roll over.
+func (s *AuthService) RotateAPIKey(accountID, keyID string, grace time.Duration) (string, *models.APIKey, error) {
+	old, err := s.repo.FindAPIKey(accountID, keyID)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	if !old.Active(now) {
+		return "", nil, ErrAPIKeyInactive
+	}
+
+	plaintext, key, err := newAPIKey(accountID, old.ScopeList(), old.ExpiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	oldExpiresAt := now.Add(grace)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(oldExpiresAt) {
+		oldExpiresAt = *old.ExpiresAt
+	}
+	if err := s.repo.RotateAPIKey(old, oldExpiresAt, key); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+func (s *AuthService) RevokeAPIKey(accountID, keyID string) error {
+	key, err := s.repo.FindAPIKey(accountID, keyID)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	return s.repo.RevokeAPIKey(key, time.Now())
+}
+
+// AuthenticateAPIKey resolves a plaintext API key to the owning service account.
+func (s *AuthService) AuthenticateAPIKey(plaintext string) (*ServiceIdentity, error) {
+	if plaintext == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, account, err := s.repo.FindAPIKeyByHash(models.HashAPIKey(plaintext))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if account.Disabled || !key.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Avoid a write on every request; minute resolution is plenty for auditing.
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		if err := s.repo.TouchAPIKey(key.ID, now); err != nil {
+			log.Printf("failed to record API key use for %s: %v", account.Name, err)
+		}
+	}
+
+	return &ServiceIdentity{
+		AccountID: account.ID,
+		Name:      account.Name,
+		KeyID:     key.ID,
+		Scopes:    key.ScopeList(),
+	}, nil
+}
+
+func newAPIKey(accountID string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !authz.IsKnownScope(scope) {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	if len(unique) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidKeyRequest)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidKeyRequest)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	plaintext := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	return plaintext, &models.APIKey{
+		ServiceAccountID: accountID,
+		Prefix:           plaintext[:len(apiKeyPrefix)+8],
+		Hash:             models.HashAPIKey(plaintext),
+		Scopes:           strings.Join(unique, " "),
+		ExpiresAt:        expiresAt,
+	}, nil
+}
+
+// GroupInput carries the editable fields of a group.
+type GroupInput struct {
+	Name        string
+	Kind        string
+	Description string
+	ParentID    *string
+}
+
+func (s *AuthService) ListGroups(kind string) ([]models.Group, error) {
+	return s.repo.ListGroups(kind)
+}
+
+func (s *AuthService) GetGroup(id string) (*models.Group, error) {
+	return s.repo.FindGroup(id)
+}
+
+func (s *AuthService) CreateGroup(meta RequestMeta, input GroupInput) (*models.Group, error) {
+	group := &models.Group{}
+	if err := s.applyGroupInput(group, input); err != nil {
+		return nil, err
+	}
+
+	entry := &models.AuditLog{
+		ActorID: meta.ActorID,
+		Action:  models.AuditGroupSaved,
+		Details: models.JSONMap{"name": group.Name, "kind": group.Kind, "parent_id": group.ParentID},
+	}
+	if err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		if err := repo.CreateGroup(group); err != nil {
+			return err
+		}
+		entry.Details["group_id"] = group.ID
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *AuthService) UpdateGroup(meta RequestMeta, id string, input GroupInput) (*models.Group, error) {
+	group, err := s.repo.FindGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyGroupInput(group, input); err != nil {
+		return nil, err
+	}
+
+	entry := &models.AuditLog{
+		ActorID: meta.ActorID,
+		Action:  models.AuditGroupSaved,
+		Details: models.JSONMap{"group_id": id, "name": group.Name, "kind": group.Kind, "parent_id": group.ParentID},
+	}
+	if err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		return repo.SaveGroup(group)
+	}); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *AuthService) DeleteGroup(meta RequestMeta, id string) error {
+	entry := &models.AuditLog{
+		ActorID: meta.ActorID,
+		Action:  models.AuditGroupDeleted,
+		Details: models.JSONMap{"group_id": id},
+	}
+	return s.audited(meta, entry, func(repo *AuthRepository) error {
+		return repo.DeleteGroup(id)
+	})
+}
+
+func (s *AuthService) applyGroupInput(group *models.Group, input GroupInput) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidGroup)
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(input.Kind))
+	switch kind {
+	case "":
+		kind = models.GroupKindTeam
+	case models.GroupKindDepartment, models.GroupKindTeam:
+	default:
+		return fmt.Errorf("%w: kind must be department or team", ErrInvalidGroup)
+	}
+
+	var parentID *string
+	if input.ParentID != nil && strings.TrimSpace(*input.ParentID) != "" {
+		pid := strings.TrimSpace(*input.ParentID)
+		if _, err := s.repo.FindGroup(pid); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: parent group not found", ErrInvalidGroup)
+			}
+			return err
+		}
+
+		// An existing group must not become its own ancestor.
+		if group.ID != "" {
+			if pid == group.ID {
+				return fmt.Errorf("%w: a group cannot be its own parent", ErrInvalidGroup)
+			}
+			ancestors, err := s.repo.GroupAncestorIDs(pid)
+			if err != nil {
+				return err
+			}
+			for _, a := range ancestors {
+				if a == group.ID {
+					return fmt.Errorf("%w: parent would create a cycle", ErrInvalidGroup)
+				}
+			}
+		}
+		parentID = &pid
+	}
+
+	group.Name = name
+	group.Kind = kind
+	group.Description = strings.TrimSpace(input.Description)
+	group.ParentID = parentID
+	return nil
+}
+
+func (s *AuthService) AddGroupMember(meta RequestMeta, groupID, userID string) error {
+	if _, err := s.repo.FindGroup(groupID); err != nil {
+		return err
+	}
+	if _, err := s.repo.FindByID(userID); err != nil {
+		return err
+	}
+
+	entry := &models.AuditLog{
+		ActorID:      meta.ActorID,
+		Action:       models.AuditMemberAdded,
+		TargetUserID: userID,
+		Details:      models.JSONMap{"group_id": groupID},
+	}
+	return s.audited(meta, entry, func(repo *AuthRepository) error {
+		return repo.AddGroupMember(&models.GroupMember{GroupID: groupID, UserID: userID})
+	})
+}
+
+func (s *AuthService) RemoveGroupMember(meta RequestMeta, groupID, userID string) error {
+	entry := &models.AuditLog{
+		ActorID:      meta.ActorID,
+		Action:       models.AuditMemberRemoved,
+		TargetUserID: userID,
+		Details:      models.JSONMap{"group_id": groupID},
+	}
+	return s.audited(meta, entry, func(repo *AuthRepository) error {
+		return repo.RemoveGroupMember(groupID, userID)
+	})
+}
+
+// GroupsForUser returns every group the user belongs to, including ancestors
+// of the groups they are a direct member of.
+func (s *AuthService) GroupsForUser(userID string) ([]models.Group, error) {
+	ids, err := s.repo.UserGroupIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindGroupsByIDs(ids)
+}
+
+// GroupMembers lists members of a group. When permission is set only users
+// whose role grants it are returned, e.g. to pick approvers for a department.
+func (s *AuthService) GroupMembers(groupID string, recursive bool, permission string) ([]models.User, error) {
+	if _, err := s.repo.FindGroup(groupID); err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	if permission != "" {
+		all, err := s.repo.ListRoles()
+		if err != nil {
+			return nil, err
+		}
+		for i := range all {
+			if authz.HasPermission(all[i].Name, all[i].PermissionNames(), permission) {
+				roles = append(roles, all[i].Name)
+			}
+		}
+		if len(roles) == 0 {
+			return []models.User{}, nil
+		}
+	}
+
+	return s.repo.GroupMembers(groupID, recursive, roles)
+}
+
+// Impersonate issues a short-lived token for target that carries an "act"
+// claim naming the admin. The session is audited and shown to the target.
+func (s *AuthService) Impersonate(meta RequestMeta, targetID, reason string, ttl time.Duration) (string, *models.ImpersonationSession, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", nil, ErrImpersonationReason
+	}
+	if meta.ActorID == targetID {
+		return "", nil, ErrImpersonationForbidden
+	}
+	if ttl <= 0 {
+		ttl = defaultImpersonationTTL
+	}
+	if ttl > maxImpersonationTTL {
+		ttl = maxImpersonationTTL
+	}
+
+	actor, err := s.repo.FindByID(meta.ActorID)
+	if err != nil {
+		return "", nil, err
+	}
+	target, err := s.repo.FindByID(targetID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !target.Active() {
+		return "", nil, ErrUserDeactivated
+	}
+
+	// Impersonating another admin would let helpdesk staff borrow privileges
+	// they were not granted directly.
+	targetPerms, err := s.PermissionsForRole(target.Role)
+	if err != nil {
+		return "", nil, err
+	}
+	if authz.HasPermission(target.Role, targetPerms, authz.PermUserImpersonate) ||
+		authz.HasPermission(target.Role, targetPerms, authz.PermRoleManage) {
+		return "", nil, ErrImpersonationForbidden
+	}
+
+	now := time.Now()
+	session := &models.ImpersonationSession{
+		ActorID:      actor.ID,
+		TargetUserID: target.ID,
+		Reason:       reason,
+		ExpiresAt:    now.Add(ttl),
+	}
+	entry := &models.AuditLog{
+		ActorID:      actor.ID,
+		Action:       models.AuditImpersonationStarted,
+		TargetUserID: target.ID,
+		Details:      models.JSONMap{"reason": reason, "expires_at": session.ExpiresAt},
+	}
+	if err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		if err := repo.CreateImpersonation(session); err != nil {
+			return err
+		}
+		entry.Details["session_id"] = session.ID
+		return nil
+	}); err != nil {
+		return "", nil, err
+	}
+
+	token, err := s.signUserToken(target, jwt.RegisteredClaims{
+		ID:        session.ID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+	}, &authz.Actor{Subject: actor.ID, Email: actor.Email})
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.Printf("impersonation started: actor=%s target=%s session=%s", actor.ID, target.ID, session.ID)
+	return token, session, nil
+}
+
+// EndImpersonation ends a session early. meta names whoever ended it: the
+// impersonating admin, or another admin revoking the session.
+func (s *AuthService) EndImpersonation(meta RequestMeta, sessionID string) (*models.ImpersonationSession, error) {
+	session, err := s.repo.FindImpersonation(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &models.AuditLog{
+		ActorID:      meta.ActorID,
+		Action:       models.AuditImpersonationEnded,
+		TargetUserID: session.TargetUserID,
+		Details:      models.JSONMap{"session_id": session.ID},
+	}
+	if err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		session, err = repo.EndImpersonation(sessionID, time.Now())
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	s.publishSessionRevoked(session.TargetUserID, events.SessionRevokedImpersonation, time.Now())
+	return session, nil
+}
+
+// EndCurrentImpersonation ends the session the given token belongs to.
+func (s *AuthService) EndCurrentImpersonation(meta RequestMeta, claims *Claims) (*models.ImpersonationSession, error) {
+	if claims.Act == nil || claims.ID == "" {
+		return nil, ErrNotImpersonating
+	}
+	meta.ActorID = claims.Act.Subject
+	return s.EndImpersonation(meta, claims.ID)
+}
+
+func (s *AuthService) ListImpersonations(targetUserID string, limit int) ([]models.ImpersonationSession, error) {
+	return s.repo.ListImpersonations(targetUserID, limit)
+}
@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrEmailMissing    = errors.New("identity provider did not return a verified email")
+)
+
+// ExternalIdentity is the normalised user info returned by an identity provider.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// AuthRequest carries the CSRF and replay protection values for one login attempt.
+type AuthRequest struct {
+	State    string
+	Verifier string
+	Nonce    string
+}
+
+// OAuthProvider is implemented by every login provider (GitHub, generic OIDC, ...).
+type OAuthProvider interface {
+	Name() string
+	AuthCodeURL(req AuthRequest) string
+	Exchange(ctx context.Context, code string, req AuthRequest) (*ExternalIdentity, error)
+}
+
+// ProviderRegistry holds the configured login providers keyed by name.
+type ProviderRegistry struct {
+	providers map[string]OAuthProvider
+}
+
+func NewProviderRegistry(providers ...OAuthProvider) *ProviderRegistry {
+	r := &ProviderRegistry{providers: make(map[string]OAuthProvider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+func (r *ProviderRegistry) Register(p OAuthProvider) {
+	if p == nil {
+		return
+	}
+	r.providers[strings.ToLower(p.Name())] = p
+}
+
+func (r *ProviderRegistry) Get(name string) (OAuthProvider, error) {
+	p, ok := r.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+func (r *ProviderRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GitHubProvider implements OAuthProvider on top of GitHub's OAuth apps.
+type GitHubProvider struct {
+	cfg     *oauth2.Config
+	apiBase string
+}
+
+func NewGitHubProvider(cfg *oauth2.Config) *GitHubProvider {
+	return &GitHubProvider{cfg: cfg, apiBase: "https://api.github.com"}
+}
+
+func (p *GitHubProvider) Name() string {
+	return "github"
+}
+
+func (p *GitHubProvider) AuthCodeURL(req AuthRequest) string {
+	return p.cfg.AuthCodeURL(req.State, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(req.Verifier))
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code string, req AuthRequest) (*ExternalIdentity, error) {
+	token, err := p.cfg.Exchange(ctx, code, oauth2.VerifierOption(req.Verifier))
+	if err != nil {
+		return nil, err
+	}
+	client := p.cfg.Client(ctx, token)
+
+	var ghUser struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, p.apiBase+"/user", &ghUser); err != nil {
+		return nil, err
+	}
+
+	// /user only exposes the public email, so ask for the verified primary one.
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, p.apiBase+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &ExternalIdentity{
+		Provider: p.Name(),
+		Subject:  fmt.Sprintf("%d", ghUser.ID),
+		Name:     ghUser.Name,
+	}
+	for _, e := range emails {
+		if e.Verified && (e.Primary || identity.Email == "") {
+			identity.Email = e.Email
+			identity.EmailVerified = true
+		}
+	}
+	if identity.Email == "" {
+		return nil, ErrEmailMissing
+	}
+	if identity.Name == "" {
+		identity.Name = ghUser.Login
+	}
+
+	return identity, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s responded %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: 10 * time.Second}
+}
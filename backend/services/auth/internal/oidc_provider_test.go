@@ -0,0 +1,339 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "cproom-test"
+
+// mockIdP is a minimal OpenID Connect issuer: discovery, JWKS, and a token
+// endpoint that enforces PKCE for codes handed out by authorize.
+type mockIdP struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	signKid string
+	grants  map[string]mockGrant
+	// badNonce, when set, replaces the nonce in issued id_tokens.
+	badNonce string
+	// issuer, when set, is advertised in discovery instead of the server URL.
+	issuer string
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	idp := &mockIdP{t: t, keys: map[string]*rsa.PrivateKey{}, grants: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+
+	idp.rotate()
+	return idp
+}
+
+// rotate replaces the signing key, dropping the old one from the JWKS.
+func (m *mockIdP) rotate() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signKid = fmt.Sprintf("key-%d", len(m.keys)+1)
+	m.keys = map[string]*rsa.PrivateKey{m.signKid: key}
+}
+
+func (m *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := m.srv.URL
+	if m.issuer != "" {
+		issuer = m.issuer
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": m.srv.URL + "/authorize",
+		"token_endpoint":         m.srv.URL + "/token",
+		"jwks_uri":               m.srv.URL + "/jwks",
+	})
+}
+
+func (m *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]map[string]string, 0, len(m.keys))
+	for kid, key := range m.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+}
+
+func (m *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := grant.nonce
+	if m.badNonce != "" {
+		nonce = m.badNonce
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     m.idToken(nonce),
+	})
+}
+
+func (m *mockIdP) idToken(nonce string) string {
+	m.mu.Lock()
+	kid, key := m.signKid, m.keys[m.signKid]
+	m.mu.Unlock()
+	return m.signIDToken(kid, key, nonce)
+}
+
+func (m *mockIdP) signIDToken(kid string, key *rsa.PrivateKey, nonce string) string {
+	now := time.Now()
+	claims := &idTokenClaims{
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		Name:          "Ada",
+		Nonce:         nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.srv.URL,
+			Subject:   "subject-1",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed
+}
+
+// authorize stands in for the user approving the login at the IdP: it records
+// the PKCE challenge and nonce from the authorization URL and returns a code.
+func (m *mockIdP) authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("authorization URL missing S256 challenge: %s", authURL)
+	}
+	code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+	m.mu.Lock()
+	m.grants[code] = mockGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockIdP) provider(t *testing.T) *OIDCProvider {
+	t.Helper()
+	p, err := NewOIDCProvider(context.Background(), config.OIDCProviderConfig{
+		Name:     "mock",
+		Issuer:   m.srv.URL,
+		ClientID: testClientID,
+		Scopes:   []string{"openid", "email"},
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+	return p
+}
+
+func newAuthRequest() AuthRequest {
+	return AuthRequest{State: "state-1", Verifier: strings.Repeat("v", 43), Nonce: "nonce-1"}
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider(t)
+
+	if p.jwksURI != idp.srv.URL+"/jwks" {
+		t.Errorf("jwks uri = %q", p.jwksURI)
+	}
+	authURL := p.AuthCodeURL(newAuthRequest())
+	if !strings.HasPrefix(authURL, idp.srv.URL+"/authorize?") {
+		t.Errorf("auth url = %q", authURL)
+	}
+
+	idp.issuer = "https://evil.example.com"
+	_, err := NewOIDCProvider(context.Background(), config.OIDCProviderConfig{
+		Name: "mock", Issuer: idp.srv.URL, ClientID: testClientID,
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected issuer mismatch error, got %v", err)
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider(t)
+
+	req := newAuthRequest()
+	identity, err := p.Exchange(context.Background(), idp.authorize(p.AuthCodeURL(req)), req)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "ada@example.com" || !identity.EmailVerified {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCJWKSRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider(t)
+	req := newAuthRequest()
+
+	if _, err := p.Exchange(context.Background(), idp.authorize(p.AuthCodeURL(req)), req); err != nil {
+		t.Fatalf("Exchange before rotation: %v", err)
+	}
+
+	idp.mu.Lock()
+	oldKid, oldKey := idp.signKid, idp.keys[idp.signKid]
+	idp.mu.Unlock()
+	idp.rotate()
+
+	// The new kid is not cached yet, so verification must refetch the JWKS.
+	if _, err := p.Exchange(context.Background(), idp.authorize(p.AuthCodeURL(req)), req); err != nil {
+		t.Fatalf("Exchange after rotation: %v", err)
+	}
+
+	// A token signed with the retired key must no longer verify.
+	if _, err := p.verifyIDToken(context.Background(), idp.signIDToken(oldKid, oldKey, req.Nonce), req.Nonce); err == nil {
+		t.Error("token signed with a rotated-out key was accepted")
+	}
+}
+
+func TestOIDCNonceMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.badNonce = "someone-elses-nonce"
+	p := idp.provider(t)
+
+	req := newAuthRequest()
+	_, err := p.Exchange(context.Background(), idp.authorize(p.AuthCodeURL(req)), req)
+	if err == nil || !strings.Contains(err.Error(), "nonce mismatch") {
+		t.Errorf("expected nonce mismatch, got %v", err)
+	}
+}
+
+func TestOIDCPKCEVerifierMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider(t)
+
+	req := newAuthRequest()
+	code := idp.authorize(p.AuthCodeURL(req))
+
+	req.Verifier = strings.Repeat("x", 43)
+	if _, err := p.Exchange(context.Background(), code, req); err == nil {
+		t.Error("exchange with the wrong PKCE verifier succeeded")
+	}
+}
+
+func TestCompleteOAuthRejectsBadState(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	idp := newMockIdP(t)
+	service := NewAuthService(nil, NewProviderRegistry(idp.provider(t)), nil)
+
+	_, stateToken, err := service.BeginOAuth("mock")
+	if err != nil {
+		t.Fatalf("BeginOAuth: %v", err)
+	}
+	valid, err := service.parseOAuthState("mock", stateToken)
+	if err != nil {
+		t.Fatalf("parseOAuthState: %v", err)
+	}
+
+	signState := func(secret string, expires time.Time) string {
+		claims := &oauthStateClaims{
+			Provider: "mock",
+			State:    valid.State,
+			Verifier: valid.Verifier,
+			Nonce:    valid.Nonce,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{oauthStateAudience},
+				IssuedAt:  jwt.NewNumericDate(expires.Add(-oauthStateTTL)),
+				ExpiresAt: jwt.NewNumericDate(expires),
+			},
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name       string
+		state      string
+		stateToken string
+	}{
+		{"missing cookie", valid.State, ""},
+		{"forged cookie", valid.State, signState("attacker-secret", time.Now().Add(time.Minute))},
+		{"expired cookie", valid.State, signState("test-secret", time.Now().Add(-time.Minute))},
+		{"state does not match cookie", "other-state", stateToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CompleteOAuth(context.Background(), "mock", tt.state, tt.stateToken, "code", "")
+			if !errors.Is(err, ErrInvalidOAuthState) {
+				t.Errorf("got %v, want ErrInvalidOAuthState", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/config"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPrivacyType = errors.New("type must be export or erasure")
+	ErrPrivacyRequestOpen = errors.New("a request of this type is already in progress")
+	ErrExportNotReady     = errors.New("export is not ready")
+	ErrEventsUnavailable  = errors.New("event bus is not available")
+)
+
+// RequestPrivacy starts a data subject request for userID and fans it out to
+// every service holding personal data. The auth service's own export is
+// taken immediately; its erasure runs once every other service confirmed.
+func (s *AuthService) RequestPrivacy(ctx context.Context, meta RequestMeta, userID, requestType string) (*models.DataSubjectRequest, error) {
+	if requestType != events.PrivacyExport && requestType != events.PrivacyErasure {
+		return nil, ErrInvalidPrivacyType
+	}
+	if s.events == nil {
+		return nil, ErrEventsUnavailable
+	}
+
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.OpenPrivacyRequest(userID, requestType); err == nil {
+		return nil, ErrPrivacyRequestOpen
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	req := &models.DataSubjectRequest{
+		UserID:      user.ID,
+		RequestedBy: meta.ActorID,
+		Type:        requestType,
+		Status:      models.PrivacyStatusPending,
+	}
+	for _, service := range config.PrivacyServices() {
+		req.Tasks = append(req.Tasks, models.DataSubjectTask{Service: service, Status: models.PrivacyStatusPending})
+	}
+	authTask := models.DataSubjectTask{Service: models.PrivacyServiceAuth, Status: models.PrivacyStatusPending}
+	if requestType == events.PrivacyExport {
+		data, err := s.exportAuthData(user)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		authTask.Status = models.PrivacyStatusCompleted
+		authTask.Data = data
+		authTask.CompletedAt = &now
+	}
+	req.Tasks = append(req.Tasks, authTask)
+
+	entry := &models.AuditLog{
+		ActorID:      meta.ActorID,
+		Action:       models.AuditPrivacyRequested,
+		TargetUserID: user.ID,
+		Details:      models.JSONMap{"type": requestType},
+	}
+	if err := s.audited(meta, entry, func(repo *AuthRepository) error {
+		if err := repo.CreatePrivacyRequest(req); err != nil {
+			return err
+		}
+		entry.Details["request_id"] = req.ID
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	s.dispatchPrivacyTasks(ctx, req, user)
+	return s.refreshPrivacyRequest(ctx, req.ID)
+}
+
+// RetryPrivacyRequest re-sends the request to services that have not
+// confirmed yet or reported a failure.
+func (s *AuthService) RetryPrivacyRequest(ctx context.Context, id string) (*models.DataSubjectRequest, error) {
+	if s.events == nil {
+		return nil, ErrEventsUnavailable
+	}
+
+	req, err := s.repo.FindPrivacyRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.FindByID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range req.Tasks {
+		task := &req.Tasks[i]
+		if task.Status == models.PrivacyStatusFailed {
+			task.Status = models.PrivacyStatusPending
+			task.Error = ""
+			if err := s.repo.SavePrivacyTask(task); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := s.repo.UpdatePrivacyRequestStatus(req.ID, models.PrivacyStatusPending, nil); err != nil {
+		return nil, err
+	}
+	req.Status = models.PrivacyStatusPending
+
+	s.dispatchPrivacyTasks(ctx, req, user)
+	return s.refreshPrivacyRequest(ctx, req.ID)
+}
+
+func (s *AuthService) dispatchPrivacyTasks(ctx context.Context, req *models.DataSubjectRequest, user *models.User) {
+	for i := range req.Tasks {
+		task := &req.Tasks[i]
+		if task.Service == models.PrivacyServiceAuth || task.Status != models.PrivacyStatusPending {
+			continue
+		}
+		msg := events.PrivacyRequest{
+			RequestID: req.ID,
+			Type:      req.Type,
+			UserID:    user.ID,
+			Email:     user.Email,
+		}
+		if err := s.events.PublishPrivacyRequest(ctx, task.Service, msg); err != nil {
+			log.Printf("privacy request %s: publish to %s failed: %v", req.ID, task.Service, err)
+			task.Status = models.PrivacyStatusFailed
+			task.Error = err.Error()
+			if err := s.repo.SavePrivacyTask(task); err != nil {
+				log.Printf("privacy request %s: save task %s: %v", req.ID, task.Service, err)
+			}
+		}
+	}
+}
+
+// HandlePrivacyResult records a service's confirmation and advances the request.
+func (s *AuthService) HandlePrivacyResult(ctx context.Context, res events.PrivacyResult) error {
+	req, err := s.repo.FindPrivacyRequest(res.RequestID)
+	if err != nil {
+		return fmt.Errorf("privacy result for unknown request %s: %w", res.RequestID, err)
+	}
+	if req.Status == models.PrivacyStatusCompleted {
+		return nil
+	}
+
+	var task *models.DataSubjectTask
+	for i := range req.Tasks {
+		if req.Tasks[i].Service == res.Service {
+			task = &req.Tasks[i]
+		}
+	}
+	if task == nil || task.Service == models.PrivacyServiceAuth {
+		return fmt.Errorf("privacy result for request %s from unexpected service %q", req.ID, res.Service)
+	}
+
+	now := time.Now()
+	if res.Success {
+		task.Status = models.PrivacyStatusCompleted
+		task.Error = ""
+		task.Data = models.JSONMap(res.Data)
+		task.CompletedAt = &now
+	} else {
+		task.Status = models.PrivacyStatusFailed
+		task.Error = res.Error
+	}
+	if err := s.repo.SavePrivacyTask(task); err != nil {
+		return err
+	}
+
+	_, err = s.refreshPrivacyRequest(ctx, req.ID)
+	return err
+}
+
+// refreshPrivacyRequest derives the request status from its tasks, running
+// the auth erasure last so the user stays resolvable while others work.
+func (s *AuthService) refreshPrivacyRequest(ctx context.Context, id string) (*models.DataSubjectRequest, error) {
+	req, err := s.repo.FindPrivacyRequest(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var authTask *models.DataSubjectTask
+	remotePending, failed := false, false
+	for i := range req.Tasks {
+		task := &req.Tasks[i]
+		switch {
+		case task.Service == models.PrivacyServiceAuth:
+			authTask = task
+		case task.Status == models.PrivacyStatusFailed:
+			failed = true
+		case task.Status != models.PrivacyStatusCompleted:
+			remotePending = true
+		}
+	}
+
+	status := models.PrivacyStatusPending
+	switch {
+	case failed:
+		status = models.PrivacyStatusFailed
+	case remotePending:
+	case authTask != nil && authTask.Status != models.PrivacyStatusCompleted:
+		if err := s.eraseAuthData(req); err != nil {
+			authTask.Status = models.PrivacyStatusFailed
+			authTask.Error = err.Error()
+			status = models.PrivacyStatusFailed
+		} else {
+			now := time.Now()
+			authTask.Status = models.PrivacyStatusCompleted
+			authTask.CompletedAt = &now
+			status = models.PrivacyStatusCompleted
+		}
+		if err := s.repo.SavePrivacyTask(authTask); err != nil {
+			return nil, err
+		}
+	default:
+		status = models.PrivacyStatusCompleted
+	}
+
+	if status != req.Status {
+		var completedAt *time.Time
+		if status == models.PrivacyStatusCompleted {
+			now := time.Now()
+			completedAt = &now
+		}
+		if err := s.repo.UpdatePrivacyRequestStatus(req.ID, status, completedAt); err != nil {
+			return nil, err
+		}
+		req.Status = status
+		req.CompletedAt = completedAt
+	}
+	return req, nil
+}
+
+func (s *AuthService) eraseAuthData(req *models.DataSubjectRequest) error {
+	entry := &models.AuditLog{
+		ActorID:      req.RequestedBy,
+		Action:       models.AuditUserErased,
+		TargetUserID: req.UserID,
+		Details:      models.JSONMap{"request_id": req.ID},
+	}
+	user, err := s.repo.FindByID(req.UserID)
+	if err != nil {
+		return err
+	}
+	placeholder := fmt.Sprintf("erased-%s@invalid.local", req.UserID)
+	now := time.Now()
+	if err := s.audited(RequestMeta{ActorID: req.RequestedBy}, entry, func(repo *AuthRepository) error {
+		return repo.EraseUser(req.UserID, user.Email, placeholder, now)
+	}); err != nil {
+		return err
+	}
+	s.publishSessionRevoked(req.UserID, events.SessionRevokedErased, now)
+	return nil
+}
+
+func (s *AuthService) exportAuthData(user *models.User) (models.JSONMap, error) {
+	identities, err := s.repo.ListIdentities(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.GroupsForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	impersonations, err := s.repo.ListImpersonations(user.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	auditLogs, err := s.repo.ListAuditLogsForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-trip through JSON so the stored map only holds plain values.
+	raw, err := json.Marshal(map[string]any{
+		"user": map[string]any{
+			"id":         user.ID,
+			"name":       user.Name,
+			"email":      user.Email,
+			"role":       user.Role,
+			"status":     user.Status,
+			"created_at": user.CreatedAt,
+			"updated_at": user.UpdatedAt,
+		},
+		"identities":     identities,
+		"groups":         groups,
+		"impersonations": impersonations,
+		"audit_logs":     auditLogs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var data models.JSONMap
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *AuthService) GetPrivacyRequest(id string) (*models.DataSubjectRequest, error) {
+	return s.repo.FindPrivacyRequest(id)
+}
+
+func (s *AuthService) ListPrivacyRequests(userID, status string) ([]models.DataSubjectRequest, error) {
+	return s.repo.ListPrivacyRequests(userID, status)
+}
+
+// ExportArchive builds a zip with one JSON file per service for a completed
+// export request.
+func (s *AuthService) ExportArchive(req *models.DataSubjectRequest) ([]byte, error) {
+	if req.Type != events.PrivacyExport || req.Status != models.PrivacyStatusCompleted {
+		return nil, ErrExportNotReady
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	manifest := map[string]any{
+		"request_id":   req.ID,
+		"user_id":      req.UserID,
+		"created_at":   req.CreatedAt,
+		"completed_at": req.CompletedAt,
+		"services":     req.Tasks,
+	}
+	if err := writeZipJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	for _, task := range req.Tasks {
+		if err := writeZipJSON(zw, task.Service+".json", task.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
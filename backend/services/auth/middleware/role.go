@@ -1,14 +1,78 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/internal"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
 	"github.com/gofiber/fiber/v2"
 )
 
-func AuthMiddleware(service *internal.AuthService, roles ...string) fiber.Handler {
+// Authenticator is the part of the auth service the middleware checks
+// credentials with. *internal.AuthService implements it.
+type Authenticator interface {
+	ParseJWT(token string) (*internal.Claims, error)
+	ValidateSession(claims *internal.Claims) error
+	AuthenticateAPIKey(key string) (*internal.ServiceIdentity, error)
+}
+
+func AuthMiddleware(service Authenticator, roles ...string) fiber.Handler {
+	return authenticate(service, func(_ *fiber.Ctx, claims *internal.Claims) bool {
+		if len(roles) == 0 {
+			return true
+		}
+		role := authz.NormalizeRole(claims.Role)
+		for _, r := range roles {
+			if authz.NormalizeRole(r) == role {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequirePermission authenticates the request and checks that the token
+// grants every one of the given permissions.
+func RequirePermission(service Authenticator, permissions ...string) fiber.Handler {
+	return authenticate(service, func(_ *fiber.Ctx, claims *internal.Claims) bool {
+		return claims.HasPermission(permissions...)
+	})
+}
+
+// RequireUserAccess guards routes addressing a single user by the :id param.
+// The caller must be that user, hold permission, or present a service account
+// API key with scope. Anything else is rejected, including requests without
+// credentials.
+func RequireUserAccess(service Authenticator, scope, permission string) fiber.Handler {
+	userAuth := authenticate(service, func(c *fiber.Ctx, claims *internal.Claims) bool {
+		return claims.Subject == c.Params("id") || claims.HasPermission(permission)
+	})
+
+	return func(c *fiber.Ctx) error {
+		key := internal.APIKeyFromRequest(c)
+		if key == "" {
+			return userAuth(c)
+		}
+
+		identity, err := service.AuthenticateAPIKey(key)
+		if err != nil {
+			if errors.Is(err, internal.ErrInvalidAPIKey) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid api key"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !identity.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "api key missing scope " + scope})
+		}
+
+		c.Locals("serviceAccount", identity.Name)
+		return c.Next()
+	}
+}
+
+func authenticate(service Authenticator, allow func(*fiber.Ctx, *internal.Claims) bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var tokenStr string
 
@@ -37,22 +101,26 @@ func AuthMiddleware(service *internal.AuthService, roles ...string) fiber.Handle
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid claims"})
 		}
 
-		if len(roles) > 0 {
-			allowed := false
-			for _, r := range roles {
-				if r == role {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
-				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+		if err := service.ValidateSession(claims); err != nil {
+			if errors.Is(err, internal.ErrTokenRevoked) || errors.Is(err, internal.ErrUserDeactivated) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
 			}
+			// Other services treat 401 as revoked, so keep lookup failures apart.
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if !allow(c, claims) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
 		}
 
 		c.Locals("email", email)
-		c.Locals("role", role)
+		c.Locals("role", authz.NormalizeRole(role))
+		c.Locals("permissions", authz.EffectivePermissions(role, claims.Permissions))
 		c.Locals("userID", claims.Subject)
+		c.Locals("claims", claims)
+		if claims.Act != nil {
+			c.Locals("impersonator", claims.Act)
+		}
 
 		return c.Next()
 	}
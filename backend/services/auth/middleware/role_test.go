@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/internal"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/auth/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ownerID       = "11111111-1111-1111-1111-111111111111"
+	otherID       = "22222222-2222-2222-2222-222222222222"
+	deactivatedID = "33333333-3333-3333-3333-333333333333"
+)
+
+// fakeAuth accepts the tokens and API keys it was given, standing in for the
+// user and key tables.
+type fakeAuth struct {
+	tokens      map[string]*internal.Claims
+	keys        map[string]*internal.ServiceIdentity
+	deactivated map[string]bool
+}
+
+func (f *fakeAuth) ParseJWT(token string) (*internal.Claims, error) {
+	claims, ok := f.tokens[token]
+	if !ok {
+		return nil, internal.ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+func (f *fakeAuth) ValidateSession(claims *internal.Claims) error {
+	if f.deactivated[claims.Subject] {
+		return internal.ErrUserDeactivated
+	}
+	return nil
+}
+
+func (f *fakeAuth) AuthenticateAPIKey(key string) (*internal.ServiceIdentity, error) {
+	identity, ok := f.keys[key]
+	if !ok {
+		return nil, internal.ErrInvalidAPIKey
+	}
+	return identity, nil
+}
+
+func userClaims(subject string, permissions ...string) *internal.Claims {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return &internal.Claims{
+		Email:            subject + "@example.com",
+		Role:             authz.RoleUser,
+		Permissions:      permissions,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+	}
+}
+
+func TestRequireUserAccess(t *testing.T) {
+	auth := &fakeAuth{
+		tokens: map[string]*internal.Claims{
+			"owner":       userClaims(ownerID),
+			"other":       userClaims(otherID),
+			"reader":      userClaims(otherID, authz.PermUserRead),
+			"manager":     userClaims(otherID, authz.PermUserManage),
+			"deactivated": userClaims(deactivatedID),
+		},
+		keys: map[string]*internal.ServiceIdentity{
+			"read-key":  {Name: "reader", Scopes: []string{authz.ScopeUsersRead}},
+			"write-key": {Name: "writer", Scopes: []string{authz.ScopeUsersWrite}},
+		},
+		deactivated: map[string]bool{deactivatedID: true},
+	}
+
+	// The /auth/users/:id routes as wired in cmd/server.go.
+	routes := []struct {
+		method     string
+		scope      string
+		permission string
+		holder     string // token holding permission
+		key        string // API key with scope
+		wrongKey   string // API key with another scope
+	}{
+		{http.MethodGet, authz.ScopeUsersRead, authz.PermUserRead, "reader", "read-key", "write-key"},
+		{http.MethodPut, authz.ScopeUsersWrite, authz.PermUserManage, "manager", "write-key", "read-key"},
+	}
+
+	for _, route := range routes {
+		app := fiber.New()
+		app.Add(route.method, "/auth/users/:id", RequireUserAccess(auth, route.scope, route.permission), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		cases := []struct {
+			name   string
+			target string
+			header string
+			value  string
+			want   int
+		}{
+			{"owner", ownerID, "Authorization", "Bearer owner", fiber.StatusOK},
+			{"owner by cookie", ownerID, "Cookie", models.TOKEN + "=owner", fiber.StatusOK},
+			{"other user", ownerID, "Authorization", "Bearer other", fiber.StatusForbidden},
+			{"permission holder", ownerID, "Authorization", "Bearer " + route.holder, fiber.StatusOK},
+			{"scoped api key", ownerID, "X-API-Key", route.key, fiber.StatusOK},
+			{"scoped api key in authorization", ownerID, "Authorization", "ApiKey " + route.key, fiber.StatusOK},
+			{"wrong scope", ownerID, "X-API-Key", route.wrongKey, fiber.StatusForbidden},
+			{"unknown api key", ownerID, "X-API-Key", "nope", fiber.StatusUnauthorized},
+			{"deactivated user", deactivatedID, "Authorization", "Bearer deactivated", fiber.StatusUnauthorized},
+			{"invalid token", ownerID, "Authorization", "Bearer forged", fiber.StatusUnauthorized},
+			{"anonymous", ownerID, "", "", fiber.StatusUnauthorized},
+		}
+		for _, tc := range cases {
+			t.Run(route.method+" "+tc.name, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, "/auth/users/"+tc.target, nil)
+				if tc.header != "" {
+					req.Header.Set(tc.header, tc.value)
+				}
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != tc.want {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tc.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	AuditUserDeactivated = "user.deactivated"
+	AuditUserReactivated = "user.reactivated"
+	AuditUserRoleChanged = "user.role_changed"
+	AuditUserUpdated     = "user.updated"
+	AuditAdminRegistered = "admin.registered"
+	AuditRoleSaved       = "role.saved"
+	AuditGroupSaved      = "group.saved"
+	AuditGroupDeleted    = "group.deleted"
+	AuditMemberAdded     = "group.member_added"
+	AuditMemberRemoved   = "group.member_removed"
+
+	AuditImpersonationStarted = "impersonation.started"
+	AuditImpersonationEnded   = "impersonation.ended"
+
+	AuditPrivacyRequested = "privacy.requested"
+	AuditUserErased       = "user.erased"
+
+	AuditUserRegistered  = "user.registered"
+	AuditLogin           = "login"
+	AuditOAuthCallback   = "oauth.callback"
+	AuditProfileUpdated  = "profile.updated"
+	AuditPasswordChanged = "password.changed"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// JSONMap persists a map as JSON in the database.
+type JSONMap map[string]any
+
+// Value converts the map to JSON bytes before storing.
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]any(m))
+}
+
+// Scan restores the map from JSON stored in the database.
+func (m *JSONMap) Scan(value any) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("models.JSONMap: unsupported scan type %T", value)
+	}
+
+	if len(data) == 0 {
+		*m = nil
+		return nil
+	}
+
+	return json.Unmarshal(data, m)
+}
+
+// AuditLog is an append-only record of an administrative change.
+type AuditLog struct {
+	ID           string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ActorID      string    `gorm:"size:100;index" json:"actor_id"`
+	Action       string    `gorm:"size:50;not null;index" json:"action"`
+	Outcome      string    `gorm:"size:20;not null;default:success;index" json:"outcome"`
+	TargetUserID string    `gorm:"size:100;index" json:"target_user_id,omitempty"`
+	IP           string    `gorm:"size:64;index" json:"ip,omitempty"`
+	UserAgent    string    `gorm:"size:512" json:"user_agent,omitempty"`
+	Details      JSONMap   `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "auth_audit_logs"
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	GroupKindDepartment = "department"
+	GroupKindTeam       = "team"
+)
+
+// Group is an organisational unit such as a department or a team. Groups can
+// be nested; members of a child group are implicitly members of its ancestors.
+type Group struct {
+	ID          string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:100;not null" json:"name"`
+	Kind        string    `gorm:"size:20;not null;default:team" json:"kind"`
+	Description string    `gorm:"size:255" json:"description"`
+	ParentID    *string   `gorm:"type:uuid;index" json:"parent_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// GroupMember is a direct membership of a user in a group.
+type GroupMember struct {
+	GroupID   string    `gorm:"type:uuid;primaryKey" json:"group_id"`
+	UserID    string    `gorm:"type:uuid;primaryKey;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID        string `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID    string `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider  string `gorm:"size:50;not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject   string `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	Email     string `gorm:"size:100" json:"email"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ImpersonationSession records an admin acting as another user. The session
+// ID is used as the token's jti so a session can be ended before it expires.
+type ImpersonationSession struct {
+	ID           string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ActorID      string     `gorm:"type:uuid;not null;index" json:"actor_id"`
+	TargetUserID string     `gorm:"type:uuid;not null;index" json:"target_user_id"`
+	Reason       string     `gorm:"type:text;not null" json:"reason"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	Actor  *User `gorm:"foreignKey:ActorID" json:"-"`
+	Target *User `gorm:"foreignKey:TargetUserID" json:"-"`
+}
+
+// Active reports whether the session can still be used at the given time.
+func (s *ImpersonationSession) Active(now time.Time) bool {
+	return s.EndedAt == nil && now.Before(s.ExpiresAt)
+}
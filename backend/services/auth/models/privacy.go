@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Data subject request lifecycle.
+const (
+	PrivacyStatusPending   = "pending"
+	PrivacyStatusCompleted = "completed"
+	PrivacyStatusFailed    = "failed"
+)
+
+// PrivacyServiceAuth is the task name for the auth service's own data.
+const PrivacyServiceAuth = "auth"
+
+// DataSubjectRequest tracks a personal data export or erasure across every
+// service that stores data about the user.
+type DataSubjectRequest struct {
+	ID          string            `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID      string            `gorm:"type:uuid;not null;index" json:"user_id"`
+	RequestedBy string            `gorm:"type:uuid" json:"requested_by"`
+	Type        string            `gorm:"size:20;not null" json:"type"`
+	Status      string            `gorm:"size:20;not null;default:pending;index" json:"status"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Tasks       []DataSubjectTask `gorm:"foreignKey:RequestID;constraint:OnDelete:CASCADE" json:"tasks"`
+}
+
+// DataSubjectTask is one service's share of a DataSubjectRequest. Data holds
+// the exported records for export requests.
+type DataSubjectTask struct {
+	ID          string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RequestID   string     `gorm:"type:uuid;not null;uniqueIndex:idx_privacy_task_service" json:"request_id"`
+	Service     string     `gorm:"size:50;not null;uniqueIndex:idx_privacy_task_service" json:"service"`
+	Status      string     `gorm:"size:20;not null;default:pending" json:"status"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	Data        JSONMap    `gorm:"type:jsonb" json:"-"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	Name        string           `gorm:"primaryKey;size:50" json:"name"`
+	Description string           `gorm:"size:255" json:"description"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleName;references:Name;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// RolePermission grants a single permission to a role.
+type RolePermission struct {
+	RoleName   string `gorm:"primaryKey;size:50" json:"role"`
+	Permission string `gorm:"primaryKey;size:100" json:"permission"`
+}
+
+// PermissionNames flattens the role's permissions into a string slice.
+func (r *Role) PermissionNames() []string {
+	out := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		out = append(out, p.Permission)
+	}
+	return out
+}
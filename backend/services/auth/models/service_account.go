@@ -0,0 +1,53 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// ServiceAccount is a named non-human identity used for service-to-service calls.
+type ServiceAccount struct {
+	ID          string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:100;not null" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Disabled    bool      `gorm:"not null;default:false" json:"disabled"`
+	Keys        []APIKey  `gorm:"foreignKey:ServiceAccountID;constraint:OnDelete:CASCADE" json:"keys,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// APIKey is a hashed credential belonging to a service account. The plaintext
+// key is only returned once, when the key is issued.
+type APIKey struct {
+	ID               string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ServiceAccountID string     `gorm:"type:uuid;not null;index" json:"service_account_id"`
+	Prefix           string     `gorm:"size:16;not null" json:"prefix"`
+	Hash             string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes           string     `gorm:"type:text;not null;default:''" json:"-"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// ScopeList returns the key's space separated scopes as a slice.
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// Active reports whether the key can still be used at the given time.
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// HashAPIKey returns the value stored for a plaintext API key. Keys are long
+// random strings, so a fast hash is sufficient and allows direct lookup.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,19 +1,34 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+)
 
 type User struct {
-	ID        string `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	Name      string `gorm:"size:100;not null" json:"name"`
-	Email     string `gorm:"uniqueIndex;size:100;not null" json:"email"`
-	Password  string `gorm:"not null" json:"-"`
-	Role      string `json:"role" gorm:"default:USER"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID       string `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Name     string `gorm:"size:100;not null" json:"name"`
+	Email    string `gorm:"uniqueIndex;size:100;not null" json:"email"`
+	Password string `gorm:"not null" json:"-"`
+	Role     string `json:"role" gorm:"default:USER"`
+	Status   string `gorm:"size:20;not null;default:active;index" json:"status"`
+	// Tokens issued before this instant are rejected (set on deactivation).
+	TokensRevokedAt *time.Time `json:"-"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 const (
 	TOKEN        = "AUTH_TOKEN"
-	USER  string = "USER"
-	ADMIN string = "ADMIN"
+	USER  string = authz.RoleUser
+	ADMIN string = authz.RoleAdmin
+
+	StatusActive      = "active"
+	StatusDeactivated = "deactivated"
 )
+
+// Active reports whether the user may sign in.
+func (u *User) Active() bool {
+	return u.Status == "" || u.Status == StatusActive
+}
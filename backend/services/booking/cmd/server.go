@@ -1,17 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"os"
 	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/config"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/internal"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/models"
 
-	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/proto"
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
 	"google.golang.org/grpc"
@@ -23,7 +25,10 @@ func main() {
 
 	// DB
 	db := config.ConnectDB()
-	db.AutoMigrate(&models.Booking{})
+	db.AutoMigrate(&models.Booking{}, &models.BookingEquipment{}, &models.WalkUpCode{})
+	if err := internal.InstallBookingChangeTrigger(db); err != nil {
+		log.Fatalf("booking-service: failed to install booking change trigger: %v", err)
+	}
 	config.SeedDefaultBookings(db)
 
 	// Layers
@@ -52,7 +57,18 @@ func main() {
 	}
 	defer publisher.Close()
 
-	service := internal.NewBookingService(repo, publisher)
+	rooms := internal.NewRoomClient()
+	service := internal.NewBookingService(repo, publisher, rooms)
+
+	go events.RunUntilDone(context.Background(), "booking-service: booking changes", service.WatchBookingChanges)
+
+	privacy := internal.NewPrivacyHandler(repo, rooms)
+	go events.RunUntilDone(context.Background(), "booking-service: session events", func(ctx context.Context) error {
+		return events.ConsumeSessionEvents(ctx, rabbitURL, "booking", authz.RevokeSessions)
+	})
+	go events.RunUntilDone(context.Background(), "booking-service: privacy worker", func(ctx context.Context) error {
+		return events.RunPrivacyWorker(ctx, rabbitURL, "booking", privacy)
+	})
 	handler := internal.NewBookingHandler(service)
 
 	httpPort := os.Getenv("BOOKING_HTTP_PORT")
@@ -62,14 +78,24 @@ func main() {
 
 	go func() {
 		app := fiber.New()
+		app.Use(authz.SessionCheck("booking-service"))
+		app.Use(internal.ImpersonationContext())
 		app.Get("/rooms/search", handler.SearchRooms)
 		app.Get("/bookings/mine", handler.ListUserBookings)
+		app.Get("/bookings/equipment/availability", handler.EquipmentAvailability)
 		app.Post("/bookings", handler.CreateBooking)
+		app.Post("/bookings/scan", handler.ScanRoomCode)
 		app.Post("/bookings/:id/cancel", handler.CancelBooking)
 		app.Put("/bookings/:id", handler.UpdateBooking)
 		app.Post("/bookings/:id/transfer", handler.TransferBooking)
 		app.Get("/admin/rooms/:id/bookings", handler.GetAdminRoomBookings)
 
+		kiosk := app.Group("/kiosk", handler.KioskAccess)
+		kiosk.Get("/status", handler.GetKioskStatus)
+		kiosk.Post("/bookings/code", handler.RequestWalkUpCode)
+		kiosk.Post("/bookings", handler.CreateWalkUpBooking)
+		kiosk.Post("/check-in", handler.CheckInKiosk)
+
 		log.Printf("Booking HTTP server running on :%s", httpPort)
 		if err := app.Listen(":" + httpPort); err != nil {
 			log.Fatalf("failed to start booking HTTP server: %v", err)
@@ -6,9 +6,11 @@ toolchain go1.24.7
 
 require (
 	github.com/JJnvn/Software-Arch-CPRoom/backend v0.0.0
+	github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto v0.0.0
 	github.com/gofiber/fiber/v2 v2.52.9
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/uuid v1.6.0
+	github.com/jackc/pgx/v5 v5.6.0
 	github.com/joho/godotenv v1.5.1
 	google.golang.org/grpc v1.75.1
 	google.golang.org/protobuf v1.36.9
@@ -20,7 +22,6 @@ require (
 	github.com/andybalholm/brotli v1.1.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
-	github.com/jackc/pgx/v5 v5.6.0 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
@@ -42,3 +43,5 @@ require (
 )
 
 replace github.com/JJnvn/Software-Arch-CPRoom/backend => ../../
+
+replace github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto => ../../libs/proto
@@ -1,12 +1,16 @@
 package internal
 
 import (
+	"errors"
+	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/proto"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/schedule"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -68,12 +72,30 @@ func (h *BookingHandler) SearchRooms(c *fiber.Ctx) error {
 		pageSize = value
 	}
 
+	var floors []int32
+	for _, floorStr := range strings.Split(c.Query("floor"), ",") {
+		if floorStr = strings.TrimSpace(floorStr); floorStr == "" {
+			continue
+		}
+		value, err := strconv.Atoi(floorStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "floor must be a number or comma-separated numbers"})
+		}
+		floors = append(floors, int32(value))
+	}
+
 	req := &pb.SearchRoomsRequest{
-		Start:    timestamppb.New(start),
-		End:      timestamppb.New(end),
-		Capacity: int32(capacity),
-		Page:     int32(page),
-		PageSize: int32(pageSize),
+		Start:          timestamppb.New(start),
+		End:            timestamppb.New(end),
+		Capacity:       int32(capacity),
+		Page:           int32(page),
+		PageSize:       int32(pageSize),
+		Building:       strings.TrimSpace(c.Query("building")),
+		Floors:         floors,
+		AccessibleOnly: c.QueryBool("accessible"),
+		SortBy:         c.Query("sort"),
+		Descending:     strings.EqualFold(c.Query("order"), "desc"),
+		Layout:         strings.TrimSpace(c.Query("layout")),
 	}
 
 	resp, err := h.service.SearchRooms(c.Context(), req)
@@ -85,11 +107,18 @@ func (h *BookingHandler) SearchRooms(c *fiber.Ctx) error {
 }
 
 func (h *BookingHandler) CreateBooking(c *fiber.Ctx) error {
+	type addOn struct {
+		EquipmentID string `json:"equipment_id"`
+		Quantity    int32  `json:"quantity"`
+	}
 	type request struct {
-		UserID string `json:"user_id"`
-		RoomID string `json:"room_id"`
-		Start  string `json:"start_time"`
-		End    string `json:"end_time"`
+		UserID    string  `json:"user_id"`
+		RoomID    string  `json:"room_id"`
+		Start     string  `json:"start_time"`
+		End       string  `json:"end_time"`
+		Equipment []addOn `json:"equipment"`
+		Layout    string  `json:"layout"`
+		Title     string  `json:"title"`
 	}
 
 	var req request
@@ -112,9 +141,14 @@ func (h *BookingHandler) CreateBooking(c *fiber.Ctx) error {
 		RoomId: req.RoomID,
 		Start:  timestamppb.New(start),
 		End:    timestamppb.New(end),
+		Layout: req.Layout,
+		Title:  req.Title,
+	}
+	for _, item := range req.Equipment {
+		grpcReq.Equipment = append(grpcReq.Equipment, &pb.EquipmentAddOn{EquipmentId: item.EquipmentID, Quantity: item.Quantity})
 	}
 
-	resp, err := h.service.CreateBooking(c.Context(), grpcReq)
+	resp, err := h.service.CreateBooking(c.UserContext(), grpcReq)
 	if err != nil {
 		return translateGRPCError(c, err)
 	}
@@ -122,6 +156,30 @@ func (h *BookingHandler) CreateBooking(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
+// EquipmentAvailability lists movable equipment with the units free between
+// the start and end query params, for picking booking add-ons.
+func (h *BookingHandler) EquipmentAvailability(c *fiber.Ctx) error {
+	start, err := time.Parse(time.RFC3339, c.Query("start"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid start time format"})
+	}
+
+	end, err := time.Parse(time.RFC3339, c.Query("end"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid end time format"})
+	}
+
+	if !start.Before(end) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "start time must be before end time"})
+	}
+
+	items, err := h.service.EquipmentAvailability(c.Context(), start, end)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"equipment": items})
+}
+
 func (h *BookingHandler) ListUserBookings(c *fiber.Ctx) error {
 	claims, err := parseJWTClaims(c)
 	if err != nil {
@@ -139,22 +197,29 @@ func (h *BookingHandler) ListUserBookings(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	response := make([]fiber.Map, len(bookings))
+	roomIDs := make([]uuid.UUID, len(bookings))
 	for i, booking := range bookings {
-		// Fetch room name from repository
-		roomName, err := h.service.repo.GetRoomName(booking.RoomID)
-		if err != nil {
-			roomName = "" // fallback to empty string if room not found
-		}
+		roomIDs[i] = booking.RoomID
+	}
+	// Names are best effort: a room the room service cannot name is left blank.
+	roomNames, err := h.service.rooms.RoomNames(c.Context(), roomIDs)
+	if err != nil {
+		log.Printf("failed to fetch room names: %v", err)
+	}
 
+	response := make([]fiber.Map, len(bookings))
+	for i, booking := range bookings {
 		response[i] = fiber.Map{
 			"booking_id": booking.ID.String(),
 			"user_id":    booking.UserID.String(),
 			"room_id":    booking.RoomID.String(),
-			"room_name":  roomName,
+			"room_name":  roomNames[booking.RoomID],
 			"start_time": booking.StartTime,
 			"end_time":   booking.EndTime,
 			"status":     booking.Status,
+			"layout":     booking.Layout,
+			"title":      booking.Title,
+			"equipment":  booking.Equipment,
 			"created_at": booking.CreatedAt,
 			"updated_at": booking.UpdatedAt,
 		}
@@ -175,7 +240,7 @@ func (h *BookingHandler) CancelBooking(c *fiber.Ctx) error {
 	}
 
 	req := &pb.CancelBookingRequest{BookingId: bookingID}
-	resp, err := h.service.CancelBooking(c.Context(), req)
+	resp, err := h.service.CancelBooking(c.UserContext(), req)
 	if err != nil {
 		return translateGRPCError(c, err)
 	}
@@ -269,7 +334,7 @@ func (h *BookingHandler) TransferBooking(c *fiber.Ctx) error {
 	booking.UserID = newUserID
 
 	// Publish booking transferred event to notify the new owner
-	go h.service.publishBookingEvent(c.Context(), booking, "booking.transferred", map[string]any{
+	go h.service.publishBookingEvent(c.UserContext(), booking, "booking.transferred", map[string]any{
 		"new_owner_email": req.NewUserEmail,
 		"transferred_at":  time.Now().UTC(),
 	})
@@ -328,6 +393,10 @@ func (h *BookingHandler) GetRoomSchedule(c *fiber.Ctx) error {
 }
 
 func (h *BookingHandler) GetAdminRoomBookings(c *fiber.Ctx) error {
+	if _, err := requirePermission(c, authz.PermBookingManage); err != nil {
+		return respondError(c, err)
+	}
+
 	roomID := c.Params("id")
 	if roomID == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "room ID is required"})
@@ -360,6 +429,174 @@ func (h *BookingHandler) GetAdminRoomBookings(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
+// kioskLocal is the fiber local KioskAccess stores the kiosk in.
+const kioskLocal = "kiosk"
+
+// maxKioskWait caps how long one long poll is held open.
+const maxKioskWait = 30 * time.Second
+
+// KioskAccess authenticates a room kiosk by the key sent as X-API-Key or
+// "Authorization: ApiKey <key>". Kiosk routes only ever act on that room.
+func (h *BookingHandler) KioskAccess(c *fiber.Ctx) error {
+	key := strings.TrimSpace(c.Get("X-API-Key"))
+	if auth := c.Get(fiber.HeaderAuthorization); key == "" && strings.HasPrefix(strings.ToLower(auth), "apikey ") {
+		key = strings.TrimSpace(auth[len("apikey "):])
+	}
+
+	kiosk, err := h.service.AuthenticateKiosk(key)
+	if err != nil {
+		return respondOnSiteError(c, err)
+	}
+	c.Locals(kioskLocal, kiosk)
+	return c.Next()
+}
+
+// GetKioskStatus returns the current/next view of the kiosk's room. With
+// wait=<seconds> and the last version seen, in If-None-Match or ?version=, it
+// long-polls: the response is held until the view changes or wait runs out,
+// then 304 is returned. The view is only recomputed when bookings change or
+// the time comes for it to change by itself; changes the room service makes,
+// such as new maintenance windows, show on the next poll.
+func (h *BookingHandler) GetKioskStatus(c *fiber.Ctx) error {
+	kiosk := c.Locals(kioskLocal).(*Kiosk)
+
+	known := strings.Trim(c.Get(fiber.HeaderIfNoneMatch), `"`)
+	if known == "" {
+		known = c.Query("version")
+	}
+	wait := time.Duration(c.QueryInt("wait")) * time.Second
+	if wait < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "wait must be a number of seconds"})
+	}
+	deadline := time.After(min(wait, maxKioskWait))
+
+	for {
+		// Taken before reading, so a change made meanwhile still wakes us.
+		changed := h.service.BookingChanges()
+		now := time.Now()
+		view, err := h.service.KioskStatus(c.UserContext(), kiosk, now)
+		if err != nil {
+			return respondOnSiteError(c, err)
+		}
+		c.Set(fiber.HeaderETag, `"`+view.Version+`"`)
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		if view.Version != known {
+			return c.JSON(view)
+		}
+		if wait == 0 {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+
+		var due <-chan time.Time
+		if at := view.changesAt(now); !at.IsZero() {
+			due = time.After(at.Sub(now))
+		}
+		select {
+		case <-changed:
+		case <-due:
+		case <-deadline:
+			return c.SendStatus(fiber.StatusNotModified)
+		case <-c.UserContext().Done():
+			return c.UserContext().Err()
+		case <-c.Context().Done():
+			return c.Context().Err()
+		}
+	}
+}
+
+// RequestWalkUpCode sends a one-time code to the user with the given email
+// for them to enter at the kiosk. The answer is the same whether or not the
+// email belongs to anyone.
+func (h *BookingHandler) RequestWalkUpCode(c *fiber.Ctx) error {
+	kiosk := c.Locals(kioskLocal).(*Kiosk)
+
+	var req WalkUpCodeInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	if err := h.service.RequestWalkUpCode(c.UserContext(), kiosk, req); err != nil {
+		return respondOnSiteError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "if the email belongs to a user, a code has been sent to them"})
+}
+
+// CreateWalkUpBooking requests the kiosk's room from now for the user with
+// the given email and walk-up code. The booking awaits approval like any
+// other.
+func (h *BookingHandler) CreateWalkUpBooking(c *fiber.Ctx) error {
+	kiosk := c.Locals(kioskLocal).(*Kiosk)
+
+	var req WalkUpInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	booking, err := h.service.WalkUpBooking(c.UserContext(), kiosk, req)
+	if err != nil {
+		return respondOnSiteError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(kioskBooking(booking))
+}
+
+// CheckInKiosk checks in the booking of the kiosk's room that is running or
+// about to start.
+func (h *BookingHandler) CheckInKiosk(c *fiber.Ctx) error {
+	kiosk := c.Locals(kioskLocal).(*Kiosk)
+
+	booking, err := h.service.CheckIn(c.UserContext(), kiosk)
+	if err != nil {
+		return respondOnSiteError(c, err)
+	}
+	return c.JSON(kioskBooking(booking))
+}
+
+// ScanRoomCode handles a scanned room QR code: the caller's booking of the
+// room is checked in or, if they have none, the room is requested for them.
+func (h *BookingHandler) ScanRoomCode(c *fiber.Ctx) error {
+	claims, err := requirePermission(c, authz.PermBookingCreate)
+	if err != nil {
+		return respondError(c, err)
+	}
+	userID, err := uuid.Parse(strings.TrimSpace(claims.Subject))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token subject"})
+	}
+
+	var req ScanInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	result, err := h.service.ScanRoom(c.UserContext(), c.Get(fiber.HeaderAuthorization), userID, req)
+	if err != nil {
+		return respondOnSiteError(c, err)
+	}
+	if result.Action == ScanRequested {
+		return c.Status(fiber.StatusCreated).JSON(result)
+	}
+	return c.JSON(result)
+}
+
+// respondOnSiteError maps errors of kiosk and room QR code requests.
+func respondOnSiteError(c *fiber.Ctx, err error) error {
+	var closed *schedule.ClosedError
+	switch {
+	case errors.Is(err, ErrInvalidKioskKey):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidRoomCode), errors.Is(err, ErrInvalidWalkUpCode):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidWalkUp):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrRoomNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.As(err, &closed), errors.Is(err, ErrTimeSlotUnavailable), errors.Is(err, ErrRoomUnderMaintenance),
+		errors.Is(err, ErrRoomArchived), errors.Is(err, ErrNothingToCheck):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
 func translateGRPCError(c *fiber.Ctx, err error) error {
 	st, ok := status.FromError(err)
 	if !ok {
@@ -384,11 +621,43 @@ func translateGRPCError(c *fiber.Ctx, err error) error {
 }
 
 type jwtClaims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	Email       string       `json:"email"`
+	Role        string       `json:"role"`
+	Permissions []string     `json:"permissions,omitempty"`
+	Groups      []string     `json:"groups,omitempty"`
+	Act         *authz.Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+func requirePermission(c *fiber.Ctx, permissions ...string) (*jwtClaims, error) {
+	claims, err := parseJWTClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if !authz.HasPermission(claims.Role, claims.Permissions, permissions...) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "missing permission "+strings.Join(permissions, ", "))
+	}
+	return claims, nil
+}
+
+// ImpersonationContext records the "act" claim of impersonation tokens on the
+// request context so changes are attributed to both the user and the admin.
+func ImpersonationContext() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" {
+			return c.Next()
+		}
+		claims, err := parseJWTClaims(c)
+		if err != nil || claims.Act == nil {
+			return c.Next()
+		}
+
+		c.SetUserContext(authz.WithActor(c.UserContext(), claims.Act))
+		log.Printf("%s %s by %s on behalf of %s", c.Method(), c.Path(), claims.Act.Subject, claims.Subject)
+		return c.Next()
+	}
+}
+
 func parseJWTClaims(c *fiber.Ctx) (*jwtClaims, error) {
 	authHeader := strings.TrimSpace(c.Get("Authorization"))
 	if authHeader == "" {
@@ -433,6 +702,9 @@ func parseJWTClaims(c *fiber.Ctx) (*jwtClaims, error) {
 		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token issuer")
 	}
 
+	if err := authz.CheckSession(c.UserContext(), "booking-service", tokenString, claims.Subject); err != nil {
+		return nil, err
+	}
 	return claims, nil
 }
 
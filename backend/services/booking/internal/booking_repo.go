@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"time"
@@ -16,31 +17,112 @@ var (
 	ErrTimeSlotUnavailable = errors.New("room is not available for the requested time window")
 	ErrRoomNotFound        = errors.New("room not found")
 	ErrUserNotFound        = errors.New("user not found")
+	// ErrRoomUnderMaintenance is returned when the window overlaps a maintenance window of the room.
+	ErrRoomUnderMaintenance = errors.New("room is closed for maintenance during the requested time window")
+	// ErrRoomArchived is returned when booking a room that has been archived.
+	ErrRoomArchived = errors.New("room has been archived and can no longer be booked")
+	// ErrLayoutNotFound is returned when the room has no layout of the requested name.
+	ErrLayoutNotFound = errors.New("room has no layout with this name")
 )
 
+// sharedSpaceRooms selects the rooms sharing floor space with the room given
+// by the SQL expression room: the room itself, its parts if it is a combined
+// room, and every combined room containing it or one of its parts. Booking
+// any of them blocks the others. The room API does not expose combinations
+// yet, so this still reads the room service's table inside booking queries.
+func sharedSpaceRooms(room string) string {
+	return "SELECT " + room +
+		" UNION SELECT part_room_id FROM room_combinations WHERE combined_room_id = " + room +
+		" UNION SELECT combined_room_id FROM room_combinations WHERE part_room_id = " + room +
+		" UNION SELECT shared.combined_room_id FROM room_combinations parts" +
+		" JOIN room_combinations shared ON shared.part_room_id = parts.part_room_id" +
+		" WHERE parts.combined_room_id = " + room
+}
+
+// inSharedSpace matches rows whose column names a room sharing floor space
+// with roomID.
+func inSharedSpace(column string, roomID uuid.UUID) clause.Expression {
+	return clause.NamedExpr{
+		SQL:  column + " IN (" + sharedSpaceRooms("CAST(@room AS uuid)") + ")",
+		Vars: []any{sql.Named("room", roomID)},
+	}
+}
+
+// occupiedOverlap matches bookings whose window, widened by their setup and
+// teardown time, overlaps a span. Bind the span's end, then its start.
+const occupiedOverlap = "bookings.start_time - bookings.setup_minutes * interval '1 minute' < ? AND " +
+	"bookings.end_time + bookings.teardown_minutes * interval '1 minute' > ?"
+
 type BookingRepository struct {
 	db *gorm.DB
 }
 
 type RoomSearchResult struct {
-	ID       uuid.UUID
-	Name     string
-	Capacity int
-	Features []string
+	ID          uuid.UUID
+	Name        string
+	Capacity    int
+	Features    []string
+	Building    string
+	Floor       int
+	RoomNumber  string
+	Latitude    *float64
+	Longitude   *float64
+	Accessible  bool
+	TimeZone    string
+	Description string
+
+	// Set when searching for a layout; Capacity is then the layout's.
+	Layout          string
+	SetupMinutes    int
+	TeardownMinutes int
+}
+
+// RoomLayout is a named layout of a room, owned by the room service.
+type RoomLayout struct {
+	Name            string
+	Capacity        int
+	SetupMinutes    int
+	TeardownMinutes int
+}
+
+// RoomSearchFilter holds the optional room attributes SearchAvailableRooms
+// filters and sorts on.
+type RoomSearchFilter struct {
+	Capacity       int
+	Building       string
+	Floors         []int
+	AccessibleOnly bool
+	SortBy         string
+	Descending     bool
+	Layout         string
+	// Offset and Limit page through the sorted rooms; a zero Limit returns
+	// them all.
+	Offset int
+	Limit  int
+}
+
+// roomSearchOrder maps the accepted sort keys to ORDER BY columns on rooms.
+var roomSearchOrder = map[string][]string{
+	"capacity": {"capacity", "name"},
+	"name":     {"name"},
+	"building": {"building", "floor", "room_number", "name"},
+	"floor":    {"floor", "building", "room_number", "name"},
 }
 
 func NewBookingRepository(db *gorm.DB) *BookingRepository {
 	return &BookingRepository{db: db}
 }
 
+// Create saves a booking unless a confirmed booking of a room sharing its
+// floor space overlaps it.
 func (r *BookingRepository) Create(b *models.Booking) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		var roomCount int64
-		if err := tx.Table("rooms").Where("id = ?", b.RoomID).Count(&roomCount).Error; err != nil {
+		if err := checkRoomActive(tx, b.RoomID); err != nil {
 			return err
 		}
-		if roomCount == 0 {
-			return ErrRoomNotFound
+
+		if err := checkMaintenance(tx, b.RoomID, b.OccupiedFrom(), b.OccupiedUntil()); err != nil {
+			return err
 		}
 
 		var userCount int64
@@ -53,9 +135,9 @@ func (r *BookingRepository) Create(b *models.Booking) error {
 
 		var count int64
 		if err := tx.Model(&models.Booking{}).
-			Where("room_id = ?", b.RoomID).
+			Where(inSharedSpace("room_id", b.RoomID)).
 			Where("status = ?", models.StatusConfirmed).
-			Where("start_time < ? AND end_time > ?", b.EndTime, b.StartTime).
+			Where(occupiedOverlap, b.OccupiedUntil(), b.OccupiedFrom()).
 			Count(&count).Error; err != nil {
 			return err
 		}
@@ -64,10 +146,33 @@ func (r *BookingRepository) Create(b *models.Booking) error {
 			return ErrTimeSlotUnavailable
 		}
 
+		if err := checkEquipment(tx, b.ID, b.Equipment, b.OccupiedFrom(), b.OccupiedUntil()); err != nil {
+			return err
+		}
+
 		return tx.Create(b).Error
 	})
 }
 
+// checkRoomActive fails with ErrRoomNotFound or ErrRoomArchived unless the
+// room exists and is not archived.
+func checkRoomActive(tx *gorm.DB, roomID uuid.UUID) error {
+	var room struct {
+		ArchivedAt *time.Time
+	}
+	result := tx.Table("rooms").Select("archived_at").Where("id = ?", roomID).Scan(&room)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoomNotFound
+	}
+	if room.ArchivedAt != nil {
+		return ErrRoomArchived
+	}
+	return nil
+}
+
 func (r *BookingRepository) FindByID(id uuid.UUID) (*models.Booking, error) {
 	var booking models.Booking
 	if err := r.db.First(&booking, "id = ?", id).Error; err != nil {
@@ -96,12 +201,15 @@ func (r *BookingRepository) UpdateBookingTimes(id uuid.UUID, newStart, newEnd ti
 			return err
 		}
 
+		booking.StartTime = newStart
+		booking.EndTime = newEnd
+
 		var count int64
 		if err := tx.Model(&models.Booking{}).
-			Where("room_id = ?", booking.RoomID).
+			Where(inSharedSpace("room_id", booking.RoomID)).
 			Where("status = ?", models.StatusConfirmed).
 			Where("id <> ?", booking.ID).
-			Where("start_time < ? AND end_time > ?", newEnd, newStart).
+			Where(occupiedOverlap, booking.OccupiedUntil(), booking.OccupiedFrom()).
 			Count(&count).Error; err != nil {
 			return err
 		}
@@ -110,8 +218,21 @@ func (r *BookingRepository) UpdateBookingTimes(id uuid.UUID, newStart, newEnd ti
 			return ErrTimeSlotUnavailable
 		}
 
-		booking.StartTime = newStart
-		booking.EndTime = newEnd
+		if err := checkRoomActive(tx, booking.RoomID); err != nil {
+			return err
+		}
+		if err := checkMaintenance(tx, booking.RoomID, booking.OccupiedFrom(), booking.OccupiedUntil()); err != nil {
+			return err
+		}
+
+		var addOns []models.BookingEquipment
+		if err := tx.Where("booking_id = ?", booking.ID).Find(&addOns).Error; err != nil {
+			return err
+		}
+		if err := checkEquipment(tx, booking.ID, addOns, booking.OccupiedFrom(), booking.OccupiedUntil()); err != nil {
+			return err
+		}
+
 		return tx.Save(&booking).Error
 	})
 }
@@ -150,7 +271,8 @@ func (r *BookingRepository) GetRoomSchedule(roomID uuid.UUID, date time.Time) ([
 
 func (r *BookingRepository) ListByUser(userID uuid.UUID) ([]models.Booking, error) {
 	var bookings []models.Booking
-	err := r.db.Where("user_id = ?", userID).
+	err := r.db.Preload("Equipment").
+		Where("user_id = ?", userID).
 		Order("start_time DESC").
 		Find(&bookings).Error
 	return bookings, err
@@ -182,18 +304,6 @@ func (r *BookingRepository) GetRoomBookingsByDate(roomID uuid.UUID, startOfDay,
 	return bookings, err
 }
 
-func (r *BookingRepository) GetRoomName(roomID uuid.UUID) (string, error) {
-	var roomName string
-	err := r.db.Table("rooms").
-		Select("name").
-		Where("id = ?", roomID).
-		Scan(&roomName).Error
-	if err != nil {
-		return "", err
-	}
-	return roomName, nil
-}
-
 func (r *BookingRepository) GetUserIDByEmail(email string) (uuid.UUID, error) {
 	var userID string
 
@@ -210,39 +320,98 @@ func (r *BookingRepository) GetUserIDByEmail(email string) (uuid.UUID, error) {
 	return uuid.Parse(userID)
 }
 
-func (r *BookingRepository) SearchAvailableRooms(start, end time.Time, capacity, page, pageSize int) ([]RoomSearchResult, error) {
-	if page <= 0 {
-		page = 1
-	}
-	if pageSize <= 0 || pageSize > 100 {
-		pageSize = 10
-	}
-
+// SearchAvailableRooms returns the rooms matching filter with no confirmed
+// booking overlapping [start, end) in it or a room sharing its floor space, in
+// sort order. With a layout, only rooms
+// offering it match, its capacity is used, and its setup and teardown time
+// must be free too. Opening hours are left to the caller, which reads the
+// rooms a batch at a time through Offset and Limit.
+func (r *BookingRepository) SearchAvailableRooms(start, end time.Time, filter RoomSearchFilter) ([]RoomSearchResult, error) {
 	query := r.db.Table("rooms").
-		Select("rooms.id, rooms.name, rooms.capacity, rooms.features")
+		Select("rooms.id, rooms.name, rooms.capacity, rooms.features, rooms.building, rooms.floor, rooms.room_number, " +
+			"rooms.latitude, rooms.longitude, rooms.accessible, rooms.time_zone, rooms.description").
+		Where("rooms.archived_at IS NULL")
+
+	capacityTable, setup, teardown := "rooms", "0", "0"
+	if filter.Layout != "" {
+		capacityTable, setup, teardown = "room_layouts", "room_layouts.setup_minutes", "room_layouts.teardown_minutes"
+		query = query.
+			Select("rooms.id, rooms.name, room_layouts.capacity, rooms.features, rooms.building, rooms.floor, rooms.room_number, "+
+				"rooms.latitude, rooms.longitude, rooms.accessible, rooms.time_zone, rooms.description, "+
+				"room_layouts.name AS layout, room_layouts.setup_minutes, room_layouts.teardown_minutes").
+			Joins("JOIN room_layouts ON room_layouts.room_id = rooms.id AND lower(room_layouts.name) = lower(?)", filter.Layout)
+	}
 
-	if capacity > 0 {
-		query = query.Where("rooms.capacity >= ?", capacity)
+	if filter.Capacity > 0 {
+		query = query.Where(capacityTable+".capacity >= ?", filter.Capacity)
+	}
+	if filter.Building != "" {
+		query = query.Where("rooms.building = ?", filter.Building)
+	}
+	if len(filter.Floors) > 0 {
+		query = query.Where("rooms.floor IN ?", filter.Floors)
+	}
+	if filter.AccessibleOnly {
+		query = query.Where("rooms.accessible = ?", true)
 	}
 
 	if !start.IsZero() && !end.IsZero() {
+		// The span the room would be blocked for, widened by the layout's buffers.
+		until := "CAST(? AS timestamptz) + " + teardown + " * interval '1 minute'"
+		from := "CAST(? AS timestamptz) - " + setup + " * interval '1 minute'"
+
 		subQuery := r.db.Table("bookings").
 			Select("1").
-			Where("bookings.room_id = rooms.id").
+			Where("bookings.room_id IN ("+sharedSpaceRooms("rooms.id")+")").
 			Where("bookings.status = ?", models.StatusConfirmed).
-			Where("bookings.start_time < ? AND bookings.end_time > ?", end, start)
+			Where("bookings.start_time - bookings.setup_minutes * interval '1 minute' < "+until+
+				" AND bookings.end_time + bookings.teardown_minutes * interval '1 minute' > "+from, end, start)
 		query = query.Where("NOT EXISTS (?)", subQuery)
+
+		maintenance := r.db.Table("room_maintenance_windows").
+			Select("1").
+			Where("room_maintenance_windows.room_id IN ("+sharedSpaceRooms("rooms.id")+")").
+			Where("room_maintenance_windows.starts_at < "+until+" AND room_maintenance_windows.ends_at > "+from, end, start)
+		query = query.Where("NOT EXISTS (?)", maintenance)
 	}
 
-	query = query.Order("rooms.capacity ASC, rooms.name ASC").
-		Offset((page - 1) * pageSize).
-		Limit(pageSize)
+	columns, ok := roomSearchOrder[filter.SortBy]
+	if !ok {
+		columns = roomSearchOrder["capacity"]
+	}
+	for i, column := range columns {
+		table := "rooms"
+		if column == "capacity" {
+			table = capacityTable
+		}
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: table, Name: column},
+			Desc:   filter.Descending && i == 0,
+		})
+	}
+	// A unique last key keeps batches from overlapping.
+	query = query.Order("rooms.id")
+	if filter.Limit > 0 {
+		query = query.Offset(filter.Offset).Limit(filter.Limit)
+	}
 
 	type roomSearchRow struct {
-		ID       uuid.UUID
-		Name     string
-		Capacity int
-		Features []byte
+		ID          uuid.UUID
+		Name        string
+		Capacity    int
+		Features    []byte
+		Building    string
+		Floor       int
+		RoomNumber  string
+		Latitude    *float64
+		Longitude   *float64
+		Accessible  bool
+		TimeZone    string
+		Description string
+
+		Layout          string
+		SetupMinutes    int
+		TeardownMinutes int
 	}
 
 	var rows []roomSearchRow
@@ -260,12 +429,65 @@ func (r *BookingRepository) SearchAvailableRooms(start, end time.Time, capacity,
 		}
 
 		results[i] = RoomSearchResult{
-			ID:       row.ID,
-			Name:     row.Name,
-			Capacity: row.Capacity,
-			Features: featureSlice,
+			ID:          row.ID,
+			Name:        row.Name,
+			Capacity:    row.Capacity,
+			Features:    featureSlice,
+			Building:    row.Building,
+			Floor:       row.Floor,
+			RoomNumber:  row.RoomNumber,
+			Latitude:    row.Latitude,
+			Longitude:   row.Longitude,
+			Accessible:  row.Accessible,
+			TimeZone:    row.TimeZone,
+			Description: row.Description,
+
+			Layout:          row.Layout,
+			SetupMinutes:    row.SetupMinutes,
+			TeardownMinutes: row.TeardownMinutes,
 		}
 	}
 
 	return results, nil
 }
+
+// RoomLayout looks up a layout of the room by name, ignoring case.
+func (r *BookingRepository) RoomLayout(roomID uuid.UUID, name string) (*RoomLayout, error) {
+	return roomLayout(r.db, roomID, name)
+}
+
+func roomLayout(db *gorm.DB, roomID uuid.UUID, name string) (*RoomLayout, error) {
+	var layout RoomLayout
+	result := db.Table("room_layouts").
+		Select("name, capacity, setup_minutes, teardown_minutes").
+		Where("room_id = ? AND lower(name) = lower(?)", roomID, name).
+		Scan(&layout)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLayoutNotFound
+	}
+	return &layout, nil
+}
+
+// EraseUserBookings cancels the user's upcoming bookings and detaches every
+// booking from them, keeping the rows for room utilisation history. Titles
+// are cleared and pending walk-up codes dropped.
+func (r *BookingRepository) EraseUserBookings(userID uuid.UUID, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Booking{}).
+			Where("user_id = ?", userID).
+			Where("status IN ?", []string{models.StatusPending, models.StatusConfirmed}).
+			Where("start_time > ?", now).
+			Update("status", models.StatusCancelled).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.WalkUpCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Booking{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]any{"user_id": uuid.Nil, "title": ""}).Error
+	})
+}
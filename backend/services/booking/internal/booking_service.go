@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"log"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/models"
-	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/proto"
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -16,14 +19,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// maxTitleLength is the longest booking title, in characters.
+const maxTitleLength = 100
+
 type BookingService struct {
 	repo      *BookingRepository
 	publisher events.Publisher
+	rooms     *RoomClient
+	// bookingChanges wakes long-polling kiosks.
+	bookingChanges *changeSignal
 	pb.UnimplementedBookingServiceServer
 }
 
-func NewBookingService(repo *BookingRepository, publisher events.Publisher) *BookingService {
-	return &BookingService{repo: repo, publisher: publisher}
+func NewBookingService(repo *BookingRepository, publisher events.Publisher, rooms *RoomClient) *BookingService {
+	return &BookingService{repo: repo, publisher: publisher, rooms: rooms, bookingChanges: newChangeSignal()}
 }
 
 func (s *BookingService) publishBookingEvent(ctx context.Context, booking *models.Booking, event string, metadata map[string]any) {
@@ -31,8 +40,7 @@ func (s *BookingService) publishBookingEvent(ctx context.Context, booking *model
 		return
 	}
 
-	// Fetch room name
-	roomName, err := s.repo.GetRoomName(booking.RoomID)
+	roomName, err := s.rooms.RoomName(ctx, booking.RoomID)
 	if err != nil {
 		log.Printf("failed to fetch room name for room %s: %v", booking.RoomID, err)
 		roomName = booking.RoomID.String() // Fallback to room ID
@@ -40,20 +48,27 @@ func (s *BookingService) publishBookingEvent(ctx context.Context, booking *model
 
 	payload := events.BookingEvent{
 		Event:     event,
-		BookingID: booking.ID.String(),
 		UserID:    booking.UserID.String(),
 		RoomID:    booking.RoomID.String(),
 		RoomName:  roomName,
 		Status:    booking.Status,
 		StartTime: booking.StartTime,
 		EndTime:   booking.EndTime,
-		Metadata:  metadata,
+		Metadata:  authz.ActorMetadata(ctx, metadata),
+	}
+	// Walk-up codes are sent before there is a booking.
+	if booking.ID != uuid.Nil {
+		payload.BookingID = booking.ID.String()
 	}
 	if err := s.publisher.PublishBookingEvent(ctx, payload); err != nil {
 		log.Printf("failed to publish booking event %s: %v", event, err)
 	}
 }
 
+// roomSearchBatch is how many rooms SearchRooms reads from the database at a
+// time.
+const roomSearchBatch = 100
+
 func (s *BookingService) SearchRooms(ctx context.Context, req *pb.SearchRoomsRequest) (*pb.SearchRoomsResponse, error) {
 	start := req.GetStart().AsTime()
 	end := req.GetEnd().AsTime()
@@ -66,25 +81,89 @@ func (s *BookingService) SearchRooms(ctx context.Context, req *pb.SearchRoomsReq
 		return nil, status.Error(codes.InvalidArgument, "start time must be before end time")
 	}
 
-	rooms, err := s.repo.SearchAvailableRooms(
-		start,
-		end,
-		int(req.Capacity),
-		int(req.Page),
-		int(req.PageSize),
-	)
+	if _, ok := roomSearchOrder[req.GetSortBy()]; req.GetSortBy() != "" && !ok {
+		return nil, status.Error(codes.InvalidArgument, "sort_by must be one of capacity, name, building, floor")
+	}
+
+	filter := RoomSearchFilter{
+		Capacity:       int(req.Capacity),
+		Building:       req.GetBuilding(),
+		AccessibleOnly: req.GetAccessibleOnly(),
+		SortBy:         req.GetSortBy(),
+		Descending:     req.GetDescending(),
+		Layout:         strings.TrimSpace(req.GetLayout()),
+	}
+	for _, floor := range req.GetFloors() {
+		filter.Floors = append(filter.Floors, int(floor))
+	}
+
+	page, pageSize := int(req.Page), int(req.PageSize)
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	rules, err := s.repo.ScheduleRules(start, end)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "unable to search rooms: %v", err)
+		return nil, status.Errorf(codes.Internal, "unable to load opening hours: %v", err)
+	}
+
+	// Rooms closed during the window are only known after loading them, so
+	// read the sorted rooms a batch at a time until the page is full.
+	skip := (page - 1) * pageSize
+	var rooms []RoomSearchResult
+	filter.Limit = roomSearchBatch
+	for len(rooms) < pageSize {
+		candidates, err := s.repo.SearchAvailableRooms(start, end, filter)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to search rooms: %v", err)
+		}
+		for _, room := range candidates {
+			// With a layout the room must also be open for setup and teardown.
+			from := start.Add(-time.Duration(room.SetupMinutes) * time.Minute)
+			until := end.Add(time.Duration(room.TeardownMinutes) * time.Minute)
+			if rules.calendarFor(room.ID, room.Building, room.TimeZone).Check(from, until) != nil {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(rooms) < pageSize {
+				rooms = append(rooms, room)
+			}
+		}
+		if len(candidates) < filter.Limit {
+			break
+		}
+		filter.Offset += filter.Limit
 	}
 
 	resp := &pb.SearchRoomsResponse{}
 	for _, room := range rooms {
-		resp.Rooms = append(resp.Rooms, &pb.RoomInfo{
-			RoomId:   room.ID.String(),
-			Name:     room.Name,
-			Capacity: int32(room.Capacity),
-			Features: room.Features,
-		})
+		info := &pb.RoomInfo{
+			RoomId:      room.ID.String(),
+			Name:        room.Name,
+			Capacity:    int32(room.Capacity),
+			Features:    room.Features,
+			Building:    room.Building,
+			Floor:       int32(room.Floor),
+			RoomNumber:  room.RoomNumber,
+			Accessible:  room.Accessible,
+			TimeZone:    room.TimeZone,
+			Description: room.Description,
+
+			Layout:          room.Layout,
+			SetupMinutes:    int32(room.SetupMinutes),
+			TeardownMinutes: int32(room.TeardownMinutes),
+		}
+		if room.Latitude != nil && room.Longitude != nil {
+			info.Latitude = *room.Latitude
+			info.Longitude = *room.Longitude
+		}
+		resp.Rooms = append(resp.Rooms, info)
 	}
 
 	return resp, nil
@@ -117,6 +196,16 @@ func (s *BookingService) CreateBooking(ctx context.Context, req *pb.CreateBookin
 		return nil, status.Error(codes.InvalidArgument, "start time must be in the future")
 	}
 
+	addOns, err := parseEquipmentAddOns(req.GetEquipment())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	title := strings.TrimSpace(req.GetTitle())
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return nil, status.Error(codes.InvalidArgument, "title must be at most 100 characters")
+	}
+
 	booking := &models.Booking{
 		ID:        uuid.New(),
 		UserID:    userID,
@@ -124,12 +213,39 @@ func (s *BookingService) CreateBooking(ctx context.Context, req *pb.CreateBookin
 		StartTime: start,
 		EndTime:   end,
 		Status:    models.StatusPending,
+		Title:     title,
+		Equipment: addOns,
+	}
+
+	if name := strings.TrimSpace(req.GetLayout()); name != "" {
+		layout, err := s.repo.RoomLayout(roomID, name)
+		if err != nil {
+			if errors.Is(err, ErrLayoutNotFound) {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			return nil, status.Errorf(codes.Internal, "failed to load layout: %v", err)
+		}
+		booking.Layout = layout.Name
+		booking.SetupMinutes = layout.SetupMinutes
+		booking.TeardownMinutes = layout.TeardownMinutes
+	}
+
+	if err := s.checkOpeningHours(booking); err != nil {
+		return nil, openingHoursStatus(err)
 	}
 
 	if err := s.repo.Create(booking); err != nil {
 		switch {
 		case errors.Is(err, ErrTimeSlotUnavailable):
 			return nil, status.Error(codes.FailedPrecondition, "room is not available for the requested time window")
+		case errors.Is(err, ErrRoomUnderMaintenance):
+			return nil, status.Error(codes.FailedPrecondition, ErrRoomUnderMaintenance.Error())
+		case errors.Is(err, ErrRoomArchived):
+			return nil, status.Error(codes.FailedPrecondition, ErrRoomArchived.Error())
+		case errors.Is(err, ErrEquipmentNotMovable), errors.Is(err, ErrEquipmentUnavailable):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, ErrEquipmentNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
 		case errors.Is(err, ErrRoomNotFound):
 			return nil, status.Error(codes.NotFound, "room not found")
 		case errors.Is(err, ErrUserNotFound):
@@ -147,6 +263,11 @@ func (s *BookingService) CreateBooking(ctx context.Context, req *pb.CreateBookin
 		RoomId:    booking.RoomID.String(),
 		Start:     timestamppb.New(booking.StartTime),
 		End:       timestamppb.New(booking.EndTime),
+		Equipment: toProtoAddOns(booking.Equipment),
+
+		Layout:          booking.Layout,
+		SetupMinutes:    int32(booking.SetupMinutes),
+		TeardownMinutes: int32(booking.TeardownMinutes),
 	}, nil
 }
 
@@ -267,10 +388,22 @@ func (s *BookingService) UpdateBooking(ctx context.Context, req *pb.UpdateBookin
 		return nil, status.Error(codes.FailedPrecondition, "cannot reschedule a booking that has already started")
 	}
 
+	moved := *booking
+	moved.StartTime, moved.EndTime = newStart, newEnd
+	if err := s.checkOpeningHours(&moved); err != nil {
+		return nil, openingHoursStatus(err)
+	}
+
 	if err := s.repo.UpdateBookingTimes(id, newStart, newEnd); err != nil {
 		switch {
 		case errors.Is(err, ErrTimeSlotUnavailable):
 			return nil, status.Error(codes.FailedPrecondition, "room is not available for the requested time window")
+		case errors.Is(err, ErrRoomUnderMaintenance):
+			return nil, status.Error(codes.FailedPrecondition, ErrRoomUnderMaintenance.Error())
+		case errors.Is(err, ErrRoomArchived):
+			return nil, status.Error(codes.FailedPrecondition, ErrRoomArchived.Error())
+		case errors.Is(err, ErrEquipmentNotMovable), errors.Is(err, ErrEquipmentUnavailable), errors.Is(err, ErrEquipmentNotFound):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			return nil, status.Error(codes.NotFound, "booking not found")
 		default:
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEquipmentNotFound    = errors.New("equipment not found")
+	ErrEquipmentNotMovable  = errors.New("only movable equipment can be reserved")
+	ErrEquipmentUnavailable = errors.New("equipment is not available for the requested time window")
+)
+
+// EquipmentAvailability is a movable equipment item and how many of its units
+// are free for a window.
+type EquipmentAvailability struct {
+	ID        uuid.UUID `json:"equipment_id"`
+	Name      string    `json:"name"`
+	RoomID    uuid.UUID `json:"room_id"`
+	RoomName  string    `json:"room_name"`
+	Condition string    `json:"condition"`
+	Quantity  int       `json:"quantity"`
+	Available int       `json:"available"`
+}
+
+type equipmentStock struct {
+	ID        uuid.UUID
+	Name      string
+	Quantity  int
+	Condition string
+	Movable   bool
+}
+
+// reservedEquipment sums the units of each item held by pending and confirmed
+// bookings overlapping [start, end), other than excludeID. Items are held for
+// a booking's setup and teardown time as well. Pending bookings hold their
+// add-ons too, so approving one never has to take equipment back.
+func reservedEquipment(tx *gorm.DB, ids []uuid.UUID, excludeID uuid.UUID, start, end time.Time) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		EquipmentID uuid.UUID
+		Reserved    int
+	}
+	query := tx.Table("booking_equipment").
+		Select("booking_equipment.equipment_id, SUM(booking_equipment.quantity) AS reserved").
+		Joins("JOIN bookings ON bookings.id = booking_equipment.booking_id").
+		Where("bookings.status IN ?", []string{models.StatusPending, models.StatusConfirmed}).
+		Where(occupiedOverlap, end, start).
+		Where("bookings.id <> ?", excludeID)
+	if ids != nil {
+		query = query.Where("booking_equipment.equipment_id IN ?", ids)
+	}
+	if err := query.Group("booking_equipment.equipment_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		reserved[row.EquipmentID] = row.Reserved
+	}
+	return reserved, nil
+}
+
+// checkEquipment fails unless every add-on is a movable, unbroken item with
+// enough units free during [start, end). The items are locked so concurrent
+// bookings of the same item are checked one after another.
+func checkEquipment(tx *gorm.DB, bookingID uuid.UUID, addOns []models.BookingEquipment, start, end time.Time) error {
+	if len(addOns) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(addOns))
+	for i, addOn := range addOns {
+		ids[i] = addOn.EquipmentID
+	}
+
+	var stock []equipmentStock
+	if err := tx.Table("room_equipment").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id, name, quantity, condition, movable").
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&stock).Error; err != nil {
+		return err
+	}
+	items := make(map[uuid.UUID]equipmentStock, len(stock))
+	for _, item := range stock {
+		items[item.ID] = item
+	}
+
+	reserved, err := reservedEquipment(tx, ids, bookingID, start, end)
+	if err != nil {
+		return err
+	}
+
+	for _, addOn := range addOns {
+		item, ok := items[addOn.EquipmentID]
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: %s", ErrEquipmentNotFound, addOn.EquipmentID)
+		case !item.Movable:
+			return fmt.Errorf("%w: %s is fixed to its room", ErrEquipmentNotMovable, item.Name)
+		case item.Condition == "broken":
+			return fmt.Errorf("%w: %s is broken", ErrEquipmentUnavailable, item.Name)
+		}
+		if free := item.Quantity - reserved[item.ID]; addOn.Quantity > free {
+			return fmt.Errorf("%w: %d of %s requested, %d free", ErrEquipmentUnavailable, addOn.Quantity, item.Name, max(free, 0))
+		}
+	}
+	return nil
+}
+
+// EquipmentAvailability lists movable, unbroken equipment with the units free
+// during [start, end).
+func (r *BookingRepository) EquipmentAvailability(start, end time.Time) ([]EquipmentAvailability, error) {
+	var items []EquipmentAvailability
+	if err := r.db.Table("room_equipment").
+		Select("room_equipment.id, room_equipment.name, room_equipment.room_id, rooms.name AS room_name, "+
+			"room_equipment.condition, room_equipment.quantity").
+		Joins("JOIN rooms ON rooms.id = room_equipment.room_id").
+		Where("room_equipment.movable AND room_equipment.condition <> ?", "broken").
+		Order("room_equipment.name, rooms.name").
+		Scan(&items).Error; err != nil {
+		return nil, err
+	}
+
+	reserved, err := reservedEquipment(r.db, nil, uuid.Nil, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Available = max(items[i].Quantity-reserved[items[i].ID], 0)
+	}
+	return items, nil
+}
+
+// parseEquipmentAddOns validates requested add-ons, merging repeated items.
+func parseEquipmentAddOns(req []*pb.EquipmentAddOn) ([]models.BookingEquipment, error) {
+	var addOns []models.BookingEquipment
+	index := make(map[uuid.UUID]int)
+	for _, addOn := range req {
+		id, err := uuid.Parse(addOn.GetEquipmentId())
+		if err != nil {
+			return nil, errors.New("invalid equipment_id")
+		}
+		if addOn.GetQuantity() < 1 {
+			return nil, errors.New("equipment quantity must be at least 1")
+		}
+		if i, ok := index[id]; ok {
+			addOns[i].Quantity += int(addOn.GetQuantity())
+			continue
+		}
+		index[id] = len(addOns)
+		addOns = append(addOns, models.BookingEquipment{EquipmentID: id, Quantity: int(addOn.GetQuantity())})
+	}
+	return addOns, nil
+}
+
+func toProtoAddOns(addOns []models.BookingEquipment) []*pb.EquipmentAddOn {
+	var out []*pb.EquipmentAddOn
+	for _, addOn := range addOns {
+		out = append(out, &pb.EquipmentAddOn{EquipmentId: addOn.EquipmentID.String(), Quantity: int32(addOn.Quantity)})
+	}
+	return out
+}
+
+func (s *BookingService) EquipmentAvailability(ctx context.Context, start, end time.Time) ([]EquipmentAvailability, error) {
+	return s.repo.EquipmentAvailability(start, end)
+}
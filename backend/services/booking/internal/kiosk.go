@@ -0,0 +1,545 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kiosk states, from most to least specific.
+const (
+	KioskBusy        = "busy"
+	KioskMaintenance = "maintenance"
+	KioskClosed      = "closed"
+	KioskFree        = "free"
+)
+
+const (
+	// kioskKeyPrefix marks kiosk keys, issued by the room service.
+	kioskKeyPrefix = "cpd_"
+	// kioskHorizon is how far ahead a kiosk looks for the next booking.
+	kioskHorizon = 24 * time.Hour
+	// checkInEarly is how long before its start a booking can be checked in.
+	checkInEarly = 10 * time.Minute
+	// Walk-up bookings last between these, 30 minutes if not given.
+	minWalkUp     = 15 * time.Minute
+	maxWalkUp     = 2 * time.Hour
+	defaultWalkUp = 30 * time.Minute
+	// A walk-up code is valid for walkUpCodeTTL and maxWalkUpAttempts
+	// guesses. A new one is sent at most every walkUpCodeCooldown.
+	walkUpCodeTTL      = 10 * time.Minute
+	walkUpCodeCooldown = time.Minute
+	maxWalkUpAttempts  = 5
+)
+
+var (
+	ErrInvalidKioskKey = errors.New("invalid kiosk key")
+	ErrInvalidWalkUp   = errors.New("walk-up bookings last 15 to 120 minutes and have a title of at most 100 characters")
+	ErrNothingToCheck  = errors.New("no confirmed booking of this room to check in to")
+	// ErrInvalidWalkUpCode does not say whether the email or the code was
+	// wrong, so kiosks cannot be used to find out who has an account.
+	ErrInvalidWalkUpCode = errors.New("invalid email or code")
+)
+
+// Kiosk is a display tablet mounted outside a room. Kiosks and their keys are
+// managed by the room service.
+type Kiosk struct {
+	ID         uuid.UUID
+	RoomID     uuid.UUID
+	Name       string
+	LastSeenAt *time.Time
+}
+
+// KioskRoom is what a kiosk shows about its room.
+type KioskRoom struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Building   string    `json:"building,omitempty"`
+	Floor      int       `json:"floor"`
+	RoomNumber string    `json:"room_number,omitempty"`
+	Capacity   int       `json:"capacity"`
+	TimeZone   string    `json:"time_zone"`
+}
+
+// KioskBooking is a booking as shown on a kiosk, without its owner.
+type KioskBooking struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Status      string     `json:"status"`
+	Start       time.Time  `json:"start_time"`
+	End         time.Time  `json:"end_time"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// KioskStatus is the compact current/next view of a room. Until is when State
+// ends, nil when that is beyond the kiosk's horizon. Version changes whenever
+// anything but Now does, for long polling.
+type KioskStatus struct {
+	Room       KioskRoom     `json:"room"`
+	Now        time.Time     `json:"now"`
+	State      string        `json:"state"`
+	Until      *time.Time    `json:"until,omitempty"`
+	Reason     string        `json:"reason,omitempty"`
+	Current    *KioskBooking `json:"current,omitempty"`
+	Next       *KioskBooking `json:"next,omitempty"`
+	CanWalkUp  bool          `json:"can_walk_up"`
+	CanCheckIn bool          `json:"can_check_in"`
+	Version    string        `json:"version"`
+}
+
+// WalkUpCodeInput asks for a walk-up code to be sent to the user with the
+// email.
+type WalkUpCodeInput struct {
+	Email string `json:"email"`
+}
+
+// WalkUpInput is a walk-up booking made at a kiosk. The email names the user
+// it is booked for and Code is the one-time code they were sent.
+type WalkUpInput struct {
+	Email           string `json:"email"`
+	Code            string `json:"code"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Title           string `json:"title"`
+}
+
+// kioskBlock is a span in which the room cannot be walked up to.
+type kioskBlock struct {
+	start, end time.Time
+	state      string
+	reason     string
+}
+
+// KioskByKeyHash returns the unrevoked kiosk with the key hash.
+func (r *BookingRepository) KioskByKeyHash(hash string) (*Kiosk, error) {
+	var kiosk Kiosk
+	result := r.db.Table("room_kiosks").
+		Select("id, room_id, name, last_seen_at").
+		Where("key_hash = ? AND revoked_at IS NULL", hash).
+		Scan(&kiosk)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &kiosk, nil
+}
+
+// ActiveUserIDByEmail returns the ID of the active user with the email.
+func (r *BookingRepository) ActiveUserIDByEmail(email string) (uuid.UUID, error) {
+	var userID string
+	err := r.db.Table("users").
+		Select("id").
+		Where("LOWER(email) = LOWER(?)", email).
+		Where("status IS NULL OR status IN ?", []string{"", "active"}).
+		Scan(&userID).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if userID == "" {
+		return uuid.Nil, ErrUserNotFound
+	}
+	return uuid.Parse(userID)
+}
+
+// SaveWalkUpCode stores a new code for the kiosk and user, replacing one sent
+// before unless that was within walkUpCodeCooldown. It reports whether the
+// code was stored.
+func (r *BookingRepository) SaveWalkUpCode(code *models.WalkUpCode) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kiosk_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"code_hash", "attempts", "expires_at", "created_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "kiosk_walk_up_codes.created_at < ?", Vars: []any{code.CreatedAt.Add(-walkUpCodeCooldown)}},
+		}},
+	}).Create(code)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseWalkUpCode consumes the kiosk's code for the user if its hash matches.
+// A wrong guess counts against the code, which is dropped once it expires or
+// runs out of attempts.
+func (r *BookingRepository) UseWalkUpCode(kioskID, userID uuid.UUID, hash string, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var code models.WalkUpCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&code, "kiosk_id = ? AND user_id = ?", kioskID, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidWalkUpCode
+		}
+		if err != nil {
+			return err
+		}
+
+		if !now.Before(code.ExpiresAt) || code.Attempts >= maxWalkUpAttempts {
+			if err := tx.Delete(&code).Error; err != nil {
+				return err
+			}
+			return ErrInvalidWalkUpCode
+		}
+		if subtle.ConstantTimeCompare([]byte(code.CodeHash), []byte(hash)) != 1 {
+			if err := tx.Model(&code).Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+				return err
+			}
+			return ErrInvalidWalkUpCode
+		}
+		return tx.Delete(&code).Error
+	})
+}
+
+func (r *BookingRepository) TouchKiosk(id uuid.UUID, at time.Time) error {
+	return r.db.Table("room_kiosks").Where("id = ?", id).Update("last_seen_at", at).Error
+}
+
+// maintenanceBlocks returns the maintenance windows of the room, or of a room
+// sharing its floor space, overlapping [start, end).
+func (r *BookingRepository) maintenanceBlocks(roomID uuid.UUID, start, end time.Time) ([]kioskBlock, error) {
+	var rows []struct {
+		StartsAt time.Time
+		EndsAt   time.Time
+		Reason   string
+	}
+	if err := r.db.Table("room_maintenance_windows").
+		Select("starts_at, ends_at, reason").
+		Where(inSharedSpace("room_id", roomID)).
+		Where("starts_at < ? AND ends_at > ?", end, start).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	blocks := make([]kioskBlock, len(rows))
+	for i, row := range rows {
+		blocks[i] = kioskBlock{start: row.StartsAt, end: row.EndsAt, state: KioskMaintenance, reason: row.Reason}
+	}
+	return blocks, nil
+}
+
+// CheckIn marks the room's confirmed booking running at now, or starting
+// within checkInEarly, as checked in. With a userID other than uuid.Nil only
+// that user's bookings are considered. Checking in twice keeps the first time.
+func (r *BookingRepository) CheckIn(roomID, userID uuid.UUID, now time.Time) (*models.Booking, error) {
+	var booking models.Booking
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("room_id = ? AND status = ?", roomID, models.StatusConfirmed).
+			Where("start_time <= ? AND end_time > ?", now.Add(checkInEarly), now)
+		if userID != uuid.Nil {
+			query = query.Where("user_id = ?", userID)
+		}
+		err := query.Order("start_time ASC").First(&booking).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNothingToCheck
+		}
+		if err != nil || booking.CheckedInAt != nil {
+			return err
+		}
+
+		booking.CheckedInAt = &now
+		return tx.Model(&booking).Update("checked_in_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// AuthenticateKiosk returns the active kiosk the key belongs to.
+func (s *BookingService) AuthenticateKiosk(key string) (*Kiosk, error) {
+	if !strings.HasPrefix(key, kioskKeyPrefix) {
+		return nil, ErrInvalidKioskKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	kiosk, err := s.repo.KioskByKeyHash(hex.EncodeToString(sum[:]))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidKioskKey
+		}
+		return nil, err
+	}
+
+	// Kiosks poll constantly; minute resolution is plenty to spot dead ones.
+	now := time.Now()
+	if kiosk.LastSeenAt == nil || now.Sub(*kiosk.LastSeenAt) > time.Minute {
+		if err := s.repo.TouchKiosk(kiosk.ID, now); err != nil {
+			log.Printf("failed to record kiosk %s as seen: %v", kiosk.ID, err)
+		}
+	}
+	return kiosk, nil
+}
+
+// KioskStatus combines the room, its bookings, maintenance windows and
+// opening hours into what its kiosk shows at now. Bookings of rooms sharing
+// its floor space occupy it too, and pending bookings count as booked so a
+// walk-up never takes a requested slot.
+func (s *BookingService) KioskStatus(ctx context.Context, kiosk *Kiosk, now time.Time) (*KioskStatus, error) {
+	room, err := s.rooms.KioskRoom(ctx, kiosk.RoomID)
+	if err != nil {
+		return nil, err
+	}
+
+	horizon := now.Add(kioskHorizon)
+	bookings, err := s.repo.ListOverlapping(kiosk.RoomID, now, horizon)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := s.repo.maintenanceBlocks(kiosk.RoomID, now, horizon)
+	if err != nil {
+		return nil, err
+	}
+	building, timeZone, err := s.repo.RoomLocation(kiosk.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.ScheduleRules(now, horizon)
+	if err != nil {
+		return nil, err
+	}
+	for _, closed := range rules.calendarFor(kiosk.RoomID, building, timeZone).ClosedPeriods(now, horizon) {
+		blocks = append(blocks, kioskBlock{start: closed.Start, end: closed.End, state: KioskClosed, reason: closed.Reason})
+	}
+
+	status := &KioskStatus{Room: *room, Now: now, State: KioskFree}
+	for i := range bookings {
+		b := &bookings[i]
+		block := kioskBlock{start: b.OccupiedFrom(), end: b.OccupiedUntil(), state: KioskBusy}
+		if b.RoomID != kiosk.RoomID {
+			block.reason = "booked together with a room sharing its space"
+		}
+		blocks = append(blocks, block)
+		switch {
+		case b.RoomID == kiosk.RoomID && b.StartTime.After(now) && status.Next == nil:
+			status.Next = kioskBooking(b)
+		case b.RoomID == kiosk.RoomID && !b.StartTime.After(now) && b.EndTime.After(now):
+			status.Current = kioskBooking(b)
+		}
+	}
+
+	// The state is that of the most specific block covering now. It lasts
+	// until the run of back-to-back blocks ends; a free room is free until
+	// the next block starts.
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].start.Before(blocks[j].start) })
+	var until time.Time
+	for _, block := range blocks {
+		if block.start.After(now) {
+			if status.State == KioskFree {
+				until = block.start
+				break
+			}
+			if block.start.After(until) {
+				break
+			}
+		}
+		if block.end.After(until) {
+			until = block.end
+		}
+		if !block.start.After(now) && kioskStateRank(block.state) < kioskStateRank(status.State) {
+			status.State, status.Reason = block.state, block.reason
+		}
+	}
+	if !until.IsZero() && until.Before(horizon) {
+		status.Until = &until
+	}
+
+	status.CanWalkUp = status.State == KioskFree && (status.Until == nil || status.Until.Sub(now) >= minWalkUp)
+	if status.Current != nil {
+		status.CanCheckIn = status.Current.Status == models.StatusConfirmed && status.Current.CheckedInAt == nil
+	} else if next := status.Next; next != nil {
+		status.CanCheckIn = next.Status == models.StatusConfirmed && next.CheckedInAt == nil && !next.Start.After(now.Add(checkInEarly))
+	}
+
+	versioned := *status
+	versioned.Now = time.Time{}
+	data, err := json.Marshal(versioned)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	status.Version = hex.EncodeToString(sum[:8])
+	return status, nil
+}
+
+func kioskStateRank(state string) int {
+	switch state {
+	case KioskBusy:
+		return 0
+	case KioskMaintenance:
+		return 1
+	case KioskClosed:
+		return 2
+	}
+	return 3
+}
+
+func kioskBooking(b *models.Booking) *KioskBooking {
+	title := b.Title
+	if title == "" {
+		title = "Booked"
+	}
+	return &KioskBooking{
+		ID:          b.ID,
+		Title:       title,
+		Status:      b.Status,
+		Start:       b.StartTime,
+		End:         b.EndTime,
+		CheckedInAt: b.CheckedInAt,
+	}
+}
+
+// RequestWalkUpCode sends a one-time code to the active user with the email,
+// who enters it at the kiosk to prove the walk-up booking is theirs. Nothing
+// tells the kiosk whether the email matched a user.
+func (s *BookingService) RequestWalkUpCode(ctx context.Context, kiosk *Kiosk, input WalkUpCodeInput) error {
+	userID, err := s.repo.ActiveUserIDByEmail(strings.TrimSpace(input.Email))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	code, err := newWalkUpCode()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	saved, err := s.repo.SaveWalkUpCode(&models.WalkUpCode{
+		KioskID:   kiosk.ID,
+		UserID:    userID,
+		CodeHash:  hashWalkUpCode(kiosk.ID, code),
+		ExpiresAt: now.Add(walkUpCodeTTL),
+		CreatedAt: now,
+	})
+	if err != nil || !saved {
+		return err
+	}
+
+	s.publishBookingEvent(ctx, &models.Booking{UserID: userID, RoomID: kiosk.RoomID}, events.BookingWalkUpCodeEvent, map[string]any{
+		"code":       code,
+		"kiosk_id":   kiosk.ID.String(),
+		"kiosk_name": kiosk.Name,
+		"expires_at": now.Add(walkUpCodeTTL).UTC(),
+	})
+	return nil
+}
+
+// newWalkUpCode returns a random six digit code.
+func newWalkUpCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashWalkUpCode binds the code to the kiosk it was sent for.
+func hashWalkUpCode(kioskID uuid.UUID, code string) string {
+	sum := sha256.Sum256([]byte(kioskID.String() + ":" + strings.TrimSpace(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// WalkUpBooking requests the kiosk's room from now for the user with the
+// given email, once the code they were sent checks out.
+func (s *BookingService) WalkUpBooking(ctx context.Context, kiosk *Kiosk, input WalkUpInput) (*models.Booking, error) {
+	// Reject a bad duration or title before the code is used up.
+	if _, _, err := walkUpWindow(input.DurationMinutes, input.Title); err != nil {
+		return nil, err
+	}
+	userID, err := s.repo.ActiveUserIDByEmail(strings.TrimSpace(input.Email))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidWalkUpCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UseWalkUpCode(kiosk.ID, userID, hashWalkUpCode(kiosk.ID, input.Code), time.Now()); err != nil {
+		return nil, err
+	}
+	return s.quickBooking(ctx, kiosk.RoomID, userID, input.DurationMinutes, input.Title, map[string]any{
+		"source":   "kiosk",
+		"kiosk_id": kiosk.ID.String(),
+	})
+}
+
+// walkUpWindow returns the length, durationMinutes or 30 minutes if zero, and
+// trimmed title of a quick booking.
+func walkUpWindow(durationMinutes int, title string) (time.Duration, string, error) {
+	duration := defaultWalkUp
+	if durationMinutes != 0 {
+		duration = time.Duration(durationMinutes) * time.Minute
+	}
+	title = strings.TrimSpace(title)
+	if duration < minWalkUp || duration > maxWalkUp || utf8.RuneCountInString(title) > maxTitleLength {
+		return 0, "", ErrInvalidWalkUp
+	}
+	return duration, title, nil
+}
+
+// quickBooking requests the room from now, for durationMinutes or 30 minutes
+// if zero. It goes through the same checks and approval as any other booking;
+// once approved it can be checked in at the room.
+func (s *BookingService) quickBooking(ctx context.Context, roomID, userID uuid.UUID, durationMinutes int, title string, metadata map[string]any) (*models.Booking, error) {
+	duration, title, err := walkUpWindow(durationMinutes, title)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Truncate(time.Minute)
+	booking := &models.Booking{
+		ID:        uuid.New(),
+		UserID:    userID,
+		RoomID:    roomID,
+		StartTime: now,
+		EndTime:   now.Add(duration),
+		Status:    models.StatusPending,
+		Title:     title,
+	}
+	if err := s.checkOpeningHours(booking); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(booking); err != nil {
+		return nil, err
+	}
+
+	s.publishBookingEvent(ctx, booking, events.BookingCreatedEvent, metadata)
+	return booking, nil
+}
+
+// CheckIn checks in the booking of the kiosk's room that is running or about
+// to start.
+func (s *BookingService) CheckIn(ctx context.Context, kiosk *Kiosk) (*models.Booking, error) {
+	return s.checkIn(ctx, kiosk.RoomID, uuid.Nil, map[string]any{"kiosk_id": kiosk.ID.String()})
+}
+
+// checkIn checks in the room's booking that is running or about to start,
+// only among userID's bookings unless it is uuid.Nil.
+func (s *BookingService) checkIn(ctx context.Context, roomID, userID uuid.UUID, metadata map[string]any) (*models.Booking, error) {
+	now := time.Now()
+	booking, err := s.repo.CheckIn(roomID, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !booking.CheckedInAt.Equal(now) {
+		return booking, nil // checked in before
+	}
+
+	metadata["checked_in_at"] = booking.CheckedInAt.UTC()
+	s.publishBookingEvent(ctx, booking, events.BookingCheckedInEvent, metadata)
+	return booking, nil
+}
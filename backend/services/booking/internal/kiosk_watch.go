@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// bookingChangesChannel is the Postgres channel notified whenever the
+// bookings table changes, whichever service or instance changed it.
+const bookingChangesChannel = "booking_changes"
+
+// InstallBookingChangeTrigger makes every write to the bookings table notify
+// bookingChangesChannel. It is safe to run on every start.
+func InstallBookingChangeTrigger(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			fmt.Sprintf(`CREATE OR REPLACE FUNCTION notify_booking_changes() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('%s', '');
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`, bookingChangesChannel),
+			`DROP TRIGGER IF EXISTS bookings_notify_changes ON bookings`,
+			`CREATE TRIGGER bookings_notify_changes AFTER INSERT OR UPDATE OR DELETE ON bookings
+	FOR EACH STATEMENT EXECUTE FUNCTION notify_booking_changes()`,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListenBookingChanges calls changed for every notification on
+// bookingChangesChannel, and once when it starts listening since changes may
+// have been missed before. It holds one connection until ctx is done.
+func (r *BookingRepository) ListenBookingChanges(ctx context.Context, changed func()) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pg := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pg.Exec(ctx, "LISTEN "+bookingChangesChannel); err != nil {
+			return err
+		}
+		changed()
+		for {
+			if _, err := pg.WaitForNotification(ctx); err != nil {
+				return err
+			}
+			changed()
+		}
+	})
+}
+
+// changeSignal wakes every waiter at once: Wait returns a channel that is
+// closed by the next Broadcast.
+type changeSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newChangeSignal() *changeSignal {
+	return &changeSignal{ch: make(chan struct{})}
+}
+
+func (s *changeSignal) Wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ch
+}
+
+func (s *changeSignal) Broadcast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.ch)
+	s.ch = make(chan struct{})
+}
+
+// WatchBookingChanges wakes long-polling kiosks whenever bookings change,
+// until ctx is done.
+func (s *BookingService) WatchBookingChanges(ctx context.Context) error {
+	return s.repo.ListenBookingChanges(ctx, s.bookingChanges.Broadcast)
+}
+
+// BookingChanges returns a channel closed at the next change to bookings.
+// Take it before reading the state it should wake you about.
+func (s *BookingService) BookingChanges() <-chan struct{} {
+	return s.bookingChanges.Wait()
+}
+
+// changesAt is the next time after now at which the status would change
+// without any booking changing: its state ending, the next booking starting
+// or opening for check-in, or the current one ending. It is zero if none of
+// those is known.
+func (v *KioskStatus) changesAt(now time.Time) time.Time {
+	var next time.Time
+	consider := func(t time.Time) {
+		if t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if v.Until != nil {
+		consider(*v.Until)
+		// A free room stops taking walk-ups when too little time is left.
+		consider(v.Until.Add(-minWalkUp))
+	}
+	if v.Current != nil {
+		consider(v.Current.End)
+	}
+	if v.Next != nil {
+		consider(v.Next.Start)
+		consider(v.Next.Start.Add(-checkInEarly))
+	}
+	return next
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangeSignalWakesEveryWaiter(t *testing.T) {
+	s := newChangeSignal()
+	a, b := s.Wait(), s.Wait()
+
+	s.Broadcast()
+	for _, ch := range []<-chan struct{}{a, b} {
+		select {
+		case <-ch:
+		default:
+			t.Fatal("waiter not woken by Broadcast")
+		}
+	}
+
+	select {
+	case <-s.Wait():
+		t.Fatal("a waiter taken after Broadcast must wait for the next one")
+	default:
+	}
+}
+
+func TestKioskStatusChangesAt(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return now.Add(time.Duration(minutes) * time.Minute) }
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name string
+		view KioskStatus
+		want time.Time
+	}{
+		{"nothing ahead", KioskStatus{}, time.Time{}},
+		{"free until a booking, walk-ups close first", KioskStatus{Until: ptr(at(60)), Next: &KioskBooking{Start: at(60), End: at(90)}}, at(45)},
+		{"check-in opens before the next booking", KioskStatus{Until: ptr(at(5)), Next: &KioskBooking{Start: at(5), End: at(30)}}, at(5)},
+		{"next booking opens for check-in", KioskStatus{Next: &KioskBooking{Start: at(20), End: at(60)}}, at(10)},
+		{"current booking ends", KioskStatus{Current: &KioskBooking{Start: at(-30), End: at(30)}}, at(30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.view.changesAt(now); !got.Equal(tt.want) {
+				t.Errorf("changesAt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
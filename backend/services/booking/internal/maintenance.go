@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/models"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Displacement actions and outcomes for DisplaceBookings.
+const (
+	DisplaceList     = "list"
+	DisplaceCancel   = "cancel"
+	DisplaceRelocate = "relocate"
+
+	OutcomeAffected   = "affected"
+	OutcomeCancelled  = "cancelled"
+	OutcomeRelocated  = "relocated"
+	OutcomeUnresolved = "unresolved"
+)
+
+var ErrNoAlternativeRoom = errors.New("no alternative room is available")
+
+// checkMaintenance fails with ErrRoomUnderMaintenance when a maintenance
+// window of the room, or of a room sharing its floor space, overlaps
+// [start, end). The windows are owned by the room service.
+func checkMaintenance(tx *gorm.DB, roomID uuid.UUID, start, end time.Time) error {
+	var count int64
+	if err := tx.Table("room_maintenance_windows").
+		Where(inSharedSpace("room_id", roomID)).
+		Where("starts_at < ? AND ends_at > ?", end, start).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoomUnderMaintenance
+	}
+	return nil
+}
+
+// ListOverlapping returns the pending and confirmed bookings of a room, or of
+// a room sharing its floor space, that with their setup and teardown time
+// overlap [start, end).
+func (r *BookingRepository) ListOverlapping(roomID uuid.UUID, start, end time.Time) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.Where(inSharedSpace("room_id", roomID)).
+		Where("status IN ?", []string{models.StatusPending, models.StatusConfirmed}).
+		Where(occupiedOverlap, end, start).
+		Order("start_time ASC").
+		Find(&bookings).Error
+	return bookings, err
+}
+
+// RelocateBooking moves a booking to another room, failing if that room is
+// booked or under maintenance for the booking's window. A booking in a layout
+// takes the buffers of the new room's layout of the same name.
+func (r *BookingRepository) RelocateBooking(id, roomID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		if booking.Layout != "" {
+			layout, err := roomLayout(tx, roomID, booking.Layout)
+			if err != nil {
+				return err
+			}
+			booking.SetupMinutes = layout.SetupMinutes
+			booking.TeardownMinutes = layout.TeardownMinutes
+		}
+
+		var count int64
+		if err := tx.Model(&models.Booking{}).
+			Where(inSharedSpace("room_id", roomID)).
+			Where("status = ?", models.StatusConfirmed).
+			Where(occupiedOverlap, booking.OccupiedUntil(), booking.OccupiedFrom()).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrTimeSlotUnavailable
+		}
+
+		if err := checkMaintenance(tx, roomID, booking.OccupiedFrom(), booking.OccupiedUntil()); err != nil {
+			return err
+		}
+
+		return tx.Model(&booking).Updates(map[string]any{
+			"room_id":          roomID,
+			"setup_minutes":    booking.SetupMinutes,
+			"teardown_minutes": booking.TeardownMinutes,
+		}).Error
+	})
+}
+
+// RoomCapacity returns the capacity and building of a room.
+func (r *BookingRepository) RoomCapacity(roomID uuid.UUID) (int, string, error) {
+	var row struct {
+		Capacity int
+		Building string
+	}
+	result := r.db.Table("rooms").Select("capacity, building").Where("id = ?", roomID).Scan(&row)
+	if result.Error != nil {
+		return 0, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, "", ErrRoomNotFound
+	}
+	return row.Capacity, row.Building, nil
+}
+
+// DisplaceBookings lists the bookings a maintenance window or room archival
+// collides with and, depending on the action, cancels them or moves each to
+// the smallest free room of at least the same capacity, preferring the same
+// building. Owners are notified through the usual booking events.
+func (s *BookingService) DisplaceBookings(ctx context.Context, req *pb.DisplaceBookingsRequest) (*pb.DisplaceBookingsResponse, error) {
+	roomID, err := uuid.Parse(req.GetRoomId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid room_id")
+	}
+
+	start := req.GetStart().AsTime()
+	end := req.GetEnd().AsTime()
+	if !start.Before(end) {
+		return nil, status.Error(codes.InvalidArgument, "start time must be before end time")
+	}
+
+	action := req.GetAction()
+	switch action {
+	case "":
+		action = DisplaceList
+	case DisplaceList, DisplaceCancel, DisplaceRelocate:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "action must be list, cancel or relocate")
+	}
+
+	bookings, err := s.repo.ListOverlapping(roomID, start, end)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load bookings: %v", err)
+	}
+
+	cause := req.GetCause()
+	if cause == "" {
+		cause = "maintenance"
+	}
+	metadata := map[string]any{"cause": cause}
+	if reason := req.GetReason(); reason != "" {
+		metadata["reason"] = reason
+	}
+
+	resp := &pb.DisplaceBookingsResponse{}
+	for i := range bookings {
+		booking := &bookings[i]
+		result := &pb.DisplacedBooking{Outcome: OutcomeAffected}
+
+		switch action {
+		case DisplaceCancel:
+			if err := s.repo.UpdateStatus(booking.ID, models.StatusCancelled); err != nil {
+				result.Outcome = OutcomeUnresolved
+				result.Error = err.Error()
+				break
+			}
+			booking.Status = models.StatusCancelled
+			result.Outcome = OutcomeCancelled
+			s.publishBookingEvent(ctx, booking, events.BookingCancelledEvent, metadata)
+		case DisplaceRelocate:
+			newRoomID, err := s.relocate(booking)
+			if err != nil {
+				result.Outcome = OutcomeUnresolved
+				result.Error = err.Error()
+				break
+			}
+
+			previous := booking.RoomID
+			names, err := s.rooms.RoomNames(ctx, []uuid.UUID{previous, newRoomID})
+			if err != nil {
+				log.Printf("failed to fetch room names for relocated booking %s: %v", booking.ID, err)
+			}
+			booking.RoomID = newRoomID
+			result.Outcome = OutcomeRelocated
+			result.NewRoomId = newRoomID.String()
+			result.NewRoomName = names[newRoomID]
+
+			relocated := map[string]any{"previous_room_id": previous.String(), "previous_room_name": names[previous]}
+			for k, v := range metadata {
+				relocated[k] = v
+			}
+			s.publishBookingEvent(ctx, booking, events.BookingRelocatedEvent, relocated)
+		}
+
+		result.Booking = &pb.BookingSummary{
+			BookingId: booking.ID.String(),
+			UserId:    booking.UserID.String(),
+			Start:     timestamppb.New(booking.StartTime),
+			End:       timestamppb.New(booking.EndTime),
+			Status:    booking.Status,
+		}
+		resp.Bookings = append(resp.Bookings, result)
+	}
+
+	return resp, nil
+}
+
+// relocate moves booking to the first free candidate room. A booking in a
+// layout only moves to rooms offering that layout with at least the same
+// capacity. Rooms taken by a concurrent booking are skipped.
+func (s *BookingService) relocate(booking *models.Booking) (uuid.UUID, error) {
+	capacity, building, err := s.repo.RoomCapacity(booking.RoomID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	filter := RoomSearchFilter{Capacity: capacity, Layout: booking.Layout}
+	if booking.Layout != "" {
+		if layout, err := s.repo.RoomLayout(booking.RoomID, booking.Layout); err == nil {
+			filter.Capacity = layout.Capacity
+		} else if !errors.Is(err, ErrLayoutNotFound) {
+			return uuid.Nil, err
+		}
+	}
+
+	candidates, err := s.repo.SearchAvailableRooms(booking.StartTime, booking.EndTime, filter)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	rules, err := s.repo.ScheduleRules(booking.StartTime, booking.EndTime)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// candidates are sorted by capacity; keep that order within each building group
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Building == building && candidates[j].Building != building
+	})
+
+	for _, room := range candidates {
+		if room.ID == booking.RoomID {
+			continue
+		}
+		from := booking.StartTime.Add(-time.Duration(room.SetupMinutes) * time.Minute)
+		until := booking.EndTime.Add(time.Duration(room.TeardownMinutes) * time.Minute)
+		if rules.calendarFor(room.ID, room.Building, room.TimeZone).Check(from, until) != nil {
+			continue
+		}
+		if err := s.repo.RelocateBooking(booking.ID, room.ID); err != nil {
+			if errors.Is(err, ErrTimeSlotUnavailable) || errors.Is(err, ErrRoomUnderMaintenance) || errors.Is(err, ErrLayoutNotFound) {
+				continue
+			}
+			return uuid.Nil, err
+		}
+		return room.ID, nil
+	}
+	return uuid.Nil, ErrNoAlternativeRoom
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/schedule"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/models"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scheduleRules holds the opening hours and exception rows owned by the room
+// service, read straight from its tables like the rooms table itself.
+type scheduleRules struct {
+	hours      []schedule.Hours
+	exceptions []schedule.Exception
+}
+
+type openingHoursRow struct {
+	RoomID       *uuid.UUID
+	Building     string
+	Weekday      int
+	OpensMinute  int
+	ClosesMinute int
+}
+
+type scheduleExceptionRow struct {
+	RoomID       *uuid.UUID
+	Building     string
+	Date         time.Time
+	Closed       bool
+	OpensMinute  int
+	ClosesMinute int
+	Reason       string
+}
+
+// ScheduleRules loads every opening hours row and the exceptions dated
+// between from and to, padded by a day for time zone offsets.
+func (r *BookingRepository) ScheduleRules(from, to time.Time) (*scheduleRules, error) {
+	var hourRows []openingHoursRow
+	if err := r.db.Table("room_opening_hours").
+		Select("room_id, building, weekday, opens_minute, closes_minute").
+		Scan(&hourRows).Error; err != nil {
+		return nil, err
+	}
+
+	var exceptionRows []scheduleExceptionRow
+	if err := r.db.Table("room_schedule_exceptions").
+		Select("room_id, building, date, closed, opens_minute, closes_minute, reason").
+		Where("date BETWEEN ? AND ?",
+			from.AddDate(0, 0, -1).Format(schedule.DateLayout),
+			to.AddDate(0, 0, 1).Format(schedule.DateLayout)).
+		Scan(&exceptionRows).Error; err != nil {
+		return nil, err
+	}
+
+	rules := &scheduleRules{}
+	for _, row := range hourRows {
+		rules.hours = append(rules.hours, schedule.Hours{
+			RoomID:   uuidString(row.RoomID),
+			Building: row.Building,
+			Weekday:  time.Weekday(row.Weekday),
+			Opens:    row.OpensMinute,
+			Closes:   row.ClosesMinute,
+		})
+	}
+	for _, row := range exceptionRows {
+		rules.exceptions = append(rules.exceptions, schedule.Exception{
+			RoomID:   uuidString(row.RoomID),
+			Building: row.Building,
+			Date:     row.Date.Format(schedule.DateLayout),
+			Closed:   row.Closed,
+			Opens:    row.OpensMinute,
+			Closes:   row.ClosesMinute,
+			Reason:   row.Reason,
+		})
+	}
+	return rules, nil
+}
+
+// RoomLocation returns the building and time zone of a room.
+func (r *BookingRepository) RoomLocation(roomID uuid.UUID) (string, string, error) {
+	var row struct {
+		Building string
+		TimeZone string
+	}
+	result := r.db.Table("rooms").Select("building, time_zone").Where("id = ?", roomID).Scan(&row)
+	if result.Error != nil {
+		return "", "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", "", ErrRoomNotFound
+	}
+	return row.Building, row.TimeZone, nil
+}
+
+// calendarFor picks the rules that apply to one room.
+func (rules *scheduleRules) calendarFor(roomID uuid.UUID, building, timeZone string) *schedule.Calendar {
+	applies := func(ruleRoom, ruleBuilding string) bool {
+		if ruleRoom != "" {
+			return ruleRoom == roomID.String()
+		}
+		return ruleBuilding == "" || ruleBuilding == building
+	}
+
+	var hours []schedule.Hours
+	for _, h := range rules.hours {
+		if applies(h.RoomID, h.Building) {
+			hours = append(hours, h)
+		}
+	}
+	var exceptions []schedule.Exception
+	for _, e := range rules.exceptions {
+		if applies(e.RoomID, e.Building) {
+			exceptions = append(exceptions, e)
+		}
+	}
+
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return schedule.NewCalendar(loc, hours, exceptions)
+}
+
+// checkOpeningHours returns a *schedule.ClosedError when the booking's room
+// is closed for part of the time it occupies, setup and teardown included.
+func (s *BookingService) checkOpeningHours(booking *models.Booking) error {
+	building, timeZone, err := s.repo.RoomLocation(booking.RoomID)
+	if err != nil {
+		return err
+	}
+	start, end := booking.OccupiedFrom(), booking.OccupiedUntil()
+	rules, err := s.repo.ScheduleRules(start, end)
+	if err != nil {
+		return err
+	}
+	return rules.calendarFor(booking.RoomID, building, timeZone).Check(start, end)
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func openingHoursStatus(err error) error {
+	var closed *schedule.ClosedError
+	switch {
+	case errors.As(err, &closed):
+		return status.Error(codes.FailedPrecondition, closed.Error())
+	case errors.Is(err, ErrRoomNotFound):
+		return status.Error(codes.NotFound, "room not found")
+	}
+	return status.Errorf(codes.Internal, "failed to check opening hours: %v", err)
+}
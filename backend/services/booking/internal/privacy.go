@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/google/uuid"
+)
+
+// PrivacyHandler answers data subject requests for the bookings table.
+type PrivacyHandler struct {
+	repo  *BookingRepository
+	rooms *RoomClient
+}
+
+func NewPrivacyHandler(repo *BookingRepository, rooms *RoomClient) *PrivacyHandler {
+	return &PrivacyHandler{repo: repo, rooms: rooms}
+}
+
+func (h *PrivacyHandler) ExportUserData(ctx context.Context, req events.PrivacyRequest) (map[string]any, error) {
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id: %w", err)
+	}
+
+	bookings, err := h.repo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roomIDs := make([]uuid.UUID, len(bookings))
+	for i, b := range bookings {
+		roomIDs[i] = b.RoomID
+	}
+	roomNames, err := h.rooms.RoomNames(ctx, roomIDs)
+	if err != nil {
+		log.Printf("failed to fetch room names for export of user %s: %v", userID, err)
+	}
+
+	out := make([]map[string]any, 0, len(bookings))
+	for _, b := range bookings {
+		equipment := make([]map[string]any, len(b.Equipment))
+		for i, e := range b.Equipment {
+			equipment[i] = map[string]any{"equipment_id": e.EquipmentID.String(), "quantity": e.Quantity}
+		}
+		out = append(out, map[string]any{
+			"booking_id":    b.ID.String(),
+			"room_id":       b.RoomID.String(),
+			"room_name":     roomNames[b.RoomID],
+			"title":         b.Title,
+			"layout":        b.Layout,
+			"equipment":     equipment,
+			"start_time":    b.StartTime,
+			"end_time":      b.EndTime,
+			"status":        b.Status,
+			"checked_in_at": b.CheckedInAt,
+			"created_at":    b.CreatedAt,
+			"updated_at":    b.UpdatedAt,
+		})
+	}
+	return map[string]any{"bookings": out}, nil
+}
+
+func (h *PrivacyHandler) EraseUserData(ctx context.Context, req events.PrivacyRequest) error {
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+	return h.repo.EraseUserBookings(userID, time.Now())
+}
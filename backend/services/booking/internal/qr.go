@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/models"
+	"github.com/google/uuid"
+)
+
+// Outcomes of scanning a room's QR code.
+const (
+	ScanCheckedIn = "checked_in"
+	ScanRequested = "requested"
+)
+
+var ErrInvalidRoomCode = errors.New("invalid room code")
+
+// ScanInput is a scanned room QR code link. Duration and title apply when the
+// scan starts a quick booking.
+type ScanInput struct {
+	RoomID          string `json:"room_id"`
+	Signature       string `json:"sig"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Title           string `json:"title"`
+}
+
+// ScanResult tells whether a scan checked the user in or requested the room.
+type ScanResult struct {
+	Action  string          `json:"action"`
+	Booking *models.Booking `json:"booking"`
+}
+
+// ScanRoom handles a user scanning a room's QR code: their booking of the
+// room that is running or about to start is checked in, and otherwise the
+// room is requested for them from now, pending approval like any booking.
+// The code is checked by the room service, which holds the secrets; the
+// caller's Authorization header is passed on to it.
+func (s *BookingService) ScanRoom(ctx context.Context, authorization string, userID uuid.UUID, input ScanInput) (*ScanResult, error) {
+	roomID, err := uuid.Parse(input.RoomID)
+	if err != nil {
+		return nil, ErrInvalidRoomCode
+	}
+	if err := s.rooms.VerifyRoomCode(ctx, authorization, roomID, input.Signature); err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]any{"source": "qr"}
+	booking, err := s.checkIn(ctx, roomID, userID, metadata)
+	if err == nil {
+		return &ScanResult{Action: ScanCheckedIn, Booking: booking}, nil
+	}
+	if !errors.Is(err, ErrNothingToCheck) {
+		return nil, err
+	}
+
+	booking, err = s.quickBooking(ctx, roomID, userID, input.DurationMinutes, input.Title, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &ScanResult{Action: ScanRequested, Booking: booking}, nil
+}
@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	roompb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/room"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// roomLookupTimeout bounds each room lookup, so a slow room service delays a
+// booking response rather than hanging it.
+const roomLookupTimeout = 2 * time.Second
+
+// RoomClient calls the room service, which owns rooms and their QR code
+// secrets. Room lookups use its gRPC API; VerifyRoomCode uses its HTTP API,
+// since room.proto has no QR code RPC.
+type RoomClient struct {
+	baseURL string
+	http    *http.Client
+	// grpc is nil when the gRPC address cannot be used; lookups then fail.
+	grpc roompb.RoomServiceClient
+}
+
+// NewRoomClient talks to the room service at ROOM_SERVICE_URL and on
+// ROOM_GRPC_PORT, defaulting to its compose hostname.
+func NewRoomClient() *RoomClient {
+	baseURL := strings.TrimSpace(os.Getenv("ROOM_SERVICE_URL"))
+	if baseURL == "" {
+		baseURL = "http://room-service:8082"
+	}
+	port := os.Getenv("ROOM_GRPC_PORT")
+	if port == "" {
+		port = "50053"
+	}
+
+	client := &RoomClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 5 * time.Second},
+	}
+	conn, err := grpc.NewClient("room-service:"+port, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Printf("Warning: failed to connect to room service: %v", err)
+		return client
+	}
+	client.grpc = roompb.NewRoomServiceClient(conn)
+	return client
+}
+
+// RoomNames returns the names of the rooms with the ids, archived or not.
+// Rooms the room service does not know are left out.
+func (c *RoomClient) RoomNames(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]string, error) {
+	names := make(map[uuid.UUID]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	if c == nil || c.grpc == nil {
+		return names, errors.New("room service unavailable")
+	}
+
+	req := &roompb.BatchGetRoomsRequest{Ids: make([]string, 0, len(ids))}
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			req.Ids = append(req.Ids, id.String())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, roomLookupTimeout)
+	defer cancel()
+	resp, err := c.grpc.BatchGetRooms(ctx, req)
+	if err != nil {
+		return names, fmt.Errorf("get room names: %w", err)
+	}
+	for id, room := range resp.GetRooms() {
+		roomID, err := uuid.Parse(id)
+		if err != nil {
+			continue
+		}
+		names[roomID] = room.GetName()
+	}
+	return names, nil
+}
+
+// RoomName returns the name of the room, or ErrRoomNotFound.
+func (c *RoomClient) RoomName(ctx context.Context, roomID uuid.UUID) (string, error) {
+	names, err := c.RoomNames(ctx, []uuid.UUID{roomID})
+	if err != nil {
+		return "", err
+	}
+	name, ok := names[roomID]
+	if !ok {
+		return "", ErrRoomNotFound
+	}
+	return name, nil
+}
+
+// KioskRoom returns what a kiosk shows about its room. An archived room is
+// reported as ErrRoomNotFound, since its kiosk no longer serves it.
+func (c *RoomClient) KioskRoom(ctx context.Context, roomID uuid.UUID) (*KioskRoom, error) {
+	if c == nil || c.grpc == nil {
+		return nil, errors.New("room service unavailable")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, roomLookupTimeout)
+	defer cancel()
+	room, err := c.grpc.GetRoom(ctx, &roompb.GetRoomRequest{Id: roomID.String()})
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get room: %w", err)
+	}
+	if room.GetArchivedAt() != nil {
+		return nil, ErrRoomNotFound
+	}
+	return &KioskRoom{
+		ID:         roomID,
+		Name:       room.GetName(),
+		Building:   room.GetBuilding(),
+		Floor:      int(room.GetFloor()),
+		RoomNumber: room.GetRoomNumber(),
+		Capacity:   int(room.GetCapacity()),
+		TimeZone:   room.GetTimeZone(),
+	}, nil
+}
+
+// VerifyRoomCode asks the room service whether sig is the current signature of
+// the room's QR code. The caller's Authorization header is passed on, so the
+// room service sees who scanned. It returns ErrInvalidRoomCode for a code the
+// room service rejects.
+func (c *RoomClient) VerifyRoomCode(ctx context.Context, authorization string, roomID uuid.UUID, sig string) error {
+	body, err := json.Marshal(map[string]string{"sig": sig})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/rooms/"+roomID.String()+"/qr/verify", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("verify room code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil
+	case resp.StatusCode == http.StatusForbidden, resp.StatusCode == http.StatusBadRequest:
+		return ErrInvalidRoomCode
+	}
+	return fmt.Errorf("verify room code: room service answered %s", resp.Status)
+}
@@ -24,4 +24,40 @@ type Booking struct {
 	Status    string    `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Layout names the room layout booked, if any. Its setup and teardown
+	// time is copied so later layout changes do not move the booking.
+	Layout          string `gorm:"type:varchar(50);not null;default:''" json:"layout,omitempty"`
+	SetupMinutes    int    `gorm:"not null;default:0" json:"setup_minutes"`
+	TeardownMinutes int    `gorm:"not null;default:0" json:"teardown_minutes"`
+
+	// Title is shown on the room's kiosk. CheckedInAt is set when someone
+	// checks in at the kiosk.
+	Title       string     `gorm:"type:varchar(100);not null;default:''" json:"title,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+
+	Equipment []BookingEquipment `gorm:"foreignKey:BookingID;constraint:OnDelete:CASCADE" json:"equipment,omitempty"`
+}
+
+// OccupiedFrom is when the room is first blocked for the booking, including
+// setup time.
+func (b *Booking) OccupiedFrom() time.Time {
+	return b.StartTime.Add(-time.Duration(b.SetupMinutes) * time.Minute)
 }
+
+// OccupiedUntil is when the room is free again after the booking, including
+// teardown time.
+func (b *Booking) OccupiedUntil() time.Time {
+	return b.EndTime.Add(time.Duration(b.TeardownMinutes) * time.Minute)
+}
+
+// BookingEquipment reserves Quantity units of a movable room_equipment item
+// for the booking's window. The items themselves are owned by the room
+// service.
+type BookingEquipment struct {
+	BookingID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	EquipmentID uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"equipment_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+}
+
+func (BookingEquipment) TableName() string { return "booking_equipment" }
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WalkUpCode is the one-time code sent to a user who asked a kiosk to book
+// its room for them. The booking is only made once the code is entered at the
+// kiosk, so nobody can book in someone else's name by knowing their email.
+// Only a hash of the code is kept.
+type WalkUpCode struct {
+	KioskID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	CodeHash  string    `gorm:"type:varchar(64);not null"`
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (WalkUpCode) TableName() string { return "kiosk_walk_up_codes" }
@@ -9,6 +9,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/notification/config"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/notification/internal"
 	"github.com/gofiber/fiber/v2"
@@ -37,6 +39,9 @@ func main() {
 	defer rabbitRes.Connection.Close()
 
 	service := internal.NewNotificationService(mongoRes.Database, rabbitRes.Channel, rabbitRes.QueueName)
+	if err := service.EnsureInboxIndexes(ctx); err != nil {
+		log.Printf("failed to ensure inbox indexes: %v", err)
+	}
 	handler := internal.NewNotificationHandler(service)
 
 	consumerCh, err := rabbitRes.Connection.Channel()
@@ -72,6 +77,15 @@ func main() {
 	eventConsumer := internal.NewDomainEventConsumer(service, eventsCh, rabbitRes.EventsQueue)
 	go eventConsumer.Start(ctx)
 
+	rabbitURL := os.Getenv("RABBITMQ_URL")
+	go events.RunUntilDone(ctx, "notification-service: privacy worker", func(ctx context.Context) error {
+		return events.RunPrivacyWorker(ctx, rabbitURL, "notification", service)
+	})
+
+	go events.RunUntilDone(ctx, "notification-service: session events", func(ctx context.Context) error {
+		return events.ConsumeSessionEvents(ctx, rabbitURL, "notification", authz.RevokeSessions)
+	})
+
 	scheduler := internal.NewScheduler(service, time.Minute)
 	go scheduler.Start(ctx)
 
@@ -79,6 +79,8 @@ func (d *Dispatcher) handleMessage(ctx context.Context, body []byte) error {
 		return d.dispatchEmail(ctx, payload)
 	case string(models.ChannelPush):
 		return d.dispatchPush(ctx, payload)
+	case string(models.ChannelInApp):
+		return d.dispatchInApp(ctx, payload)
 	default:
 		log.Printf("unsupported channel %s; marking as failed", payload.Channel)
 		d.markFailed(ctx, payload)
@@ -142,6 +144,17 @@ func (d *Dispatcher) dispatchPush(ctx context.Context, payload notificationMessa
 	return nil
 }
 
+func (d *Dispatcher) dispatchInApp(ctx context.Context, payload notificationMessage) error {
+	if err := d.service.DeliverInApp(ctx, payload.HistoryID, payload.UserID, payload.Type, payload.Message, payload.Metadata); err != nil {
+		log.Printf("failed to deliver in-app notification: %v", err)
+		d.markFailed(ctx, payload)
+		return nil
+	}
+
+	d.markSent(ctx, payload)
+	return nil
+}
+
 func (d *Dispatcher) markFailed(ctx context.Context, payload notificationMessage) {
 	if err := d.service.UpdateHistoryStatus(ctx, payload.HistoryID, "failed"); err != nil {
 		log.Printf("failed to mark history %s as failed: %v", payload.HistoryID, err)
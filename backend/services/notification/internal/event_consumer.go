@@ -57,16 +57,23 @@ func (c *DomainEventConsumer) handleMessage(ctx context.Context, body []byte) er
 		return fmt.Errorf("decode booking event: %w", err)
 	}
 
-	if evt.UserID == "" || evt.BookingID == "" {
+	if evt.UserID == "" || (evt.BookingID == "" && evt.Event != events.BookingWalkUpCodeEvent) {
 		return fmt.Errorf("booking event missing identifiers")
 	}
 
 	message, metadata := buildBookingNotification(evt)
-	channels := []models.Channel{models.ChannelEmail, models.ChannelPush}
+	historyMessage := message
+	channels := []models.Channel{models.ChannelEmail, models.ChannelPush, models.ChannelInApp}
+	if evt.Event == events.BookingWalkUpCodeEvent {
+		// A one-time code books in the user's name at the kiosk: deliver it,
+		// but keep it out of the inbox and the stored history.
+		channels = []models.Channel{models.ChannelEmail, models.ChannelPush}
+		historyMessage = walkUpCodeMessage(bookingRoomDisplay(evt), "(not stored)")
+	}
 
 	for _, channel := range channels {
 		meta := cloneMetadata(metadata)
-		if err := c.service.SendNotification(ctx, evt.UserID, evt.Event, message, channel, meta); err != nil {
+		if err := c.service.sendNotification(ctx, evt.UserID, evt.Event, message, historyMessage, channel, meta); err != nil {
 			if IsChannelDisabled(err) || IsValidationError(err) {
 				continue
 			}
@@ -81,11 +88,7 @@ func buildBookingNotification(evt events.BookingEvent) (string, map[string]any)
 	startFormatted := formatEventTime(evt.StartTime)
 	endFormatted := formatEventTime(evt.EndTime)
 
-	// Use room name if available, fallback to room ID
-	roomDisplay := evt.RoomName
-	if roomDisplay == "" {
-		roomDisplay = evt.RoomID
-	}
+	roomDisplay := bookingRoomDisplay(evt)
 
 	metadata := map[string]any{
 		"booking_id": evt.BookingID,
@@ -111,6 +114,9 @@ func buildBookingNotification(evt events.BookingEvent) (string, map[string]any)
 	case events.BookingUpdatedEvent:
 		return fmt.Sprintf("Booking for room %s updated. New time: %s - %s.", roomDisplay, startFormatted, endFormatted), metadata
 	case events.BookingCancelledEvent:
+		if reason, ok := evt.Metadata["reason"].(string); ok && reason != "" {
+			return fmt.Sprintf("Booking for room %s has been cancelled: %s", roomDisplay, reason), metadata
+		}
 		return fmt.Sprintf("Booking for room %s has been cancelled.", roomDisplay), metadata
 	case events.BookingApprovedEvent:
 		return fmt.Sprintf("Booking for room %s has been approved.", roomDisplay), metadata
@@ -120,6 +126,22 @@ func buildBookingNotification(evt events.BookingEvent) (string, map[string]any)
 			return fmt.Sprintf("Booking for room %s was denied: %s", roomDisplay, reason), metadata
 		}
 		return fmt.Sprintf("Booking for room %s was denied.", roomDisplay), metadata
+	case events.BookingRelocatedEvent:
+		previous, _ := evt.Metadata["previous_room_name"].(string)
+		if previous == "" {
+			previous, _ = evt.Metadata["previous_room_id"].(string)
+		}
+		message := fmt.Sprintf("Your booking (%s - %s) has been moved from room %s to room %s.", startFormatted, endFormatted, previous, roomDisplay)
+		if reason, ok := evt.Metadata["reason"].(string); ok && reason != "" {
+			message += " Reason: " + reason
+		}
+		return message, metadata
+	case events.BookingCheckedInEvent:
+		return fmt.Sprintf("You checked in to room %s (%s - %s).", roomDisplay, startFormatted, endFormatted), metadata
+	case events.BookingWalkUpCodeEvent:
+		code, _ := evt.Metadata["code"].(string)
+		delete(metadata, "code")
+		return walkUpCodeMessage(roomDisplay, code), metadata
 	case events.BookingTransferredEvent:
 		return fmt.Sprintf("A booking for room %s has been transferred to you (%s - %s).", roomDisplay, startFormatted, endFormatted), metadata
 	default:
@@ -127,6 +149,18 @@ func buildBookingNotification(evt events.BookingEvent) (string, map[string]any)
 	}
 }
 
+// bookingRoomDisplay names the event's room, falling back to its ID.
+func bookingRoomDisplay(evt events.BookingEvent) string {
+	if evt.RoomName != "" {
+		return evt.RoomName
+	}
+	return evt.RoomID
+}
+
+func walkUpCodeMessage(roomDisplay, code string) string {
+	return fmt.Sprintf("Your code to book room %s at its kiosk is %s. It expires in 10 minutes; ignore this if you did not ask for it.", roomDisplay, code)
+}
+
 func formatEventTime(t time.Time) string {
 	if t.IsZero() {
 		return ""
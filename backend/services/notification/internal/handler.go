@@ -1,10 +1,13 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/notification/models"
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -27,10 +30,20 @@ func (h *NotificationHandler) RegisterRoutes(app *fiber.App) {
 	app.Put("/preferences", h.UpdateMyPreferences)
 	app.Get("/preferences", h.GetMyPreferences)
 
-	app.Post("/notifications/send", h.SendNotification)
-	app.Post("/notifications/schedule", h.ScheduleNotification)
+	// Sending on someone's behalf is for internal callers only.
+	sendScope := RequireServiceScope(authz.ScopeNotificationsSend)
+	app.Post("/notifications/send", sendScope, h.SendNotification)
+	app.Post("/notifications/schedule", sendScope, h.ScheduleNotification)
 	app.Get("/notifications/history/:userId", h.GetHistory)
 	app.Get("/notifications/history", h.GetMyHistory)
+
+	// In-app inbox for the authenticated user
+	app.Get("/notifications/inbox", h.ListInbox)
+	app.Get("/notifications/inbox/unread-count", h.GetUnreadCount)
+	app.Post("/notifications/inbox/read-all", h.MarkAllRead)
+	app.Post("/notifications/inbox/:id/read", h.MarkRead)
+	app.Post("/notifications/inbox/:id/unread", h.MarkUnread)
+	app.Post("/notifications/inbox/:id/archive", h.ArchiveInboxItem)
 }
 
 func (h *NotificationHandler) UpdatePreferences(c *fiber.Ctx) error {
@@ -119,7 +132,7 @@ func (h *NotificationHandler) GetMyPreferences(c *fiber.Ctx) error {
 		// Return default preferences if none exist
 		return c.JSON(fiber.Map{
 			"user_id":          userIDStr,
-			"enabled_channels": []string{"email"},
+			"enabled_channels": []string{"email", "in_app"},
 			"preferences": fiber.Map{
 				"notification_type": "email",
 				"language":          "en",
@@ -257,7 +270,7 @@ func (h *NotificationHandler) SendNotification(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	channel, err := parseChannel(req.Channel)
+	channel, err := parseSendChannel(req.Channel)
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -290,7 +303,7 @@ func (h *NotificationHandler) ScheduleNotification(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	channel, err := parseChannel(req.Channel)
+	channel, err := parseSendChannel(req.Channel)
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -315,11 +328,20 @@ func (h *NotificationHandler) ScheduleNotification(c *fiber.Ctx) error {
 	return c.Status(http.StatusAccepted).JSON(fiber.Map{"notification_id": id.Hex()})
 }
 
+// GetHistory is the legacy form of GetMyHistory and only serves the caller's
+// own history.
 func (h *NotificationHandler) GetHistory(c *fiber.Ctx) error {
 	userID := c.Params("userId")
 	if userID == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "userId is required"})
 	}
+	caller, ok := currentUserID(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if caller != userID {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
@@ -337,13 +359,123 @@ func (h *NotificationHandler) GetHistory(c *fiber.Ctx) error {
 	})
 }
 
+// parseSendChannel is parseChannel for the send and schedule endpoints. The
+// in-app inbox is only written by domain events, so a caller cannot plant
+// messages in someone's inbox.
+func parseSendChannel(value string) (models.Channel, error) {
+	channel, err := parseChannel(value)
+	if err == nil && channel == models.ChannelInApp {
+		return "", fiber.NewError(http.StatusBadRequest, "in_app notifications cannot be sent directly")
+	}
+	return channel, err
+}
+
 func parseChannel(value string) (models.Channel, error) {
 	switch value {
 	case string(models.ChannelEmail), "":
 		return models.ChannelEmail, nil
 	case string(models.ChannelPush):
 		return models.ChannelPush, nil
+	case string(models.ChannelInApp):
+		return models.ChannelInApp, nil
 	default:
 		return "", fiber.NewError(http.StatusBadRequest, "unsupported channel")
 	}
 }
+
+// ListInbox pages through the caller's in-app notifications, newest first.
+// Pass the returned next_cursor as ?cursor= to fetch the following page.
+func (h *NotificationHandler) ListInbox(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	page, err := h.service.ListInbox(c.Context(), userID, InboxQuery{
+		Cursor: c.Query("cursor"),
+		Limit:  limit,
+		Filter: c.Query("filter", InboxFilterAll),
+	})
+	if err != nil {
+		return respondInboxError(c, err)
+	}
+	return c.JSON(page)
+}
+
+// GetUnreadCount returns the caller's badge count.
+func (h *NotificationHandler) GetUnreadCount(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	count, err := h.service.UnreadCount(c.Context(), userID)
+	if err != nil {
+		return respondInboxError(c, err)
+	}
+	return c.JSON(fiber.Map{"unread_count": count})
+}
+
+func (h *NotificationHandler) MarkRead(c *fiber.Ctx) error {
+	return h.updateInboxItem(c, h.service.MarkRead)
+}
+
+func (h *NotificationHandler) MarkUnread(c *fiber.Ctx) error {
+	return h.updateInboxItem(c, h.service.MarkUnread)
+}
+
+func (h *NotificationHandler) ArchiveInboxItem(c *fiber.Ctx) error {
+	return h.updateInboxItem(c, h.service.Archive)
+}
+
+func (h *NotificationHandler) MarkAllRead(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	updated, err := h.service.MarkAllRead(c.Context(), userID)
+	if err != nil {
+		return respondInboxError(c, err)
+	}
+	return c.JSON(fiber.Map{"updated": updated, "unread_count": 0})
+}
+
+// updateInboxItem applies update to the :id item and answers with the new
+// badge count so the client can refresh it without another request.
+func (h *NotificationHandler) updateInboxItem(c *fiber.Ctx, update func(ctx context.Context, userID, id string) error) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	if err := update(c.Context(), userID, c.Params("id")); err != nil {
+		return respondInboxError(c, err)
+	}
+
+	count, err := h.service.UnreadCount(c.Context(), userID)
+	if err != nil {
+		return respondInboxError(c, err)
+	}
+	return c.JSON(fiber.Map{"unread_count": count})
+}
+
+func respondInboxError(c *fiber.Ctx, err error) error {
+	switch {
+	case IsValidationError(err):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInboxItemNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
+
+// currentUserID resolves the caller from the token subject. Inbox items are
+// keyed by user ID, so unlike the preference endpoints there is no fallback
+// to the email claim: a token without a subject is rejected.
+func currentUserID(c *fiber.Ctx) (string, bool) {
+	subject, ok := c.Locals("subject").(string)
+	return subject, ok && subject != ""
+}
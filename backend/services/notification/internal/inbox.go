@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/notification/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInboxItemNotFound = errors.New("inbox item not found")
+
+const (
+	InboxFilterAll      = "all"
+	InboxFilterUnread   = "unread"
+	InboxFilterArchived = "archived"
+)
+
+// InboxQuery pages through a user's inbox newest first. Cursor is the ID of
+// the last item of the previous page, so items arriving between requests
+// never shift the pages the way skip/limit does.
+type InboxQuery struct {
+	Cursor string
+	Limit  int
+	Filter string
+}
+
+type InboxPage struct {
+	Items       []models.InboxItem `json:"items"`
+	NextCursor  string             `json:"next_cursor,omitempty"`
+	UnreadCount int64              `json:"unread_count"`
+}
+
+// EnsureInboxIndexes creates the indexes backing cursor pagination and the
+// per-delivery uniqueness DeliverInApp relies on.
+func (s *NotificationService) EnsureInboxIndexes(ctx context.Context) error {
+	_, err := s.inboxCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "history_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	return err
+}
+
+// DeliverInApp files a dispatched notification into the user's inbox. It is
+// keyed by history ID, so a redelivered queue message does not duplicate it.
+func (s *NotificationService) DeliverInApp(ctx context.Context, historyID, userID, notifType, message string, metadata map[string]any) error {
+	if userID == "" {
+		return validationError("user_id is required")
+	}
+	if historyID == "" {
+		return validationError("history_id is required")
+	}
+
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"user_id":     userID,
+			"history_id":  historyID,
+			"type":        notifType,
+			"message":     message,
+			"metadata":    metadata,
+			"created_at":  s.clock().UTC(),
+			"read_at":     nil,
+			"archived_at": nil,
+		},
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err := s.inboxCol.UpdateOne(ctx, bson.M{"history_id": historyID}, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *NotificationService) ListInbox(ctx context.Context, userID string, q InboxQuery) (*InboxPage, error) {
+	if userID == "" {
+		return nil, validationError("user_id is required")
+	}
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+
+	filter := bson.M{"user_id": userID}
+	switch q.Filter {
+	case InboxFilterAll, "":
+		filter["archived_at"] = nil
+	case InboxFilterUnread:
+		filter["archived_at"] = nil
+		filter["read_at"] = nil
+	case InboxFilterArchived:
+		filter["archived_at"] = bson.M{"$ne": nil}
+	default:
+		return nil, validationError("filter must be all, unread or archived")
+	}
+	if q.Cursor != "" {
+		after, err := primitive.ObjectIDFromHex(q.Cursor)
+		if err != nil {
+			return nil, validationError("invalid cursor")
+		}
+		filter["_id"] = bson.M{"$lt": after}
+	}
+
+	// Fetch one extra item to learn whether another page exists.
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(q.Limit + 1))
+
+	cursor, err := s.inboxCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]models.InboxItem, 0, q.Limit)
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	page := &InboxPage{Items: items}
+	if len(items) > q.Limit {
+		page.Items = items[:q.Limit]
+		page.NextCursor = page.Items[q.Limit-1].ID.Hex()
+	}
+
+	page.UnreadCount, err = s.UnreadCount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// UnreadCount is the badge count: unread items that have not been archived.
+func (s *NotificationService) UnreadCount(ctx context.Context, userID string) (int64, error) {
+	if userID == "" {
+		return 0, validationError("user_id is required")
+	}
+	return s.inboxCol.CountDocuments(ctx, bson.M{
+		"user_id":     userID,
+		"read_at":     nil,
+		"archived_at": nil,
+	})
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userID, idHex string) error {
+	return s.updateInboxItem(ctx, userID, idHex, bson.M{"read_at": s.clock().UTC()})
+}
+
+func (s *NotificationService) MarkUnread(ctx context.Context, userID, idHex string) error {
+	return s.updateInboxItem(ctx, userID, idHex, bson.M{"read_at": nil})
+}
+
+func (s *NotificationService) Archive(ctx context.Context, userID, idHex string) error {
+	return s.updateInboxItem(ctx, userID, idHex, bson.M{"archived_at": s.clock().UTC()})
+}
+
+// MarkAllRead marks every unread, unarchived item as read and reports how
+// many changed.
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	if userID == "" {
+		return 0, validationError("user_id is required")
+	}
+	filter := bson.M{
+		"user_id":     userID,
+		"read_at":     nil,
+		"archived_at": nil,
+	}
+	result, err := s.inboxCol.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read_at": s.clock().UTC()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// updateInboxItem scopes the update to the owner, so another user's item
+// reads as not found.
+func (s *NotificationService) updateInboxItem(ctx context.Context, userID, idHex string, fields bson.M) error {
+	if userID == "" {
+		return validationError("user_id is required")
+	}
+	id, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return ErrInboxItemNotFound
+	}
+
+	result, err := s.inboxCol.UpdateOne(ctx, bson.M{"_id": id, "user_id": userID}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInboxItemNotFound
+	}
+	return nil
+}
@@ -3,15 +3,22 @@ package internal
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"log"
 	"strings"
+	"sync"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
 	"github.com/gofiber/fiber/v2"
 )
 
 type JWTClaims struct {
-	Email   string `json:"email"`
-	Role    string `json:"role"`
-	Subject string `json:"sub"`
+	Email       string       `json:"email"`
+	Role        string       `json:"role"`
+	Permissions []string     `json:"permissions"`
+	Groups      []string     `json:"groups"`
+	Act         *authz.Actor `json:"act"`
+	Subject     string       `json:"sub"`
 }
 
 // ExtractJWTClaims middleware extracts JWT claims from the Authorization header
@@ -34,12 +41,6 @@ func ExtractJWTClaims() fiber.Handler {
 			c.Locals("user_id", email)
 		}
 
-		// If we already have both, we're done
-		if email != "" && userID != "" {
-			return c.Next()
-		}
-
-		// Try to extract from JWT token (without validation, since Kong already validated)
 		authHeader := c.Get("Authorization")
 		if !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
 			return c.Next()
@@ -68,17 +69,35 @@ func ExtractJWTClaims() fiber.Handler {
 			return c.Next()
 		}
 
+		// Kong checks the signature, but only the auth service knows whether
+		// the token has been revoked since it was issued.
+		if err := authz.CheckSession(c.UserContext(), "notification-service", tokenStr, claims.Subject); err != nil {
+			fe := err.(*fiber.Error)
+			return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+		}
+
 		// Set locals from JWT claims
 		if claims.Email != "" && email == "" {
 			c.Locals("email", claims.Email)
 			email = claims.Email
 		}
+		if claims.Subject != "" {
+			c.Locals("subject", claims.Subject)
+		}
 		if claims.Subject != "" && userID == "" {
 			c.Locals("user_id", claims.Subject)
 			userID = claims.Subject
 		}
 		if claims.Role != "" {
 			c.Locals("role", claims.Role)
+			c.Locals("permissions", authz.EffectivePermissions(claims.Role, claims.Permissions))
+			c.Locals("groups", claims.Groups)
+		}
+		if claims.Act != nil {
+			// Impersonation token: keep the admin alongside the user.
+			c.Locals("impersonator", claims.Act)
+			c.SetUserContext(authz.WithActor(c.UserContext(), claims.Act))
+			log.Printf("%s %s by %s on behalf of %s", c.Method(), c.Path(), claims.Act.Subject, claims.Subject)
 		}
 
 		// Use email as fallback user_id if we still don't have one
@@ -89,3 +108,36 @@ func ExtractJWTClaims() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequireServiceScope admits only internal callers presenting a service
+// account API key, as X-API-Key or "Authorization: ApiKey <key>", that was
+// issued with scope.
+func RequireServiceScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := strings.TrimSpace(c.Get("X-API-Key"))
+		if auth := c.Get("Authorization"); key == "" && strings.HasPrefix(strings.ToLower(auth), "apikey ") {
+			key = strings.TrimSpace(auth[7:])
+		}
+		if key == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "service api key required"})
+		}
+
+		identity, err := apiKeys().Verify(c.UserContext(), key)
+		switch {
+		case errors.Is(err, authz.ErrInvalidAPIKey):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid api key"})
+		case err != nil:
+			log.Printf("notification-service: %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "api key could not be verified"})
+		}
+		if !identity.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "api key missing scope " + scope})
+		}
+
+		c.Locals("serviceAccount", identity.Name)
+		return c.Next()
+	}
+}
+
+// apiKeys asks the auth service which service account an API key belongs to.
+var apiKeys = sync.OnceValue(authz.NewAPIKeyVerifierFromEnv)
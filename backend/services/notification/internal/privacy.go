@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"context"
+
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/notification/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// privacyFilter matches documents keyed by the user's ID or, for callers that
+// fell back to it, their email.
+func privacyFilter(req events.PrivacyRequest) bson.M {
+	keys := []string{req.UserID}
+	if req.Email != "" {
+		keys = append(keys, req.Email)
+	}
+	return bson.M{"user_id": bson.M{"$in": keys}}
+}
+
+// ExportUserData implements events.PrivacyHandler.
+func (s *NotificationService) ExportUserData(ctx context.Context, req events.PrivacyRequest) (map[string]any, error) {
+	filter := privacyFilter(req)
+
+	var prefs []models.NotificationPreference
+	if err := findAll(ctx, s.prefsCol, filter, &prefs); err != nil {
+		return nil, err
+	}
+	var history []models.NotificationHistory
+	if err := findAll(ctx, s.historyCol, filter, &history); err != nil {
+		return nil, err
+	}
+	var scheduled []models.ScheduledNotification
+	if err := findAll(ctx, s.scheduleCol, filter, &scheduled); err != nil {
+		return nil, err
+	}
+	var inbox []models.InboxItem
+	if err := findAll(ctx, s.inboxCol, filter, &inbox); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"preferences":             prefs,
+		"notification_history":    history,
+		"scheduled_notifications": scheduled,
+		"inbox":                   inbox,
+	}, nil
+}
+
+// EraseUserData implements events.PrivacyHandler. Notifications only exist to
+// reach the user, so they are deleted rather than pseudonymised.
+func (s *NotificationService) EraseUserData(ctx context.Context, req events.PrivacyRequest) error {
+	filter := privacyFilter(req)
+	if _, err := s.prefsCol.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	if _, err := s.historyCol.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	if _, err := s.scheduleCol.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	_, err := s.inboxCol.DeleteMany(ctx, filter)
+	return err
+}
+
+func findAll(ctx context.Context, col *mongo.Collection, filter bson.M, out any) error {
+	cursor, err := col.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, out)
+}
@@ -40,6 +40,7 @@ type NotificationService struct {
 	prefsCol    *mongo.Collection
 	historyCol  *mongo.Collection
 	scheduleCol *mongo.Collection
+	inboxCol    *mongo.Collection
 	amqpChannel *amqp.Channel
 	queueName   string
 	clock       func() time.Time
@@ -50,6 +51,7 @@ func NewNotificationService(db *mongo.Database, ch *amqp.Channel, queueName stri
 		prefsCol:    db.Collection("notification_preferences"),
 		historyCol:  db.Collection("notification_history"),
 		scheduleCol: db.Collection("scheduled_notifications"),
+		inboxCol:    db.Collection("notification_inbox"),
 		amqpChannel: ch,
 		queueName:   queueName,
 		clock:       time.Now,
@@ -72,10 +74,11 @@ func (s *NotificationService) UpdatePreferences(ctx context.Context, pref models
 
 	update := bson.M{
 		"$set": bson.M{
-			"user_id":          pref.UserID,
-			"enabled_channels": pref.EnabledChannels,
-			"preferences":      pref.Preferences,
-			"updated_at":       pref.UpdatedAt,
+			"user_id":           pref.UserID,
+			"enabled_channels":  pref.EnabledChannels,
+			"disabled_channels": disabledChannels(pref.EnabledChannels),
+			"preferences":       pref.Preferences,
+			"updated_at":        pref.UpdatedAt,
 		},
 		"$setOnInsert": bson.M{
 			"created_at": pref.CreatedAt,
@@ -101,10 +104,42 @@ func (s *NotificationService) GetPreferences(ctx context.Context, userID string)
 		}
 		return nil, err
 	}
+	// The in-app inbox arrived after many users had saved preferences; treat
+	// it as on unless the user has switched it off since.
+	if !hasChannel(pref.EnabledChannels, models.ChannelInApp) && !hasChannel(pref.DisabledChannels, models.ChannelInApp) {
+		pref.EnabledChannels = append(pref.EnabledChannels, models.ChannelInApp)
+	}
 	return &pref, nil
 }
 
+// disabledChannels is the complement of enabled within the supported channels.
+func disabledChannels(enabled []models.Channel) []models.Channel {
+	disabled := make([]models.Channel, 0, len(models.AllChannels))
+	for _, ch := range models.AllChannels {
+		if !hasChannel(enabled, ch) {
+			disabled = append(disabled, ch)
+		}
+	}
+	return disabled
+}
+
+func hasChannel(channels []models.Channel, channel models.Channel) bool {
+	for _, ch := range channels {
+		if ch == channel {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *NotificationService) SendNotification(ctx context.Context, userID, notifType, message string, channel models.Channel, metadata map[string]any) error {
+	return s.sendNotification(ctx, userID, notifType, message, message, channel, metadata)
+}
+
+// sendNotification delivers message but records historyMessage in the
+// history, so a message carrying a one-time credential can be stored
+// redacted.
+func (s *NotificationService) sendNotification(ctx context.Context, userID, notifType, message, historyMessage string, channel models.Channel, metadata map[string]any) error {
 	if userID == "" {
 		return validationError("user_id is required")
 	}
@@ -123,7 +158,7 @@ func (s *NotificationService) SendNotification(ctx context.Context, userID, noti
 		ID:       docID,
 		UserID:   userID,
 		Type:     notifType,
-		Message:  message,
+		Message:  historyMessage,
 		Channel:  channel,
 		SentAt:   sentAt,
 		Status:   "queued",
@@ -353,10 +388,8 @@ func (s *NotificationService) ensureChannelAllowed(ctx context.Context, userID s
 		// default: allow all channels
 		return nil
 	}
-	for _, ch := range pref.EnabledChannels {
-		if ch == channel {
-			return nil
-		}
+	if hasChannel(pref.EnabledChannels, channel) {
+		return nil
 	}
 	return channelDisabledError(userID, channel)
 }
@@ -11,9 +11,9 @@ import (
 )
 
 type UserResolver struct {
-	baseURL      string
-	httpClient   *http.Client
-	serviceToken string
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
 }
 
 func NewUserResolver(baseURL string) *UserResolver {
@@ -26,7 +26,7 @@ func NewUserResolver(baseURL string) *UserResolver {
 		httpClient: &http.Client{
 			Timeout: 3 * time.Second,
 		},
-		serviceToken: strings.TrimSpace(os.Getenv("SERVICE_API_TOKEN")),
+		apiKey: strings.TrimSpace(os.Getenv("SERVICE_API_KEY")),
 	}
 }
 
@@ -49,8 +49,8 @@ func (r *UserResolver) ResolveEmail(ctx context.Context, userID string) (string,
 		return "", err
 	}
 
-	if r.serviceToken != "" {
-		req.Header.Set("X-Service-Token", r.serviceToken)
+	if r.apiKey != "" {
+		req.Header.Set("X-API-Key", r.apiKey)
 	}
 
 	resp, err := r.httpClient.Do(req)
@@ -11,15 +11,22 @@ type Channel string
 const (
 	ChannelEmail Channel = "email"
 	ChannelPush  Channel = "push"
+	ChannelInApp Channel = "in_app"
 )
 
+// AllChannels lists every delivery channel the service supports.
+var AllChannels = []Channel{ChannelEmail, ChannelPush, ChannelInApp}
+
 type NotificationPreference struct {
 	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	UserID          string             `bson:"user_id" json:"user_id"`
 	EnabledChannels []Channel          `bson:"enabled_channels" json:"enabled_channels"`
-	Preferences     map[string]any     `bson:"preferences,omitempty" json:"preferences,omitempty"`
-	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
-	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	// DisabledChannels records the channels the user switched off, so channels
+	// added after the preferences were saved can default to on.
+	DisabledChannels []Channel      `bson:"disabled_channels,omitempty" json:"-"`
+	Preferences      map[string]any `bson:"preferences,omitempty" json:"preferences,omitempty"`
+	UpdatedAt        time.Time      `bson:"updated_at" json:"updated_at"`
+	CreatedAt        time.Time      `bson:"created_at" json:"created_at"`
 }
 
 type NotificationHistory struct {
@@ -45,3 +52,17 @@ type ScheduledNotification struct {
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 }
+
+// InboxItem is a notification delivered over the in-app channel. ReadAt and
+// ArchivedAt stay null until the user acts on the item.
+type InboxItem struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	HistoryID  string             `bson:"history_id" json:"history_id"`
+	Type       string             `bson:"type" json:"type"`
+	Message    string             `bson:"message" json:"message"`
+	Metadata   map[string]any     `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ReadAt     *time.Time         `bson:"read_at" json:"read_at"`
+	ArchivedAt *time.Time         `bson:"archived_at" json:"archived_at"`
+}
@@ -0,0 +1,62 @@
+// Command sensorsim feeds simulated occupancy readings to the room service,
+// for trying out sensor ingestion without hardware. It needs the key of a
+// registered sensor:
+//
+//	SENSOR_KEY=cps_... ROOM_SERVICE_URL=http://localhost:8082 go run ./cmd/sensorsim
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	key := os.Getenv("SENSOR_KEY")
+	if key == "" {
+		log.Fatal("SENSOR_KEY is required")
+	}
+	baseURL := os.Getenv("ROOM_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8082"
+	}
+	interval := 10 * time.Second
+	if v := os.Getenv("SENSOR_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid SENSOR_INTERVAL: %v", err)
+		}
+		interval = d
+	}
+
+	// People arrive and leave a few at a time, so the room stays occupied
+	// or vacant for a while between changes.
+	people := 0
+	for {
+		people = max(people+rand.Intn(5)-2, 0)
+		body, _ := json.Marshal(map[string]any{
+			"people_count": people,
+			"recorded_at":  time.Now().UTC(),
+		})
+
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/rooms/occupancy/readings", bytes.NewReader(body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Printf("sensorsim: send failed: %v", err)
+		} else {
+			resp.Body.Close()
+			log.Printf("sensorsim: sent people_count=%d: %s", people, resp.Status)
+		}
+		time.Sleep(interval)
+	}
+}
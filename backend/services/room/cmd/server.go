@@ -1,25 +1,74 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"os"
+	"time"
+	_ "time/tzdata"
 
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	events "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/room"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/config"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/internal"
-	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
 	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	db := config.ConnectDB()
-	db.AutoMigrate(&models.Room{})
+	if err := config.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
 	config.SeedDefaultRooms(db)
 
+	// Event bus. Rooms are still served without it; other services then
+	// fall back to reading the rooms table.
+	var publisher internal.EventPublisher
+	for attempt := 1; attempt <= 5; attempt++ {
+		p, err := events.NewRabbitPublisher(os.Getenv("RABBITMQ_URL"))
+		if err == nil {
+			defer p.Close()
+			publisher = p
+			break
+		}
+		wait := time.Duration(attempt) * time.Second
+		log.Printf("room-service: failed to connect event publisher (attempt %d/5): %v; retrying in %s", attempt, err, wait)
+		time.Sleep(wait)
+	}
+	if publisher == nil {
+		log.Println("room-service: event bus unavailable; room events disabled")
+	}
+
+	go events.RunUntilDone(context.Background(), "room-service: session events", func(ctx context.Context) error {
+		return events.ConsumeSessionEvents(ctx, os.Getenv("RABBITMQ_URL"), "room", authz.RevokeSessions)
+	})
+
 	roomRepo := internal.NewRoomRepository(db)
-	roomService := internal.NewRoomService(roomRepo)
+	roomService := internal.NewRoomService(roomRepo, publisher)
 	roomHandler := internal.NewRoomHandler(roomService)
 
+	grpcPort := os.Getenv("ROOM_GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "50053"
+	}
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterRoomServiceServer(grpcServer, internal.NewRoomGRPCServer(roomHandler))
+	go func() {
+		log.Printf("Room gRPC server running on :%s", grpcPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
 	app := fiber.New()
+	app.Use(authz.SessionCheck("room-service"))
 
 	roomHandler.RegisterRoutes(app)
 
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationsTable records which schema versions have been applied. The
+// database is shared with the other services, hence the prefix.
+const migrationsTable = "room_schema_migrations"
+
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrate applies every migration in migrations/ that has not run yet, in
+// version order. Files are named <version>_<name>.sql. Each migration runs in
+// its own transaction under an advisory lock so replicas starting together
+// do not race.
+func Migrate(db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("migrate: database handle is nil")
+	}
+
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + migrationsTable + ` (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`).Error; err != nil {
+		return fmt.Errorf("create %s: %w", migrationsTable, err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		applied := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext(?))`, migrationsTable).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Table(migrationsTable).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := tx.Exec(m.SQL).Error; err != nil {
+				return err
+			}
+			applied = true
+			return tx.Exec(`INSERT INTO `+migrationsTable+` (version, name) VALUES (?, ?)`, m.Version, m.Name).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+
+	return nil
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		file := entry.Name()
+		versionStr, name, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), "_")
+		version, err := strconv.Atoi(versionStr)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.sql", file)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, file, version)
+		}
+		seen[version] = file
+
+		body, err := migrationFiles.ReadFile("migrations/" + file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
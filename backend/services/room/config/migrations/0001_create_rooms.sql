@@ -0,0 +1,10 @@
+-- Baseline schema, matching what AutoMigrate created before migrations were
+-- versioned. Existing databases already have this table.
+CREATE TABLE IF NOT EXISTS rooms (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name text NOT NULL,
+    capacity bigint,
+    features jsonb
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rooms_name ON rooms (name);
@@ -0,0 +1,20 @@
+-- Location and accessibility attributes used for searching and sorting rooms.
+ALTER TABLE rooms
+    ADD COLUMN IF NOT EXISTS building varchar(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS floor integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS room_number varchar(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS latitude double precision,
+    ADD COLUMN IF NOT EXISTS longitude double precision,
+    ADD COLUMN IF NOT EXISTS accessible boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS time_zone varchar(64) NOT NULL DEFAULT 'Asia/Bangkok',
+    ADD COLUMN IF NOT EXISTS description text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_rooms_building_floor ON rooms (building, floor);
+CREATE INDEX IF NOT EXISTS idx_rooms_accessible ON rooms (accessible);
+
+-- The seeded rooms encode their floor and number in the name.
+UPDATE rooms SET floor = 1, room_number = '101' WHERE id = '11111111-1111-1111-1111-111111111111' AND room_number = '';
+UPDATE rooms SET floor = 2, room_number = '203' WHERE id = '22222222-2222-2222-2222-222222222222' AND room_number = '';
+UPDATE rooms SET floor = 3, room_number = '305' WHERE id = '33333333-3333-3333-3333-333333333333' AND room_number = '';
+UPDATE rooms SET floor = 5, room_number = '507' WHERE id = '44444444-4444-4444-4444-444444444444' AND room_number = '';
+UPDATE rooms SET floor = 9, room_number = '903' WHERE id = '55555555-5555-5555-5555-555555555555' AND room_number = '';
@@ -0,0 +1,41 @@
+-- Weekly opening hours, dated exceptions and imported holiday calendars.
+-- Rows apply to one room (room_id set), a building (building set) or every
+-- room (neither set); the most specific scope wins.
+CREATE TABLE IF NOT EXISTS room_holiday_calendars (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name varchar(100) NOT NULL,
+    building varchar(100) NOT NULL DEFAULT '',
+    event_count integer NOT NULL DEFAULT 0,
+    imported_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_holiday_calendars_name_building ON room_holiday_calendars (name, building);
+
+CREATE TABLE IF NOT EXISTS room_opening_hours (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid REFERENCES rooms (id) ON DELETE CASCADE,
+    building varchar(100) NOT NULL DEFAULT '',
+    weekday smallint NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_minute integer NOT NULL CHECK (opens_minute BETWEEN 0 AND 1440),
+    closes_minute integer NOT NULL CHECK (closes_minute BETWEEN 0 AND 1440),
+    CHECK (closes_minute > opens_minute)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_opening_hours_room ON room_opening_hours (room_id);
+CREATE INDEX IF NOT EXISTS idx_room_opening_hours_building ON room_opening_hours (building);
+
+CREATE TABLE IF NOT EXISTS room_schedule_exceptions (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid REFERENCES rooms (id) ON DELETE CASCADE,
+    building varchar(100) NOT NULL DEFAULT '',
+    date date NOT NULL,
+    closed boolean NOT NULL DEFAULT true,
+    opens_minute integer NOT NULL DEFAULT 0,
+    closes_minute integer NOT NULL DEFAULT 0,
+    reason varchar(200) NOT NULL DEFAULT '',
+    calendar_id uuid REFERENCES room_holiday_calendars (id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_schedule_exceptions_date ON room_schedule_exceptions (date);
+CREATE INDEX IF NOT EXISTS idx_room_schedule_exceptions_room ON room_schedule_exceptions (room_id);
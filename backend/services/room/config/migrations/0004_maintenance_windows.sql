@@ -0,0 +1,13 @@
+-- Maintenance blackouts. The booking service treats a room as unavailable
+-- while a window is in effect.
+CREATE TABLE IF NOT EXISTS room_maintenance_windows (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz NOT NULL,
+    reason varchar(200) NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_maintenance_windows_room_time ON room_maintenance_windows (room_id, starts_at, ends_at);
@@ -0,0 +1,5 @@
+-- Rooms are archived instead of deleted so bookings and their history keep
+-- resolving the room. Archived rooms are hidden from listings and search.
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS archived_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_rooms_active ON rooms (name) WHERE archived_at IS NULL;
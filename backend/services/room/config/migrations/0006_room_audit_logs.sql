@@ -0,0 +1,28 @@
+-- Who changed which room, schedule or maintenance window. Entries are only
+-- ever appended.
+CREATE TABLE IF NOT EXISTS room_audit_logs (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid,
+    action varchar(50) NOT NULL,
+    actor_id varchar(100) NOT NULL,
+    actor_email varchar(255) NOT NULL DEFAULT '',
+    impersonator_id varchar(100),
+    method varchar(10) NOT NULL,
+    path text NOT NULL,
+    details jsonb,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_audit_logs_room ON room_audit_logs (room_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_room_audit_logs_actor ON room_audit_logs (actor_id, created_at);
+
+CREATE OR REPLACE FUNCTION room_audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'room_audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS room_audit_logs_append_only ON room_audit_logs;
+CREATE TRIGGER room_audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON room_audit_logs
+    FOR EACH ROW EXECUTE FUNCTION room_audit_logs_append_only();
@@ -0,0 +1,3 @@
+-- Incremented on every change to a room; exposed as its ETag for optimistic
+-- concurrency.
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
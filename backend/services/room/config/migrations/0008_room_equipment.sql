@@ -0,0 +1,16 @@
+-- Equipment kept in a room. Movable items can be reserved as booking add-ons;
+-- the booking service counts reservations against quantity.
+CREATE TABLE IF NOT EXISTS room_equipment (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    quantity integer NOT NULL DEFAULT 1 CHECK (quantity >= 0),
+    condition varchar(20) NOT NULL DEFAULT 'working' CHECK (condition IN ('working', 'degraded', 'broken')),
+    movable boolean NOT NULL DEFAULT false,
+    notes text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_equipment_room ON room_equipment (room_id);
+CREATE INDEX IF NOT EXISTS idx_room_equipment_movable ON room_equipment (movable) WHERE movable;
@@ -0,0 +1,15 @@
+-- Named seating layouts. A layout's capacity replaces the room's when it is
+-- requested, and bookings in it block the room for the setup and teardown
+-- time around the booked window.
+CREATE TABLE IF NOT EXISTS room_layouts (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    name varchar(50) NOT NULL,
+    capacity integer NOT NULL CHECK (capacity >= 1),
+    setup_minutes integer NOT NULL DEFAULT 0 CHECK (setup_minutes >= 0),
+    teardown_minutes integer NOT NULL DEFAULT 0 CHECK (teardown_minutes >= 0),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_layouts_room_name ON room_layouts (room_id, lower(name));
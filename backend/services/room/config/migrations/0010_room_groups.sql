@@ -0,0 +1,27 @@
+-- Room groups form a tree, e.g. campus > building > wing, for browsing and
+-- filtering rooms.
+CREATE TABLE IF NOT EXISTS room_groups (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    parent_id uuid REFERENCES room_groups (id),
+    name varchar(100) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CHECK (parent_id <> id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_groups_parent ON room_groups (parent_id);
+
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS group_id uuid REFERENCES room_groups (id);
+CREATE INDEX IF NOT EXISTS idx_rooms_group ON rooms (group_id) WHERE group_id IS NOT NULL;
+
+-- A combined room, such as Hall A+B, is booked as one room but occupies its
+-- parts. The booking service treats a booking of either as blocking the other.
+CREATE TABLE IF NOT EXISTS room_combinations (
+    combined_room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    part_room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    PRIMARY KEY (combined_room_id, part_room_id),
+    CHECK (combined_room_id <> part_room_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_combinations_part ON room_combinations (part_room_id);
@@ -0,0 +1,28 @@
+-- Occupancy sensors authenticate with their own key; only its SHA-256 hash
+-- is stored.
+CREATE TABLE IF NOT EXISTS room_sensors (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    key_prefix varchar(16) NOT NULL,
+    key_hash varchar(64) NOT NULL UNIQUE,
+    last_seen_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_sensors_room ON room_sensors (room_id);
+
+-- Time series of sensor readings. The latest reading of a room is its
+-- current occupancy.
+CREATE TABLE IF NOT EXISTS room_occupancy_readings (
+    id bigserial PRIMARY KEY,
+    room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    sensor_id uuid NOT NULL REFERENCES room_sensors (id) ON DELETE CASCADE,
+    recorded_at timestamptz NOT NULL,
+    received_at timestamptz NOT NULL DEFAULT now(),
+    occupied boolean NOT NULL,
+    people_count integer CHECK (people_count >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_occupancy_readings_room_time ON room_occupancy_readings (room_id, recorded_at DESC);
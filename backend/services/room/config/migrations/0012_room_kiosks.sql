@@ -0,0 +1,15 @@
+-- Display tablets mounted outside a room. Like sensors, they authenticate
+-- with their own key and only its SHA-256 hash is stored. The booking service
+-- reads this table to serve the kiosk API.
+CREATE TABLE IF NOT EXISTS room_kiosks (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    key_prefix varchar(16) NOT NULL,
+    key_hash varchar(64) NOT NULL UNIQUE,
+    last_seen_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_kiosks_room ON room_kiosks (room_id);
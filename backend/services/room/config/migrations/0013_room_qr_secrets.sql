@@ -0,0 +1,8 @@
+-- Secret each room's QR code link is signed with. It is kept in the clear
+-- because the booking service needs it to check scanned links; rotating it
+-- invalidates every printed code of the room.
+CREATE TABLE IF NOT EXISTS room_qr_secrets (
+    room_id uuid PRIMARY KEY REFERENCES rooms (id) ON DELETE CASCADE,
+    secret bytea NOT NULL,
+    rotated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,4 @@
+-- Audit entries identify the actor by user ID only. The email was personal
+-- data the privacy erasure never reached; the ID survives erasure as a
+-- pseudonym.
+ALTER TABLE room_audit_logs DROP COLUMN IF EXISTS actor_email;
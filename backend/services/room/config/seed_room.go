@@ -13,24 +13,24 @@ func SeedDefaultRooms(db *gorm.DB) {
 	}
 
 	queries := []string{
-		`INSERT INTO rooms (id, name, capacity, features)
-		 VALUES ('11111111-1111-1111-1111-111111111111', 'Floor 1 Room 101', 10, '["Projector","Whiteboard","Air Conditioning","HDMI"]')
+		`INSERT INTO rooms (id, name, capacity, features, floor, room_number)
+		 VALUES ('11111111-1111-1111-1111-111111111111', 'Floor 1 Room 101', 10, '["Projector","Whiteboard","Air Conditioning","HDMI"]', 1, '101')
 		 ON CONFLICT (id) DO NOTHING;`,
 
-		`INSERT INTO rooms (id, name, capacity, features)
-		 VALUES ('22222222-2222-2222-2222-222222222222', 'Floor 2 Room 203', 15, '["Projector","Whiteboard","HDMI"]')
+		`INSERT INTO rooms (id, name, capacity, features, floor, room_number)
+		 VALUES ('22222222-2222-2222-2222-222222222222', 'Floor 2 Room 203', 15, '["Projector","Whiteboard","HDMI"]', 2, '203')
 		 ON CONFLICT (id) DO NOTHING;`,
 
-		`INSERT INTO rooms (id, name, capacity, features)
-		 VALUES ('33333333-3333-3333-3333-333333333333', 'Floor 3 Room 305', 8, '["Whiteboard","Air Conditioning"]')
+		`INSERT INTO rooms (id, name, capacity, features, floor, room_number)
+		 VALUES ('33333333-3333-3333-3333-333333333333', 'Floor 3 Room 305', 8, '["Whiteboard","Air Conditioning"]', 3, '305')
 		 ON CONFLICT (id) DO NOTHING;`,
 
-		`INSERT INTO rooms (id, name, capacity, features)
-		 VALUES ('44444444-4444-4444-4444-444444444444', 'Floor 5 Room 507', 20, '["Projector","Air Conditioning","HDMI"]')
+		`INSERT INTO rooms (id, name, capacity, features, floor, room_number)
+		 VALUES ('44444444-4444-4444-4444-444444444444', 'Floor 5 Room 507', 20, '["Projector","Air Conditioning","HDMI"]', 5, '507')
 		 ON CONFLICT (id) DO NOTHING;`,
 
-		`INSERT INTO rooms (id, name, capacity, features)
-		 VALUES ('55555555-5555-5555-5555-555555555555', 'Floor 9 Room 903', 12, '["Whiteboard","Projector","HDMI"]')
+		`INSERT INTO rooms (id, name, capacity, features, floor, room_number)
+		 VALUES ('55555555-5555-5555-5555-555555555555', 'Floor 9 Room 903', 12, '["Whiteboard","Projector","HDMI"]', 9, '903')
 		 ON CONFLICT (id) DO NOTHING;`,
 	}
 
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrBookingServiceUnavailable = errors.New("booking service unavailable")
+	ErrRoomHasBookings           = errors.New("room has upcoming bookings; retry with force to cancel them")
+)
+
+// archiveResult reports what archiving a room did or, for a dry run, would do.
+type archiveResult struct {
+	Room *models.Room
+	// Impacted are the upcoming bookings found before archiving.
+	Impacted *pb.DisplaceBookingsResponse
+	// Cancelled is set when force cancelled the bookings; CancelErr when
+	// cancelling them failed after the room was archived.
+	Cancelled *pb.DisplaceBookingsResponse
+	CancelErr error
+}
+
+// archiveRoom archives a room once the booking service confirms it has no
+// pending or confirmed bookings that have not ended yet. With force those
+// bookings are cancelled and their owners notified; with dryRun nothing is
+// changed. ErrRoomHasBookings comes with the impacted bookings in the result.
+// service is the caller's uncommitted RoomChange; commit audits and commits
+// it once the room is archived, before any booking is cancelled.
+func archiveRoom(ctx context.Context, service RoomService, bookings pb.BookingServiceClient, id uuid.UUID, force, dryRun bool, commit func() error) (*archiveResult, error) {
+	room, err := service.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if room.ArchivedAt != nil {
+		return nil, ErrRoomArchived
+	}
+	if bookings == nil {
+		return nil, ErrBookingServiceUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Everything from now on: bookings in progress are impacted as well.
+	req := &pb.DisplaceBookingsRequest{
+		RoomId: id.String(),
+		Start:  timestamppb.Now(),
+		End:    timestamppb.New(time.Now().AddDate(100, 0, 0)),
+		Action: "list",
+		Reason: "room " + room.Name + " has been archived",
+		Cause:  "archived",
+	}
+	impacted, err := bookings.DisplaceBookings(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBookingServiceUnavailable, err)
+	}
+
+	result := &archiveResult{Room: room, Impacted: impacted}
+	if dryRun {
+		return result, nil
+	}
+	if len(impacted.Bookings) > 0 && !force {
+		return result, ErrRoomHasBookings
+	}
+
+	if result.Room, err = service.Archive(id); err != nil {
+		return nil, err
+	}
+	if err := commit(); err != nil {
+		return nil, err
+	}
+	if !force {
+		return result, nil
+	}
+
+	// Listing again after archiving also catches bookings made in between;
+	// the archived room accepts no new ones.
+	req.Action = "cancel"
+	result.Cancelled, result.CancelErr = bookings.DisplaceBookings(ctx, req)
+	return result, nil
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Locals shared by handlers and the audit middleware.
+const (
+	// auditRoomIDKey names the room a route without :id changed, e.g. a
+	// newly created room.
+	auditRoomIDKey = "audit_room_id"
+	// auditSkipKey marks requests that changed nothing, such as dry runs.
+	auditSkipKey = "audit_skip"
+	// auditedRequestKey holds the *auditedRequest set by writeAccess.
+	auditedRequestKey = "audited_request"
+)
+
+// ErrAuditFailed means a change was rolled back because its audit entry
+// could not be written.
+var ErrAuditFailed = errors.New("failed to record the change in the audit log; nothing was saved")
+
+// maxAuditDetails caps the request body copied into an audit entry.
+const maxAuditDetails = 16 * 1024
+
+// AuditFilter selects room audit entries. Zero values mean "no filter".
+type AuditFilter struct {
+	RoomID   *uuid.UUID
+	ActorID  string
+	Action   string
+	Page     int
+	PageSize int
+}
+
+// auditedRequest is the change a request guarded by writeAccess makes.
+type auditedRequest struct {
+	change *RoomChange
+	claims *jwtClaims
+	action string
+}
+
+// writeAccess guards a mutating route: the caller needs room:write, which
+// admins hold. The handler's change is made through serviceFor and, after a
+// successful response, committed with an entry for action in the room audit
+// log. When the entry cannot be written nothing is saved and the request
+// fails.
+func (h *RoomHandler) writeAccess(action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, err := requirePermission(c, authz.PermRoomWrite)
+		if err != nil {
+			return respondError(c, err)
+		}
+		c.SetUserContext(authz.WithActor(c.UserContext(), claims.Act))
+
+		change, err := h.service.BeginChange()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		defer change.Rollback()
+		c.Locals(auditedRequestKey, &auditedRequest{change: change, claims: claims, action: action})
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if change.Done() || c.Response().StatusCode() >= fiber.StatusMultipleChoices {
+			return nil
+		}
+		if skip, _ := c.Locals(auditSkipKey).(bool); skip {
+			return nil
+		}
+		if err := h.commitChange(c); err != nil {
+			c.Response().ResetBody()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return nil
+	}
+}
+
+// serviceFor returns the service a handler changes rooms through: the
+// request's uncommitted change under writeAccess, the plain service otherwise.
+func (h *RoomHandler) serviceFor(c *fiber.Ctx) RoomService {
+	if req, ok := c.Locals(auditedRequestKey).(*auditedRequest); ok && !req.change.Done() {
+		return req.change.Service()
+	}
+	return h.service
+}
+
+// commitChange audits and commits the request's change. writeAccess does so
+// after the handler; handlers call it earlier when other services must see
+// the change, e.g. before cancelling the bookings of an archived room.
+func (h *RoomHandler) commitChange(c *fiber.Ctx) error {
+	req, ok := c.Locals(auditedRequestKey).(*auditedRequest)
+	if !ok {
+		return nil
+	}
+
+	entry := &models.RoomAuditLog{
+		Action:  req.action,
+		ActorID: req.claims.Subject,
+		Method:  c.Method(),
+		Path:    c.Path(),
+	}
+	if id, err := uuid.Parse(c.Params("id")); err == nil {
+		entry.RoomID = &id
+	} else if id, ok := c.Locals(auditRoomIDKey).(uuid.UUID); ok {
+		entry.RoomID = &id
+	}
+	if req.claims.Act != nil {
+		entry.ImpersonatorID = &req.claims.Act.Subject
+	}
+	if body := c.Body(); len(body) > 0 && len(body) <= maxAuditDetails && json.Valid(body) {
+		entry.Details = models.RawJSON(append([]byte(nil), body...))
+	}
+
+	if err := req.change.Commit(entry); err != nil {
+		log.Printf("room-service: failed to audit %s by %s: %v", req.action, req.claims.Subject, err)
+		return ErrAuditFailed
+	}
+	return nil
+}
+
+// ListAuditLogs pages through room changes, newest first. Supports room_id,
+// actor_id, action, page and page_size.
+func (h *RoomHandler) ListAuditLogs(c *fiber.Ctx) error {
+	if _, err := requirePermission(c, authz.PermAuditRead); err != nil {
+		return respondError(c, err)
+	}
+
+	filter := AuditFilter{
+		ActorID:  c.Query("actor_id"),
+		Action:   c.Query("action"),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", 50),
+	}
+	if roomID := c.Query("room_id"); roomID != "" {
+		id, err := uuid.Parse(roomID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid room_id"})
+		}
+		filter.RoomID = &id
+	}
+
+	entries, total, err := h.service.ListAuditLogs(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	return c.JSON(fiber.Map{"entries": entries, "total": total, "page": filter.Page})
+}
+
+func respondError(c *fiber.Ctx, err error) error {
+	if fe, ok := err.(*fiber.Error); ok {
+		return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
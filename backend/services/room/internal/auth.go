@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtClaims struct {
+	Email       string       `json:"email"`
+	Role        string       `json:"role"`
+	Permissions []string     `json:"permissions,omitempty"`
+	Groups      []string     `json:"groups,omitempty"`
+	Act         *authz.Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func requirePermission(c *fiber.Ctx, permissions ...string) (*jwtClaims, error) {
+	claims, err := parseJWTClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	if !authz.HasPermission(claims.Role, claims.Permissions, permissions...) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "missing permission "+strings.Join(permissions, ", "))
+	}
+	return claims, nil
+}
+
+// parseJWTClaims validates the bearer token itself rather than trusting the
+// gateway to have done so.
+func parseJWTClaims(c *fiber.Ctx) (*jwtClaims, error) {
+	authHeader := strings.TrimSpace(c.Get("Authorization"))
+	if authHeader == "" {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "authorization header required")
+	}
+
+	if len(authHeader) < 7 || !strings.EqualFold(authHeader[:7], "bearer ") {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "authorization header must be Bearer token")
+	}
+
+	tokenString := strings.TrimSpace(authHeader[7:])
+	if tokenString == "" {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "bearer token is empty")
+	}
+
+	return parseToken(c.UserContext(), tokenString)
+}
+
+// parseToken validates a token issued by the auth service and returns its
+// claims. Errors are *fiber.Error with the HTTP status to answer with.
+func parseToken(ctx context.Context, tokenString string) (*jwtClaims, error) {
+	secret := strings.TrimSpace(os.Getenv("JWT_SECRET"))
+	if secret == "" {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "jwt secret not configured")
+	}
+
+	issuer := strings.TrimSpace(os.Getenv("JWT_ISSUER"))
+	if issuer == "" {
+		issuer = "cproom-auth"
+	}
+
+	claims := &jwtClaims{}
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(t *jwt.Token) (any, error) {
+			return []byte(secret), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+	}
+
+	if claims.Issuer != issuer {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token issuer")
+	}
+
+	if err := authz.CheckSession(ctx, "room-service", tokenString, claims.Subject); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidEquipment = errors.New("equipment needs a name of at most 100 characters, a quantity of at least 1 and a condition of working, degraded or broken")
+
+// EquipmentInput describes a stock of equipment kept in a room. Condition
+// defaults to working.
+type EquipmentInput struct {
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Condition string `json:"condition"`
+	Movable   bool   `json:"movable"`
+	Notes     string `json:"notes"`
+}
+
+func (in EquipmentInput) applyTo(item *models.Equipment) error {
+	name := strings.TrimSpace(in.Name)
+	condition := strings.ToLower(strings.TrimSpace(in.Condition))
+	if condition == "" {
+		condition = models.EquipmentWorking
+	}
+
+	switch {
+	case name == "", utf8.RuneCountInString(name) > 100, in.Quantity < 1:
+		return ErrInvalidEquipment
+	case condition != models.EquipmentWorking && condition != models.EquipmentDegraded && condition != models.EquipmentBroken:
+		return ErrInvalidEquipment
+	}
+
+	item.Name = name
+	item.Quantity = in.Quantity
+	item.Condition = condition
+	item.Movable = in.Movable
+	item.Notes = strings.TrimSpace(in.Notes)
+	return nil
+}
+
+func (s *roomService) ListEquipment(roomID uuid.UUID) ([]models.Equipment, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListEquipment(roomID)
+}
+
+func (s *roomService) AddEquipment(roomID uuid.UUID, input EquipmentInput) (*models.Equipment, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+
+	item := &models.Equipment{RoomID: roomID}
+	if err := input.applyTo(item); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateEquipment(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// UpdateEquipment replaces the description of an item. Reservations already
+// made are kept even if the new quantity or condition no longer covers them.
+func (s *roomService) UpdateEquipment(id uuid.UUID, input EquipmentInput) (*models.Equipment, error) {
+	item, err := s.repo.GetEquipment(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.applyTo(item); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateEquipment(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// RemoveEquipment deletes an item and returns it. Bookings that reserved it
+// keep their reservation rows but can no longer be rescheduled with it.
+func (s *roomService) RemoveEquipment(id uuid.UUID) (*models.Equipment, error) {
+	item, err := s.repo.GetEquipment(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteEquipment(id); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
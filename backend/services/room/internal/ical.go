@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/schedule"
+)
+
+var ErrInvalidCalendar = errors.New("invalid iCalendar data")
+
+// maxEventSpan bounds a single holiday; longer events are rejected rather
+// than closing rooms for years.
+const maxEventSpan = 366 * 24 * time.Hour
+
+// calendarEvent is one VEVENT of an imported holiday calendar, expanded to
+// the dates it covers.
+type calendarEvent struct {
+	Summary string
+	Dates   []string
+}
+
+// parseICalendar reads the VEVENTs of an RFC 5545 calendar. Only DTSTART,
+// DTEND and SUMMARY are used: holidays are whole-day closures, so timed events
+// close every date they touch in loc, the time zone of the rooms the calendar
+// applies to. Recurrence rules are not expanded.
+func parseICalendar(r io.Reader, loc *time.Location) ([]calendarEvent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events  []calendarEvent
+		inEvent bool
+		sawCal  bool
+		summary string
+		start   time.Time
+		end     time.Time
+		allDay  bool
+	)
+	for _, line := range lines {
+		name, params, value := splitICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VCALENDAR":
+			sawCal = true
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			summary, start, end, allDay = "", time.Time{}, time.Time{}, false
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				return nil, ErrInvalidCalendar
+			}
+			if end.Sub(start) > maxEventSpan {
+				return nil, fmt.Errorf("%w: event %q is longer than a year", ErrInvalidCalendar, summary)
+			}
+			events = append(events, calendarEvent{Summary: summary, Dates: eventDates(start, end, allDay)})
+		case !inEvent:
+		case name == "SUMMARY":
+			summary = unescapeICalText(value)
+		case name == "DTSTART":
+			if start, allDay, err = parseICalTime(params, value, loc); err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			if end, _, err = parseICalTime(params, value, loc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !sawCal || inEvent {
+		return nil, ErrInvalidCalendar
+	}
+	return events, nil
+}
+
+// unfoldICalLines joins continuation lines, which start with a space or tab.
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func splitICalLine(line string) (name string, params map[string]string, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params = map[string]string{}
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, strings.TrimSpace(value)
+}
+
+// parseICalTime reads a DATE or DATE-TIME value. Times are returned in loc,
+// so their date is the one in the rooms' time zone; floating times, which
+// have neither Z nor TZID, are taken to be in loc already.
+func parseICalTime(params map[string]string, value string, loc *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, ErrInvalidCalendar
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, ErrInvalidCalendar
+		}
+		return t.In(loc), false, nil
+	}
+	in := loc
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			in = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, in)
+	if err != nil {
+		return time.Time{}, false, ErrInvalidCalendar
+	}
+	return t.In(loc), false, nil
+}
+
+// eventDates lists the dates an event covers. All-day DTEND is exclusive and
+// defaults to the day after DTSTART.
+func eventDates(start, end time.Time, allDay bool) []string {
+	if end.IsZero() || !end.After(start) {
+		return []string{start.Format(schedule.DateLayout)}
+	}
+	last := end
+	if allDay {
+		last = end.AddDate(0, 0, -1)
+	} else if last.Hour() == 0 && last.Minute() == 0 && last.Second() == 0 {
+		last = last.Add(-time.Second)
+	}
+
+	var dates []string
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format(schedule.DateLayout))
+	}
+	return dates
+}
+
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func icalendar(events ...string) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n")
+	for _, e := range events {
+		b.WriteString("BEGIN:VEVENT\r\n" + e + "END:VEVENT\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
+
+func TestParseICalendarDatesInRoomTimeZone(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+
+	tests := []struct {
+		name  string
+		event string
+		want  []string
+	}{
+		{"all day", "DTSTART;VALUE=DATE:20250101\r\n", []string{"2025-01-01"}},
+		{"utc evening is the next day in bangkok", "DTSTART:20241231T200000Z\r\nDTEND:20241231T230000Z\r\n", []string{"2025-01-01"}},
+		{"utc across midnight in bangkok", "DTSTART:20241231T150000Z\r\nDTEND:20241231T180000Z\r\n", []string{"2024-12-31", "2025-01-01"}},
+		{"floating time is local", "DTSTART:20250101T010000\r\n", []string{"2025-01-01"}},
+		{"tzid is converted", "DTSTART;TZID=Europe/London:20241231T200000\r\n", []string{"2025-01-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := parseICalendar(strings.NewReader(icalendar("SUMMARY:Holiday\r\n"+tt.event)), bangkok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := events[0].Dates
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("dates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseICalendarRejectsLongEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		ok    bool
+	}{
+		{"a year of days", "DTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20250101\r\n", true},
+		{"longer than a year", "DTSTART;VALUE=DATE:20240101\r\nDTEND;VALUE=DATE:20250103\r\n", false},
+		{"centuries", "DTSTART:20240101T000000Z\r\nDTEND:99991231T000000Z\r\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseICalendar(strings.NewReader(icalendar(tt.event)), time.UTC)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidCalendar) {
+				t.Fatalf("got %v, want ErrInvalidCalendar", err)
+			}
+		})
+	}
+}
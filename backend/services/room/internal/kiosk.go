@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+)
+
+// kioskKeyPrefix marks kiosk keys, apart from the auth service's cpk_ API
+// keys. The booking service, which serves the kiosk API, checks them against
+// room_kiosks.
+const kioskKeyPrefix = "cpd_"
+
+var ErrInvalidKiosk = errors.New("kiosk needs a name of at most 100 characters")
+
+// RegisterKiosk adds a display kiosk to the room and returns it with its key.
+// The key is not stored and cannot be shown again.
+func (s *roomService) RegisterKiosk(roomID uuid.UUID, name string) (*models.RoomKiosk, string, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return nil, "", ErrInvalidKiosk
+	}
+
+	key, keyPrefix, hash, err := newDeviceKey(kioskKeyPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kiosk := &models.RoomKiosk{
+		RoomID:    roomID,
+		Name:      name,
+		KeyPrefix: keyPrefix,
+		KeyHash:   hash,
+	}
+	if err := s.repo.CreateKiosk(kiosk); err != nil {
+		return nil, "", err
+	}
+	return kiosk, key, nil
+}
+
+func (s *roomService) ListKiosks(roomID uuid.UUID) ([]models.RoomKiosk, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListKiosks(roomID)
+}
+
+// RevokeKiosk stops a kiosk's key from being accepted.
+func (s *roomService) RevokeKiosk(id uuid.UUID) (*models.RoomKiosk, error) {
+	return s.repo.RevokeKiosk(id, time.Now())
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+)
+
+// maxLayoutBuffer caps setup and teardown time at a day.
+const maxLayoutBuffer = 24 * 60
+
+var (
+	ErrInvalidLayout   = errors.New("layout needs a name of at most 50 characters, a capacity of at least 1 and setup and teardown minutes between 0 and 1440")
+	ErrDuplicateLayout = errors.New("the room already has a layout with this name")
+)
+
+// LayoutInput describes a named layout of a room.
+type LayoutInput struct {
+	Name            string `json:"name"`
+	Capacity        int    `json:"capacity"`
+	SetupMinutes    int    `json:"setup_minutes"`
+	TeardownMinutes int    `json:"teardown_minutes"`
+}
+
+func (in LayoutInput) applyTo(layout *models.Layout) error {
+	name := strings.TrimSpace(in.Name)
+	switch {
+	case name == "", utf8.RuneCountInString(name) > 50, in.Capacity < 1:
+		return ErrInvalidLayout
+	case in.SetupMinutes < 0, in.SetupMinutes > maxLayoutBuffer, in.TeardownMinutes < 0, in.TeardownMinutes > maxLayoutBuffer:
+		return ErrInvalidLayout
+	}
+
+	layout.Name = name
+	layout.Capacity = in.Capacity
+	layout.SetupMinutes = in.SetupMinutes
+	layout.TeardownMinutes = in.TeardownMinutes
+	return nil
+}
+
+func (s *roomService) ListLayouts(roomID uuid.UUID) ([]models.Layout, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListLayouts(roomID)
+}
+
+func (s *roomService) AddLayout(roomID uuid.UUID, input LayoutInput) (*models.Layout, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+
+	layout := &models.Layout{RoomID: roomID}
+	if err := input.applyTo(layout); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateLayout(layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// UpdateLayout replaces a layout. Existing bookings keep the capacity and
+// buffers they were made with.
+func (s *roomService) UpdateLayout(id uuid.UUID, input LayoutInput) (*models.Layout, error) {
+	layout, err := s.repo.GetLayout(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.applyTo(layout); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateLayout(layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+func (s *roomService) RemoveLayout(id uuid.UUID) (*models.Layout, error) {
+	layout, err := s.repo.GetLayout(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteLayout(id); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
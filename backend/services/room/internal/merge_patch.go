@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+)
+
+var ErrInvalidPatch = errors.New("invalid merge patch")
+
+// roomFields are the room attributes clients may set: the members a merge
+// patch may change and the columns of an import or export.
+type roomFields struct {
+	Name        string   `json:"name"`
+	Capacity    int      `json:"capacity"`
+	Features    []string `json:"features"`
+	Building    string   `json:"building"`
+	Floor       int      `json:"floor"`
+	RoomNumber  string   `json:"room_number"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	Accessible  bool     `json:"accessible"`
+	TimeZone    string   `json:"time_zone"`
+	Description string   `json:"description"`
+}
+
+// readOnlyRoomFields are part of the room representation but only change
+// through their own endpoints.
+var readOnlyRoomFields = []string{"id", "version", "archived_at", "group_id"}
+
+// patchRoom applies an RFC 7396 JSON merge patch to room: members set to
+// null are cleared, members left out are kept. Unknown and read-only members
+// are rejected so a typo does not silently do nothing.
+func patchRoom(room *models.Room, patch []byte) error {
+	var changes any
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	members, ok := changes.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: body must be a JSON object", ErrInvalidPatch)
+	}
+	for _, field := range readOnlyRoomFields {
+		if _, ok := members[field]; ok {
+			return fmt.Errorf("%w: %s is read-only", ErrInvalidPatch, field)
+		}
+	}
+
+	current, err := json.Marshal(roomFieldsOf(room))
+	if err != nil {
+		return err
+	}
+	var target any
+	if err := json.Unmarshal(current, &target); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(target, members))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	var fields roomFields
+	if err := decoder.Decode(&fields); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPatch, strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	fields.applyTo(room)
+	return nil
+}
+
+func roomFieldsOf(room *models.Room) roomFields {
+	return roomFields{
+		Name:        room.Name,
+		Capacity:    room.Capacity,
+		Features:    []string(room.Features),
+		Building:    room.Building,
+		Floor:       room.Floor,
+		RoomNumber:  room.RoomNumber,
+		Latitude:    room.Latitude,
+		Longitude:   room.Longitude,
+		Accessible:  room.Accessible,
+		TimeZone:    room.TimeZone,
+		Description: room.Description,
+	}
+}
+
+func (f roomFields) applyTo(room *models.Room) {
+	room.Name = f.Name
+	room.Capacity = f.Capacity
+	room.Features = models.StringList(f.Features)
+	room.Building = f.Building
+	room.Floor = f.Floor
+	room.RoomNumber = f.RoomNumber
+	room.Latitude = f.Latitude
+	room.Longitude = f.Longitude
+	room.Accessible = f.Accessible
+	room.TimeZone = f.TimeZone
+	room.Description = f.Description
+}
+
+// mergePatch is the MergePatch function of RFC 7396.
+func mergePatch(target, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = mergePatch(doc[name], value)
+	}
+	return doc
+}
+
+// roomETag is the entity tag of a room version.
+func roomETag(room *models.Room) string {
+	return `"` + strconv.Itoa(room.Version) + `"`
+}
+
+// parseIfMatch reads the versions listed in an If-Match header. A nil result
+// with ok set means "*", i.e. any version.
+func parseIfMatch(header string) (versions []int, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return nil, true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match compares strongly, so a weak tag never matches.
+		weak := strings.HasPrefix(tag, "W/")
+		tag = strings.TrimPrefix(tag, "W/")
+		version, err := strconv.Atoi(strings.Trim(tag, `"`))
+		if err != nil || len(tag) < 3 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, false
+		}
+		if weak {
+			version = 0
+		}
+		versions = append(versions, version)
+	}
+	return versions, len(versions) > 0
+}
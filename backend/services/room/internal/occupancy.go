@@ -0,0 +1,248 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+)
+
+const (
+	sensorKeyPrefix = "cps_"
+	// maxOccupancyBatch caps the readings a sensor may send in one request.
+	maxOccupancyBatch = 500
+	// occupancyStaleAfter is how old the latest reading of a room may be
+	// before its occupancy is reported as stale.
+	occupancyStaleAfter = 15 * time.Minute
+	// maxReadingSkew tolerates sensor clocks running slightly ahead.
+	maxReadingSkew = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSensor    = errors.New("sensor needs a name of at most 100 characters")
+	ErrInvalidSensorKey = errors.New("invalid sensor key")
+	ErrInvalidReading   = errors.New("each reading needs occupied or a non-negative people_count, and recorded_at no later than now")
+)
+
+// OccupancyInput is one reading sent by a sensor. Occupied defaults to
+// people_count > 0 and RecordedAt to the time it is received.
+type OccupancyInput struct {
+	Occupied    *bool      `json:"occupied"`
+	PeopleCount *int       `json:"people_count"`
+	RecordedAt  *time.Time `json:"recorded_at"`
+}
+
+// RoomOccupancy is the latest known occupancy of a room. Stale is set when
+// the latest reading is older than occupancyStaleAfter.
+type RoomOccupancy struct {
+	RoomID      uuid.UUID  `json:"room_id"`
+	Known       bool       `json:"known"`
+	Occupied    bool       `json:"occupied"`
+	PeopleCount *int       `json:"people_count,omitempty"`
+	SensorID    *uuid.UUID `json:"sensor_id,omitempty"`
+	RecordedAt  *time.Time `json:"recorded_at,omitempty"`
+	Stale       bool       `json:"stale"`
+}
+
+func hashDeviceKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newDeviceKey returns a random key for a room device, the prefix shown to
+// tell keys apart, and the hash stored in its place.
+func newDeviceKey(prefix string) (key, keyPrefix, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", "", err
+	}
+	key = prefix + base64.RawURLEncoding.EncodeToString(raw)
+	return key, key[:len(prefix)+8], hashDeviceKey(key), nil
+}
+
+// RegisterSensor adds a sensor to the room and returns it with its key. The
+// key is not stored and cannot be shown again.
+func (s *roomService) RegisterSensor(roomID uuid.UUID, name string) (*models.RoomSensor, string, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return nil, "", ErrInvalidSensor
+	}
+
+	key, keyPrefix, hash, err := newDeviceKey(sensorKeyPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sensor := &models.RoomSensor{
+		RoomID:    roomID,
+		Name:      name,
+		KeyPrefix: keyPrefix,
+		KeyHash:   hash,
+	}
+	if err := s.repo.CreateSensor(sensor); err != nil {
+		return nil, "", err
+	}
+	return sensor, key, nil
+}
+
+func (s *roomService) ListSensors(roomID uuid.UUID) ([]models.RoomSensor, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListSensors(roomID)
+}
+
+// RevokeSensor stops a sensor's key from being accepted. Its readings are
+// kept.
+func (s *roomService) RevokeSensor(id uuid.UUID) (*models.RoomSensor, error) {
+	return s.repo.RevokeSensor(id, time.Now())
+}
+
+// AuthenticateSensor returns the active sensor the key belongs to.
+func (s *roomService) AuthenticateSensor(key string) (*models.RoomSensor, error) {
+	if !strings.HasPrefix(key, sensorKeyPrefix) {
+		return nil, ErrInvalidSensorKey
+	}
+	sensor, err := s.repo.GetSensorByKeyHash(hashDeviceKey(key))
+	if err != nil {
+		return nil, ErrInvalidSensorKey
+	}
+	return sensor, nil
+}
+
+// RecordOccupancy stores readings from sensor and publishes
+// room.occupancy_changed whenever a reading newer than the room's latest one
+// flips it between occupied and vacant.
+func (s *roomService) RecordOccupancy(sensor *models.RoomSensor, input []OccupancyInput) ([]models.OccupancyReading, error) {
+	if len(input) == 0 || len(input) > maxOccupancyBatch {
+		return nil, ErrInvalidReading
+	}
+
+	now := time.Now()
+	readings := make([]models.OccupancyReading, len(input))
+	for i, in := range input {
+		reading := models.OccupancyReading{
+			RoomID:      sensor.RoomID,
+			SensorID:    sensor.ID,
+			RecordedAt:  now,
+			ReceivedAt:  now,
+			PeopleCount: in.PeopleCount,
+		}
+		switch {
+		case in.Occupied != nil:
+			reading.Occupied = *in.Occupied
+		case in.PeopleCount != nil:
+			reading.Occupied = *in.PeopleCount > 0
+		default:
+			return nil, ErrInvalidReading
+		}
+		if in.PeopleCount != nil && *in.PeopleCount < 0 {
+			return nil, ErrInvalidReading
+		}
+		if in.RecordedAt != nil {
+			if in.RecordedAt.After(now.Add(maxReadingSkew)) {
+				return nil, ErrInvalidReading
+			}
+			reading.RecordedAt = *in.RecordedAt
+		}
+		readings[i] = reading
+	}
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].RecordedAt.Before(readings[j].RecordedAt) })
+
+	previous, err := s.repo.RecordOccupancy(sensor.ID, readings, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range readings {
+		reading := &readings[i]
+		if previous != nil && !reading.RecordedAt.After(previous.RecordedAt) {
+			continue
+		}
+		if previous == nil || previous.Occupied != reading.Occupied {
+			s.publishOccupancyChanged(reading)
+		}
+		previous = reading
+	}
+	return readings, nil
+}
+
+// publishOccupancyChanged announces a change of a room's occupancy. The
+// reading is already saved, so a failed publish is only logged.
+func (s *roomService) publishOccupancyChanged(reading *models.OccupancyReading) {
+	if s.events == nil {
+		return
+	}
+	evt := events.RoomOccupancyEvent{
+		Event:       events.RoomOccupancyChangedEvent,
+		RoomID:      reading.RoomID.String(),
+		SensorID:    reading.SensorID.String(),
+		Occupied:    reading.Occupied,
+		PeopleCount: reading.PeopleCount,
+		RecordedAt:  reading.RecordedAt,
+	}
+	if err := s.events.PublishRoomOccupancyEvent(context.Background(), evt); err != nil {
+		log.Printf("room-service: failed to publish occupancy change for room %s: %v", reading.RoomID, err)
+	}
+}
+
+// CurrentOccupancy returns the latest occupancy of the given rooms, or of
+// every room with readings when roomIDs is empty.
+func (s *roomService) CurrentOccupancy(roomIDs []uuid.UUID) ([]RoomOccupancy, error) {
+	latest, err := s.repo.LatestOccupancy(roomIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byRoom := make(map[uuid.UUID]models.OccupancyReading, len(latest))
+	for _, reading := range latest {
+		byRoom[reading.RoomID] = reading
+	}
+	if len(roomIDs) == 0 {
+		for _, reading := range latest {
+			roomIDs = append(roomIDs, reading.RoomID)
+		}
+	}
+
+	now := time.Now()
+	result := make([]RoomOccupancy, len(roomIDs))
+	for i, roomID := range roomIDs {
+		result[i] = RoomOccupancy{RoomID: roomID}
+		reading, ok := byRoom[roomID]
+		if !ok {
+			continue
+		}
+		recordedAt, sensorID := reading.RecordedAt, reading.SensorID
+		result[i] = RoomOccupancy{
+			RoomID:      roomID,
+			Known:       true,
+			Occupied:    reading.Occupied,
+			PeopleCount: reading.PeopleCount,
+			SensorID:    &sensorID,
+			RecordedAt:  &recordedAt,
+			Stale:       now.Sub(recordedAt) > occupancyStaleAfter,
+		}
+	}
+	return result, nil
+}
+
+func (s *roomService) OccupancyHistory(roomID uuid.UUID, from, to time.Time) ([]models.OccupancyReading, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	return s.repo.OccupancyHistory(roomID, from, to)
+}
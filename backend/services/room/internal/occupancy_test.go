@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+)
+
+// occupancyRooms keeps the readings of one room in memory; the occupancy
+// tests never reach other methods.
+type occupancyRooms struct {
+	RoomRepository
+	readings []models.OccupancyReading
+	calls    int
+}
+
+func (r *occupancyRooms) RecordOccupancy(sensorID uuid.UUID, readings []models.OccupancyReading, at time.Time) (*models.OccupancyReading, error) {
+	r.calls++
+	var previous *models.OccupancyReading
+	for i := range r.readings {
+		if previous == nil || r.readings[i].RecordedAt.After(previous.RecordedAt) {
+			latest := r.readings[i]
+			previous = &latest
+		}
+	}
+	r.readings = append(r.readings, readings...)
+	return previous, nil
+}
+
+// publishedEvents records occupancy events and counts room events, failing
+// each publish with err.
+type publishedEvents struct {
+	occupancy []events.RoomOccupancyEvent
+	room      int
+	err       error
+}
+
+func (p *publishedEvents) PublishRoomEvent(ctx context.Context, evt events.RoomEvent) error {
+	p.room++
+	return p.err
+}
+
+func (p *publishedEvents) PublishRoomOccupancyEvent(ctx context.Context, evt events.RoomOccupancyEvent) error {
+	p.occupancy = append(p.occupancy, evt)
+	return p.err
+}
+
+func occupied(v bool) *bool { return &v }
+
+func people(n int) *int { return &n }
+
+func TestRecordOccupancyRejectsInvalidReadings(t *testing.T) {
+	future := time.Now().Add(maxReadingSkew + time.Minute)
+
+	tests := []struct {
+		name  string
+		input []OccupancyInput
+	}{
+		{"empty batch", nil},
+		{"batch too large", make([]OccupancyInput, maxOccupancyBatch+1)},
+		{"neither occupied nor people_count", []OccupancyInput{{}}},
+		{"negative people_count", []OccupancyInput{{PeopleCount: people(-1)}}},
+		{"negative people_count with occupied", []OccupancyInput{{Occupied: occupied(true), PeopleCount: people(-1)}}},
+		{"recorded in the future", []OccupancyInput{{Occupied: occupied(true), RecordedAt: &future}}},
+		{"one bad reading in a batch", []OccupancyInput{{Occupied: occupied(true)}, {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &occupancyRooms{}
+			s := &roomService{repo: repo, events: &publishedEvents{}}
+			sensor := &models.RoomSensor{ID: uuid.New(), RoomID: uuid.New()}
+
+			if _, err := s.RecordOccupancy(sensor, tt.input); !errors.Is(err, ErrInvalidReading) {
+				t.Fatalf("got %v, want ErrInvalidReading", err)
+			}
+			if repo.calls != 0 {
+				t.Error("invalid readings were saved")
+			}
+		})
+	}
+}
+
+func TestRecordOccupancyFillsDefaults(t *testing.T) {
+	s := &roomService{repo: &occupancyRooms{}}
+	sensor := &models.RoomSensor{ID: uuid.New(), RoomID: uuid.New()}
+	ahead := time.Now().Add(maxReadingSkew / 2)
+
+	before := time.Now()
+	readings, err := s.RecordOccupancy(sensor, []OccupancyInput{
+		{PeopleCount: people(3)},
+		{PeopleCount: people(0), RecordedAt: &ahead},
+		{Occupied: occupied(true), PeopleCount: people(0), RecordedAt: &ahead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := readings[0]
+	if !first.Occupied || first.RoomID != sensor.RoomID || first.SensorID != sensor.ID {
+		t.Errorf("people_count 3 gave %+v, want an occupied reading of the sensor's room", first)
+	}
+	if first.RecordedAt.Before(before) || !first.RecordedAt.Equal(first.ReceivedAt) {
+		t.Errorf("recorded_at = %v, want the time it was received", first.RecordedAt)
+	}
+	if readings[1].Occupied {
+		t.Error("people_count 0 should default to vacant")
+	}
+	if !readings[2].Occupied {
+		t.Error("an explicit occupied should win over people_count")
+	}
+}
+
+func TestRecordOccupancyPublishesOnlyChanges(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	at := func(minutes int) *time.Time {
+		t := base.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+	reading := func(minutes int, v bool) OccupancyInput {
+		return OccupancyInput{Occupied: occupied(v), RecordedAt: at(minutes)}
+	}
+
+	tests := []struct {
+		name     string
+		previous []OccupancyInput
+		batch    []OccupancyInput
+		want     []bool
+	}{
+		{"first reading of a room", nil, []OccupancyInput{reading(0, false)}, []bool{false}},
+		{"same state again", []OccupancyInput{reading(0, true)}, []OccupancyInput{reading(1, true)}, nil},
+		{"state flips", []OccupancyInput{reading(0, true)}, []OccupancyInput{reading(1, false)}, []bool{false}},
+		{"repeats within a batch", []OccupancyInput{reading(0, false)},
+			[]OccupancyInput{reading(1, true), reading(2, true), reading(3, true)}, []bool{true}},
+		{"every flip within a batch", []OccupancyInput{reading(0, false)},
+			[]OccupancyInput{reading(1, true), reading(2, false), reading(3, true)}, []bool{true, false, true}},
+		{"batch out of order", []OccupancyInput{reading(0, false)},
+			[]OccupancyInput{reading(3, false), reading(1, true), reading(2, true)}, []bool{true, false}},
+		{"older than the latest reading", []OccupancyInput{reading(5, true)}, []OccupancyInput{reading(4, false)}, nil},
+		{"as old as the latest reading", []OccupancyInput{reading(5, true)}, []OccupancyInput{reading(5, false)}, nil},
+		{"late reading then a newer one", []OccupancyInput{reading(5, true)},
+			[]OccupancyInput{reading(4, false), reading(6, true)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &occupancyRooms{}
+			sensor := &models.RoomSensor{ID: uuid.New(), RoomID: uuid.New()}
+			if tt.previous != nil {
+				if _, err := (&roomService{repo: repo}).RecordOccupancy(sensor, tt.previous); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			published := &publishedEvents{}
+			s := &roomService{repo: repo, events: published}
+			if _, err := s.RecordOccupancy(sensor, tt.batch); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []bool
+			for _, evt := range published.occupancy {
+				got = append(got, evt.Occupied)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("published %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("published %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordOccupancyEvent(t *testing.T) {
+	published := &publishedEvents{}
+	s := &roomService{repo: &occupancyRooms{}, events: published}
+	sensor := &models.RoomSensor{ID: uuid.New(), RoomID: uuid.New()}
+	recordedAt := time.Now().Add(-time.Minute)
+
+	if _, err := s.RecordOccupancy(sensor, []OccupancyInput{{PeopleCount: people(4), RecordedAt: &recordedAt}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(published.occupancy) != 1 {
+		t.Fatalf("published %d events, want 1", len(published.occupancy))
+	}
+	evt := published.occupancy[0]
+	if evt.Event != events.RoomOccupancyChangedEvent || evt.RoomID != sensor.RoomID.String() ||
+		evt.SensorID != sensor.ID.String() || !evt.Occupied || evt.PeopleCount == nil ||
+		*evt.PeopleCount != 4 || !evt.RecordedAt.Equal(recordedAt) {
+		t.Errorf("unexpected event %+v", evt)
+	}
+}
+
+func TestRecordOccupancyKeepsReadingsWhenPublishFails(t *testing.T) {
+	repo := &occupancyRooms{}
+	published := &publishedEvents{err: errors.New("broker down")}
+	s := &roomService{repo: repo, events: published}
+	sensor := &models.RoomSensor{ID: uuid.New(), RoomID: uuid.New()}
+
+	readings, err := s.RecordOccupancy(sensor, []OccupancyInput{{Occupied: occupied(true)}})
+	if err != nil {
+		t.Fatalf("a failed publish failed the request: %v", err)
+	}
+	if len(readings) != 1 || len(repo.readings) != 1 || len(published.occupancy) != 1 {
+		t.Errorf("got %d readings, %d saved and %d published, want 1 each", len(readings), len(repo.readings), len(published.occupancy))
+	}
+}
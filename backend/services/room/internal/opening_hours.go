@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/schedule"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// HoursInput is one weekly opening period. Weekday 0 is Sunday; opens and
+// closes are "HH:MM" in the room's time zone.
+type HoursInput struct {
+	Weekday int    `json:"weekday"`
+	Opens   string `json:"opens"`
+	Closes  string `json:"closes"`
+}
+
+// ExceptionInput closes a scope on Date, or opens it from Opens to Closes
+// when Closed is false.
+type ExceptionInput struct {
+	Date   string `json:"date"`
+	Closed bool   `json:"closed"`
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+	Reason string `json:"reason"`
+}
+
+func (s *roomService) checkScope(scope ScheduleScope) error {
+	if scope.RoomID != nil {
+		if _, err := s.repo.GetByID(*scope.RoomID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *roomService) OpeningHours(scope ScheduleScope) ([]models.OpeningHours, error) {
+	if err := s.checkScope(scope); err != nil {
+		return nil, err
+	}
+	return s.repo.ListOpeningHours(scope)
+}
+
+// SetOpeningHours replaces the weekly hours of scope. An empty list removes
+// them, so the room falls back to its building's hours, then global hours,
+// and is open around the clock when none are set.
+func (s *roomService) SetOpeningHours(scope ScheduleScope, input []HoursInput) ([]models.OpeningHours, error) {
+	if err := s.checkScope(scope); err != nil {
+		return nil, err
+	}
+
+	hours := make([]models.OpeningHours, 0, len(input))
+	for _, in := range input {
+		if in.Weekday < 0 || in.Weekday > 6 {
+			return nil, fmt.Errorf("%w: weekday must be 0 (Sunday) to 6 (Saturday)", ErrInvalidSchedule)
+		}
+		opens, closes, err := parseOpenClose(in.Opens, in.Closes)
+		if err != nil {
+			return nil, err
+		}
+		hours = append(hours, models.OpeningHours{
+			RoomID:       scope.RoomID,
+			Building:     scope.Building,
+			Weekday:      in.Weekday,
+			OpensMinute:  opens,
+			ClosesMinute: closes,
+		})
+	}
+
+	if err := s.repo.ReplaceOpeningHours(scope, hours); err != nil {
+		return nil, err
+	}
+	return s.repo.ListOpeningHours(scope)
+}
+
+func (s *roomService) AddException(scope ScheduleScope, input ExceptionInput) (*models.ScheduleException, error) {
+	if err := s.checkScope(scope); err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse(schedule.DateLayout, input.Date)
+	if err != nil {
+		return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidSchedule)
+	}
+
+	exception := &models.ScheduleException{
+		RoomID:   scope.RoomID,
+		Building: scope.Building,
+		Date:     date,
+		Closed:   input.Closed,
+		Reason:   strings.TrimSpace(input.Reason),
+	}
+	if !input.Closed {
+		if exception.OpensMinute, exception.ClosesMinute, err = parseOpenClose(input.Opens, input.Closes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.CreateException(exception); err != nil {
+		return nil, err
+	}
+	return exception, nil
+}
+
+func (s *roomService) ListExceptions(scope ScheduleScope, from, to time.Time) ([]models.ScheduleException, error) {
+	if err := s.checkScope(scope); err != nil {
+		return nil, err
+	}
+	return s.repo.ListExceptions(scope, from, to)
+}
+
+// DeleteException removes a manually added exception. Dates imported from a
+// holiday calendar are removed by deleting the calendar.
+func (s *roomService) DeleteException(id uuid.UUID) error {
+	return s.repo.DeleteException(id)
+}
+
+// ImportHolidayCalendar stores every event of an iCalendar feed as an all-day
+// closure for building, or for every room when building is empty.
+// Re-importing a calendar under the same name replaces it.
+func (s *roomService) ImportHolidayCalendar(name, building string, r io.Reader) (*models.HolidayCalendar, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: calendar name is required", ErrInvalidSchedule)
+	}
+
+	building = strings.TrimSpace(building)
+	loc, err := s.buildingLocation(building)
+	if err != nil {
+		return nil, err
+	}
+	events, err := parseICalendar(r, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &models.HolidayCalendar{
+		Name:       name,
+		Building:   building,
+		EventCount: len(events),
+		ImportedAt: time.Now().UTC(),
+	}
+
+	var exceptions []models.ScheduleException
+	for _, event := range events {
+		reason := event.Summary
+		if reason == "" {
+			reason = name
+		}
+		for _, day := range event.Dates {
+			date, err := time.Parse(schedule.DateLayout, day)
+			if err != nil {
+				return nil, ErrInvalidCalendar
+			}
+			exceptions = append(exceptions, models.ScheduleException{
+				Building: calendar.Building,
+				Date:     date,
+				Closed:   true,
+				Reason:   reason,
+			})
+		}
+	}
+
+	if err := s.repo.ImportHolidayCalendar(calendar, exceptions); err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// buildingLocation is the time zone of the rooms in building, or the default
+// time zone for a calendar that applies to every room or to a building
+// without rooms yet.
+func (s *roomService) buildingLocation(building string) (*time.Location, error) {
+	zone := models.DefaultTimeZone
+	if building != "" {
+		rooms, err := s.repo.List(RoomFilter{Building: building, IncludeArchived: true})
+		if err != nil {
+			return nil, err
+		}
+		if len(rooms) > 0 {
+			zone = rooms[0].TimeZone
+		}
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+func (s *roomService) ListHolidayCalendars() ([]models.HolidayCalendar, error) {
+	return s.repo.ListHolidayCalendars()
+}
+
+func (s *roomService) DeleteHolidayCalendar(id uuid.UUID) error {
+	return s.repo.DeleteHolidayCalendar(id)
+}
+
+// Calendar resolves the opening hours and exceptions that apply to room
+// between from and to.
+func (s *roomService) Calendar(room *models.Room, from, to time.Time) (*schedule.Calendar, error) {
+	loc, err := time.LoadLocation(room.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	// Pad by a day so exceptions on the local dates at either end are found
+	// whatever the offset between loc and UTC.
+	hours, exceptions, err := s.repo.ScheduleRules(room, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]schedule.Hours, len(hours))
+	for i, h := range hours {
+		rules[i] = schedule.Hours{
+			RoomID:   uuidString(h.RoomID),
+			Building: h.Building,
+			Weekday:  time.Weekday(h.Weekday),
+			Opens:    h.OpensMinute,
+			Closes:   h.ClosesMinute,
+		}
+	}
+	dated := make([]schedule.Exception, len(exceptions))
+	for i, e := range exceptions {
+		dated[i] = schedule.Exception{
+			RoomID:   uuidString(e.RoomID),
+			Building: e.Building,
+			Date:     e.Date.Format(schedule.DateLayout),
+			Closed:   e.Closed,
+			Opens:    e.OpensMinute,
+			Closes:   e.ClosesMinute,
+			Reason:   e.Reason,
+		}
+	}
+	return schedule.NewCalendar(loc, rules, dated), nil
+}
+
+func parseOpenClose(opensStr, closesStr string) (int, int, error) {
+	opens, err := schedule.ParseClock(opensStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	closes, err := schedule.ParseClock(closesStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if closes <= opens {
+		return 0, 0, fmt.Errorf("%w: closes must be after opens", ErrInvalidSchedule)
+	}
+	return opens, closes, nil
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
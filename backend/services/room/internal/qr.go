@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/roomlink"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+	qrcode "github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRoomCode is returned for a scanned link that is not the current
+// code of an active room.
+var ErrInvalidRoomCode = errors.New("invalid room code")
+
+// RoomQRCode is the signed deep link a room's QR code encodes.
+type RoomQRCode struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	URL       string    `json:"url"`
+	Signature string    `json:"signature"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+func newQRSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// frontendBaseURL is where QR code links point to.
+func frontendBaseURL() string {
+	if base := os.Getenv("FRONTEND_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:5173"
+}
+
+func roomQRCode(secret *models.RoomQRSecret) *RoomQRCode {
+	sig := roomlink.Sign(secret.Secret, secret.RoomID.String())
+	return &RoomQRCode{
+		RoomID:    secret.RoomID,
+		URL:       roomlink.URL(frontendBaseURL(), secret.RoomID.String(), sig),
+		Signature: sig,
+		RotatedAt: secret.RotatedAt,
+	}
+}
+
+// RoomQRCode returns the room's signed link, creating its secret on first
+// use.
+func (s *roomService) RoomQRCode(roomID uuid.UUID) (*RoomQRCode, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	candidate, err := newQRSecret()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.repo.EnsureQRSecret(&models.RoomQRSecret{RoomID: roomID, Secret: candidate, RotatedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	return roomQRCode(secret), nil
+}
+
+// RotateQRSecret gives the room a new secret. Codes printed before stop
+// working.
+func (s *roomService) RotateQRSecret(roomID uuid.UUID) (*RoomQRCode, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	value, err := newQRSecret()
+	if err != nil {
+		return nil, err
+	}
+	secret := &models.RoomQRSecret{RoomID: roomID, Secret: value, RotatedAt: time.Now()}
+	if err := s.repo.SaveQRSecret(secret); err != nil {
+		return nil, err
+	}
+	return roomQRCode(secret), nil
+}
+
+// VerifyRoomCode checks a scanned link's signature against the room's current
+// secret. Archived rooms and rooms whose code was never printed have no valid
+// code.
+func (s *roomService) VerifyRoomCode(roomID uuid.UUID, signature string) error {
+	room, err := s.repo.GetByID(roomID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidRoomCode
+	}
+	if err != nil {
+		return err
+	}
+	if room.ArchivedAt != nil {
+		return ErrInvalidRoomCode
+	}
+	secret, err := s.repo.GetQRSecret(roomID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidRoomCode
+	}
+	if err != nil {
+		return err
+	}
+	if !roomlink.Verify(secret.Secret, roomID.String(), signature) {
+		return ErrInvalidRoomCode
+	}
+	return nil
+}
+
+// qrPNG renders content as a PNG QR code of size pixels square.
+func qrPNG(content string, size int) ([]byte, error) {
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
+
+// qrSVG renders content as an SVG QR code of size pixels square, one unit
+// per module so it scales without blurring.
+func qrSVG(content string, size int) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := code.Bitmap()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, len(bitmap), len(bitmap))
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
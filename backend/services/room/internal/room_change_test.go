@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+)
+
+// changeRooms hands out one changeTx; the tests never reach other methods.
+type changeRooms struct {
+	RoomRepository
+	tx *changeTx
+}
+
+func (r *changeRooms) Begin() (RoomTx, error) {
+	return r.tx, nil
+}
+
+// changeTx records what a RoomChange wrote and how it finished.
+type changeTx struct {
+	RoomRepository
+	auditErr   error
+	rooms      []*models.Room
+	audited    []*models.RoomAuditLog
+	committed  bool
+	rolledBack bool
+}
+
+func (t *changeTx) Create(room *models.Room) error {
+	t.rooms = append(t.rooms, room)
+	return nil
+}
+
+func (t *changeTx) CreateAuditLog(entry *models.RoomAuditLog) error {
+	if t.auditErr != nil {
+		return t.auditErr
+	}
+	t.audited = append(t.audited, entry)
+	return nil
+}
+
+func (t *changeTx) Commit() error {
+	t.committed = true
+	return nil
+}
+
+func (t *changeTx) Rollback() error {
+	t.rolledBack = true
+	return nil
+}
+
+func TestRoomChangeCommitsWithAudit(t *testing.T) {
+	tx := &changeTx{}
+	published := &publishedEvents{}
+	s := &roomService{repo: &changeRooms{tx: tx}, events: published}
+
+	change, err := s.BeginChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer change.Rollback()
+	if err := change.Service().Create(&models.Room{Name: "Lab", Capacity: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if published.room != 0 {
+		t.Fatal("a room event was published before the change committed")
+	}
+
+	if err := change.Commit(&models.RoomAuditLog{Action: models.AuditRoomCreated}); err != nil {
+		t.Fatal(err)
+	}
+	if !tx.committed || tx.rolledBack || len(tx.audited) != 1 {
+		t.Errorf("committed=%v rolled back=%v audited=%d, want a commit with one entry", tx.committed, tx.rolledBack, len(tx.audited))
+	}
+	if published.room != 1 {
+		t.Errorf("published %d room events after commit, want 1", published.room)
+	}
+}
+
+func TestRoomChangeRollsBackWhenAuditFails(t *testing.T) {
+	tx := &changeTx{auditErr: errors.New("disk full")}
+	published := &publishedEvents{}
+	s := &roomService{repo: &changeRooms{tx: tx}, events: published}
+
+	change, err := s.BeginChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer change.Rollback()
+	if err := change.Service().Create(&models.Room{Name: "Lab", Capacity: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := change.Commit(&models.RoomAuditLog{Action: models.AuditRoomCreated}); err == nil {
+		t.Fatal("commit succeeded without an audit entry")
+	}
+	if tx.committed || !tx.rolledBack {
+		t.Errorf("committed=%v rolled back=%v, want a rollback", tx.committed, tx.rolledBack)
+	}
+	if published.room != 0 {
+		t.Errorf("published %d room events for a rolled back change", published.room)
+	}
+}
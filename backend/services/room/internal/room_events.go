@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"log"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+)
+
+// EventPublisher is the part of the event bus the room service publishes to.
+type EventPublisher interface {
+	PublishRoomEvent(ctx context.Context, evt events.RoomEvent) error
+	PublishRoomOccupancyEvent(ctx context.Context, evt events.RoomOccupancyEvent) error
+}
+
+func roomSnapshot(room *models.Room) events.RoomSnapshot {
+	return events.RoomSnapshot{
+		ID:         room.ID.String(),
+		Name:       room.Name,
+		Capacity:   room.Capacity,
+		Features:   []string(room.Features),
+		Building:   room.Building,
+		Floor:      room.Floor,
+		RoomNumber: room.RoomNumber,
+		Accessible: room.Accessible,
+		TimeZone:   room.TimeZone,
+		ArchivedAt: room.ArchivedAt,
+	}
+}
+
+// publishRoomEvent announces a room change. The change is already saved, so
+// a failed publish is only logged; consumers fall back to reading the room.
+func (s *roomService) publishRoomEvent(event string, room *models.Room) {
+	if s.events == nil {
+		return
+	}
+	evt := events.RoomEvent{Event: event, Room: roomSnapshot(room)}
+	if err := s.events.PublishRoomEvent(context.Background(), evt); err != nil {
+		log.Printf("room-service: failed to publish %s for room %s: %v", event, room.ID, err)
+	}
+}
+
+// pendingEvents holds events published during a RoomChange until it commits.
+type pendingEvents struct {
+	events    EventPublisher
+	room      []events.RoomEvent
+	occupancy []events.RoomOccupancyEvent
+}
+
+func (p *pendingEvents) PublishRoomEvent(ctx context.Context, evt events.RoomEvent) error {
+	p.room = append(p.room, evt)
+	return nil
+}
+
+func (p *pendingEvents) PublishRoomOccupancyEvent(ctx context.Context, evt events.RoomOccupancyEvent) error {
+	p.occupancy = append(p.occupancy, evt)
+	return nil
+}
+
+// flush publishes the held events. The change is already committed, so a
+// failed publish is only logged, as in publishRoomEvent.
+func (p *pendingEvents) flush() {
+	if p == nil {
+		return
+	}
+	ctx := context.Background()
+	for _, evt := range p.room {
+		if err := p.events.PublishRoomEvent(ctx, evt); err != nil {
+			log.Printf("room-service: failed to publish %s for room %s: %v", evt.Event, evt.Room.ID, err)
+		}
+	}
+	for _, evt := range p.occupancy {
+		if err := p.events.PublishRoomOccupancyEvent(ctx, evt); err != nil {
+			log.Printf("room-service: failed to publish %s for room %s: %v", evt.Event, evt.RoomID, err)
+		}
+	}
+}
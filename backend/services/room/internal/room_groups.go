@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidGroup       = errors.New("group needs a name of at most 100 characters and a parent outside its own subtree")
+	ErrGroupNotEmpty      = errors.New("group still has rooms or subgroups")
+	ErrInvalidCombination = errors.New("a combined room needs at least two other rooms as parts; parts cannot be combined rooms themselves and a part cannot be combined")
+)
+
+// GroupInput describes a room group. A nil ParentID makes it a top-level
+// group.
+type GroupInput struct {
+	Name        string     `json:"name"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Description string     `json:"description"`
+}
+
+func (s *roomService) ListGroups() ([]models.RoomGroup, error) {
+	return s.repo.ListGroups()
+}
+
+func (s *roomService) CreateGroup(input GroupInput) (*models.RoomGroup, error) {
+	group := &models.RoomGroup{}
+	if err := s.applyGroupInput(group, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateGroup(group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *roomService) UpdateGroup(id uuid.UUID, input GroupInput) (*models.RoomGroup, error) {
+	group, err := s.repo.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyGroupInput(group, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateGroup(group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// applyGroupInput validates input for group, which has a zero ID when it is
+// new. Moving a group under itself or one of its subgroups is rejected.
+func (s *roomService) applyGroupInput(group *models.RoomGroup, input GroupInput) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return ErrInvalidGroup
+	}
+
+	if input.ParentID != nil {
+		if _, err := s.repo.GetGroup(*input.ParentID); err != nil {
+			return err
+		}
+		if group.ID != uuid.Nil {
+			subtree, err := s.repo.GroupSubtree(group.ID)
+			if err != nil {
+				return err
+			}
+			for _, id := range subtree {
+				if id == *input.ParentID {
+					return ErrInvalidGroup
+				}
+			}
+		}
+	}
+
+	group.Name = name
+	group.ParentID = input.ParentID
+	group.Description = strings.TrimSpace(input.Description)
+	return nil
+}
+
+// DeleteGroup removes an empty group. Move or remove its rooms and subgroups
+// first.
+func (s *roomService) DeleteGroup(id uuid.UUID) error {
+	return s.repo.DeleteGroup(id)
+}
+
+// SetRoomGroup moves a room into a group, or out of every group when groupID
+// is nil.
+func (s *roomService) SetRoomGroup(roomID uuid.UUID, groupID *uuid.UUID) (*models.Room, error) {
+	if groupID != nil {
+		if _, err := s.repo.GetGroup(*groupID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.SetRoomGroup(roomID, groupID); err != nil {
+		return nil, err
+	}
+
+	room, err := s.repo.GetByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	s.publishRoomEvent(events.RoomUpdatedEvent, room)
+	return room, nil
+}
+
+// RoomParts returns the rooms a combined room occupies and the combined rooms
+// the room is a part of.
+func (s *roomService) RoomParts(id uuid.UUID) (parts, partOf []models.Room, err error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, nil, err
+	}
+	return s.repo.RoomParts(id)
+}
+
+// SetRoomParts makes the room a combined room occupying partIDs, or a plain
+// room again when partIDs is empty. Bookings already made are not checked
+// against the new combination.
+func (s *roomService) SetRoomParts(id uuid.UUID, partIDs []uuid.UUID) ([]models.Room, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(partIDs))
+	var unique []uuid.UUID
+	for _, partID := range partIDs {
+		if partID == id {
+			return nil, ErrInvalidCombination
+		}
+		if !seen[partID] {
+			seen[partID] = true
+			unique = append(unique, partID)
+		}
+	}
+	if len(unique) == 1 {
+		return nil, ErrInvalidCombination
+	}
+
+	if err := s.repo.ReplaceRoomParts(id, unique); err != nil {
+		return nil, err
+	}
+	parts, _, err := s.repo.RoomParts(id)
+	return parts, err
+}
@@ -0,0 +1,369 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	bookingpb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/room"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// maxBatchGet bounds BatchGetRooms.
+const maxBatchGet = 500
+
+// RoomGRPCServer serves the room API over gRPC next to the Fiber routes.
+type RoomGRPCServer struct {
+	service  RoomService
+	bookings bookingpb.BookingServiceClient
+}
+
+// NewRoomGRPCServer shares the handler's service and booking client.
+func NewRoomGRPCServer(h *RoomHandler) *RoomGRPCServer {
+	return &RoomGRPCServer{service: h.service, bookings: h.bookingClient}
+}
+
+func (s *RoomGRPCServer) GetRoom(ctx context.Context, req *pb.GetRoomRequest) (*pb.Room, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid room id")
+	}
+	room, err := s.service.GetByID(id)
+	if err != nil {
+		return nil, roomStatus(err)
+	}
+	return toProtoRoom(room), nil
+}
+
+func (s *RoomGRPCServer) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.ListRoomsResponse, error) {
+	rooms, err := s.service.List(RoomFilter{IncludeArchived: req.GetIncludeArchived()})
+	if err != nil {
+		return nil, roomStatus(err)
+	}
+	return &pb.ListRoomsResponse{Rooms: toProtoRooms(rooms)}, nil
+}
+
+func (s *RoomGRPCServer) BatchGetRooms(ctx context.Context, req *pb.BatchGetRoomsRequest) (*pb.BatchGetRoomsResponse, error) {
+	if len(req.GetIds()) > maxBatchGet {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids per call", maxBatchGet)
+	}
+	ids := make([]uuid.UUID, 0, len(req.GetIds()))
+	for _, raw := range req.GetIds() {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid room id %q", raw)
+		}
+		ids = append(ids, id)
+	}
+
+	rooms, err := s.service.GetByIDs(ids)
+	if err != nil {
+		return nil, roomStatus(err)
+	}
+	resp := &pb.BatchGetRoomsResponse{Rooms: make(map[string]*pb.Room, len(rooms))}
+	for i := range rooms {
+		resp.Rooms[rooms[i].ID.String()] = toProtoRoom(&rooms[i])
+	}
+	return resp, nil
+}
+
+func (s *RoomGRPCServer) SearchRooms(ctx context.Context, req *pb.SearchRoomsRequest) (*pb.ListRoomsResponse, error) {
+	filter := RoomFilter{
+		Query:           req.GetQuery(),
+		Building:        strings.TrimSpace(req.GetBuilding()),
+		AccessibleOnly:  req.GetAccessibleOnly(),
+		MinCapacity:     int(req.GetMinCapacity()),
+		SortBy:          req.GetSortBy(),
+		Descending:      req.GetDescending(),
+		IncludeArchived: req.GetIncludeArchived(),
+	}
+	for _, floor := range req.GetFloors() {
+		filter.Floors = append(filter.Floors, int(floor))
+	}
+
+	rooms, err := s.service.List(filter)
+	if err != nil {
+		return nil, roomStatus(err)
+	}
+	return &pb.ListRoomsResponse{Rooms: toProtoRooms(rooms)}, nil
+}
+
+func (s *RoomGRPCServer) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb.Room, error) {
+	claims, err := authorizeRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	in := req.GetRoom()
+	if in == nil {
+		return nil, status.Error(codes.InvalidArgument, "room is required")
+	}
+
+	room := &models.Room{
+		Name:        strings.TrimSpace(in.GetName()),
+		Capacity:    int(in.GetCapacity()),
+		Features:    models.StringList(in.GetFeatures()),
+		Building:    in.GetBuilding(),
+		Floor:       int(in.GetFloor()),
+		RoomNumber:  in.GetRoomNumber(),
+		Accessible:  in.GetAccessible(),
+		TimeZone:    in.GetTimeZone(),
+		Description: in.GetDescription(),
+	}
+	if in.GetHasLocation() {
+		lat, lng := in.GetLatitude(), in.GetLongitude()
+		room.Latitude, room.Longitude = &lat, &lng
+	}
+	change, err := s.service.BeginChange()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer change.Rollback()
+	if err := change.Service().Create(room); err != nil {
+		return nil, roomStatus(err)
+	}
+
+	if err := s.commit(ctx, change, claims, models.AuditRoomCreated, room.ID, req); err != nil {
+		return nil, err
+	}
+	return toProtoRoom(room), nil
+}
+
+func (s *RoomGRPCServer) UpdateRoom(ctx context.Context, req *pb.UpdateRoomRequest) (*pb.Room, error) {
+	claims, err := authorizeRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	in := req.GetRoom()
+	id, err := uuid.Parse(in.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid room id")
+	}
+	if len(req.GetUpdateMask()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+
+	// Translate the mask into a merge patch so both APIs share validation.
+	patch := map[string]any{}
+	for _, field := range req.GetUpdateMask() {
+		switch field {
+		case "name":
+			patch["name"] = in.GetName()
+		case "capacity":
+			patch["capacity"] = in.GetCapacity()
+		case "features":
+			patch["features"] = in.GetFeatures()
+		case "building":
+			patch["building"] = in.GetBuilding()
+		case "floor":
+			patch["floor"] = in.GetFloor()
+		case "room_number":
+			patch["room_number"] = in.GetRoomNumber()
+		case "location":
+			patch["latitude"], patch["longitude"] = nil, nil
+			if in.GetHasLocation() {
+				patch["latitude"], patch["longitude"] = in.GetLatitude(), in.GetLongitude()
+			}
+		case "accessible":
+			patch["accessible"] = in.GetAccessible()
+		case "time_zone":
+			patch["time_zone"] = in.GetTimeZone()
+		case "description":
+			patch["description"] = in.GetDescription()
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unknown update_mask field %q", field)
+		}
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to build patch: %v", err)
+	}
+
+	var ifMatch []int
+	if req.GetVersion() > 0 {
+		ifMatch = []int{int(req.GetVersion())}
+	}
+	change, err := s.service.BeginChange()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer change.Rollback()
+	room, _, err := change.Service().Patch(id, body, ifMatch)
+	if err != nil {
+		if errors.Is(err, ErrVersionConflict) && len(ifMatch) == 0 {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, roomStatus(err)
+	}
+
+	if err := s.commit(ctx, change, claims, models.AuditRoomUpdated, id, req); err != nil {
+		return nil, err
+	}
+	return toProtoRoom(room), nil
+}
+
+func (s *RoomGRPCServer) ArchiveRoom(ctx context.Context, req *pb.ArchiveRoomRequest) (*pb.ArchiveRoomResponse, error) {
+	claims, err := authorizeRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid room id")
+	}
+
+	change, err := s.service.BeginChange()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer change.Rollback()
+	result, err := archiveRoom(ctx, change.Service(), s.bookings, id, req.GetForce(), req.GetDryRun(), func() error {
+		return s.commit(ctx, change, claims, models.AuditRoomArchived, id, req)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, ErrRoomHasBookings) {
+			return nil, status.Errorf(codes.FailedPrecondition, "%v (%d booking(s))", err, len(result.Impacted.GetBookings()))
+		}
+		return nil, roomStatus(err)
+	}
+
+	resp := &pb.ArchiveRoomResponse{Room: toProtoRoom(result.Room)}
+	bookings := result.Impacted
+	if result.Cancelled != nil {
+		bookings = result.Cancelled
+	}
+	for _, b := range bookings.GetBookings() {
+		resp.Bookings = append(resp.Bookings, &pb.ImpactedBooking{
+			BookingId: b.GetBooking().GetBookingId(),
+			UserId:    b.GetBooking().GetUserId(),
+			Start:     b.GetBooking().GetStart(),
+			End:       b.GetBooking().GetEnd(),
+			Status:    b.GetBooking().GetStatus(),
+			Outcome:   b.GetOutcome(),
+			Error:     b.GetError(),
+		})
+	}
+	if result.CancelErr != nil {
+		log.Printf("Error cancelling bookings for archived room %s: %v", id, result.CancelErr)
+	}
+	return resp, nil
+}
+
+// authorizeRPC checks the bearer token in the "authorization" metadata for
+// room:write, as writeAccess does for HTTP.
+func authorizeRPC(ctx context.Context) (*jwtClaims, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata required")
+	}
+	header := strings.TrimSpace(values[0])
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "authorization must be a Bearer token")
+	}
+
+	claims, err := parseToken(ctx, strings.TrimSpace(header[7:]))
+	if err != nil {
+		var fe *fiber.Error
+		if errors.As(err, &fe) && fe.Code == fiber.StatusUnauthorized {
+			return nil, status.Error(codes.Unauthenticated, fe.Message)
+		}
+		if errors.As(err, &fe) && fe.Code == fiber.StatusServiceUnavailable {
+			return nil, status.Error(codes.Unavailable, fe.Message)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !authz.HasPermission(claims.Role, claims.Permissions, authz.PermRoomWrite) {
+		return nil, status.Error(codes.PermissionDenied, "missing permission "+authz.PermRoomWrite)
+	}
+	return claims, nil
+}
+
+// commit audits and commits a change made over gRPC; Path holds the full
+// method name.
+func (s *RoomGRPCServer) commit(ctx context.Context, change *RoomChange, claims *jwtClaims, action string, roomID uuid.UUID, req proto.Message) error {
+	method, _ := grpc.Method(ctx)
+	entry := &models.RoomAuditLog{
+		RoomID:  &roomID,
+		Action:  action,
+		ActorID: claims.Subject,
+		Method:  "GRPC",
+		Path:    method,
+	}
+	if claims.Act != nil {
+		entry.ImpersonatorID = &claims.Act.Subject
+	}
+	if details, err := protojson.Marshal(req); err == nil && len(details) <= maxAuditDetails {
+		entry.Details = models.RawJSON(details)
+	}
+	if err := change.Commit(entry); err != nil {
+		log.Printf("room-service: failed to audit %s by %s: %v", action, claims.Subject, err)
+		return status.Error(codes.Internal, ErrAuditFailed.Error())
+	}
+	return nil
+}
+
+func roomStatus(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "room not found")
+	case errors.Is(err, ErrDuplicateRoomName):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrVersionConflict), errors.Is(err, ErrRoomArchived):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrBookingServiceUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case isValidationError(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Errorf(codes.Internal, "%v", err)
+}
+
+func toProtoRooms(rooms []models.Room) []*pb.Room {
+	out := make([]*pb.Room, len(rooms))
+	for i := range rooms {
+		out[i] = toProtoRoom(&rooms[i])
+	}
+	return out
+}
+
+func toProtoRoom(room *models.Room) *pb.Room {
+	out := &pb.Room{
+		Id:          room.ID.String(),
+		Name:        room.Name,
+		Capacity:    int32(room.Capacity),
+		Features:    []string(room.Features),
+		Building:    room.Building,
+		Floor:       int32(room.Floor),
+		RoomNumber:  room.RoomNumber,
+		Accessible:  room.Accessible,
+		TimeZone:    room.TimeZone,
+		Description: room.Description,
+		Version:     int32(room.Version),
+	}
+	if room.Latitude != nil && room.Longitude != nil {
+		out.HasLocation = true
+		out.Latitude = *room.Latitude
+		out.Longitude = *room.Longitude
+	}
+	if room.ArchivedAt != nil {
+		out.ArchivedAt = timestamppb.New(*room.ArchivedAt)
+	}
+	return out
+}
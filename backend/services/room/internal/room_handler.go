@@ -1,18 +1,29 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/services/booking/proto"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/authz"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/schedule"
+
+	pb "github.com/JJnvn/Software-Arch-CPRoom/backend/libs/proto/booking"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
 )
 
 type RoomHandler struct {
@@ -39,20 +50,83 @@ func NewRoomHandler(service *roomService) *RoomHandler {
 	}
 }
 
+// RegisterRoutes exposes the room API. Reads are open to any caller the
+// gateway lets through; every mutation goes through writeAccess, which checks
+// the token and audits the change.
 func (h *RoomHandler) RegisterRoutes(app *fiber.App) {
+	// static paths first so they are not taken for a room ID
+	app.Get("/rooms/audit-logs", h.ListAuditLogs)
+	app.Get("/rooms/export", h.ExportRooms)
+	app.Post("/rooms/import", h.writeAccess(models.AuditRoomsImported), h.ImportRooms)
+	app.Get("/rooms/holiday-calendars", h.ListHolidayCalendars)
+	app.Post("/rooms/holiday-calendars", h.writeAccess(models.AuditCalendarImported), h.ImportHolidayCalendar)
+	app.Delete("/rooms/holiday-calendars/:calendarId", h.writeAccess(models.AuditCalendarDeleted), h.DeleteHolidayCalendar)
+	app.Get("/rooms/exceptions", h.ListExceptions)
+	app.Post("/rooms/exceptions", h.writeAccess(models.AuditExceptionAdded), h.AddException)
+	app.Delete("/rooms/exceptions/:exceptionId", h.writeAccess(models.AuditExceptionDeleted), h.DeleteException)
+	app.Delete("/rooms/maintenance/:windowId", h.writeAccess(models.AuditMaintenanceDeleted), h.DeleteMaintenance)
+	app.Put("/rooms/equipment/:equipmentId", h.writeAccess(models.AuditEquipmentUpdated), h.UpdateEquipment)
+	app.Delete("/rooms/equipment/:equipmentId", h.writeAccess(models.AuditEquipmentRemoved), h.RemoveEquipment)
+	app.Post("/rooms/occupancy/readings", h.IngestOccupancy)
+	app.Get("/rooms/occupancy", h.ListOccupancy)
+	app.Delete("/rooms/sensors/:sensorId", h.writeAccess(models.AuditSensorRevoked), h.RevokeSensor)
+	app.Delete("/rooms/kiosks/:kioskId", h.writeAccess(models.AuditKioskRevoked), h.RevokeKiosk)
+	app.Get("/rooms/groups", h.ListGroups)
+	app.Post("/rooms/groups", h.writeAccess(models.AuditGroupCreated), h.CreateGroup)
+	app.Put("/rooms/groups/:groupId", h.writeAccess(models.AuditGroupUpdated), h.UpdateGroup)
+	app.Delete("/rooms/groups/:groupId", h.writeAccess(models.AuditGroupDeleted), h.DeleteGroup)
+	app.Put("/rooms/layouts/:layoutId", h.writeAccess(models.AuditLayoutUpdated), h.UpdateLayout)
+	app.Delete("/rooms/layouts/:layoutId", h.writeAccess(models.AuditLayoutRemoved), h.RemoveLayout)
+	app.Get("/rooms/buildings/:building/opening-hours", h.GetOpeningHours)
+	app.Put("/rooms/buildings/:building/opening-hours", h.writeAccess(models.AuditOpeningHoursSet), h.SetOpeningHours)
+	app.Get("/rooms/buildings/:building/exceptions", h.ListExceptions)
+	app.Post("/rooms/buildings/:building/exceptions", h.writeAccess(models.AuditExceptionAdded), h.AddException)
+
 	app.Get("/rooms", h.ListRooms)
 	app.Get("/rooms/:id", h.GetRoom)
-	app.Post("/rooms", h.CreateRoom)
-	app.Put("/rooms/:id", h.UpdateRoom)
-	app.Delete("/rooms/:id", h.DeleteRoom)
+	app.Post("/rooms", h.writeAccess(models.AuditRoomCreated), h.CreateRoom)
+	app.Patch("/rooms/:id", h.writeAccess(models.AuditRoomUpdated), h.UpdateRoom)
+	app.Put("/rooms/:id", h.writeAccess(models.AuditRoomUpdated), h.UpdateRoom)
+	app.Delete("/rooms/:id", h.writeAccess(models.AuditRoomArchived), h.DeleteRoom)
+	app.Post("/rooms/:id/restore", h.writeAccess(models.AuditRoomRestored), h.RestoreRoom)
+	app.Put("/rooms/:id/group", h.writeAccess(models.AuditRoomGroupChanged), h.SetRoomGroup)
+	app.Get("/rooms/:id/parts", h.GetRoomParts)
+	app.Get("/rooms/:id/occupancy", h.GetOccupancy)
+	app.Get("/rooms/:id/occupancy/history", h.GetOccupancyHistory)
+	app.Get("/rooms/:id/sensors", h.ListSensors)
+	app.Post("/rooms/:id/sensors", h.writeAccess(models.AuditSensorRegistered), h.RegisterSensor)
+	app.Get("/rooms/:id/kiosks", h.ListKiosks)
+	app.Get("/rooms/:id/qr", h.GetRoomQRCode)
+	app.Post("/rooms/:id/qr/verify", h.VerifyRoomCode)
+	app.Post("/rooms/:id/qr/rotate", h.writeAccess(models.AuditQRSecretRotated), h.RotateQRSecret)
+	app.Post("/rooms/:id/kiosks", h.writeAccess(models.AuditKioskRegistered), h.RegisterKiosk)
+	app.Put("/rooms/:id/parts", h.writeAccess(models.AuditRoomPartsSet), h.SetRoomParts)
 	app.Get("/rooms/:id/schedule", h.GetRoomSchedule)
+	app.Get("/rooms/:id/opening-hours", h.GetOpeningHours)
+	app.Put("/rooms/:id/opening-hours", h.writeAccess(models.AuditOpeningHoursSet), h.SetOpeningHours)
+	app.Get("/rooms/:id/exceptions", h.ListExceptions)
+	app.Post("/rooms/:id/exceptions", h.writeAccess(models.AuditExceptionAdded), h.AddException)
+	app.Get("/rooms/:id/maintenance", h.ListMaintenance)
+	app.Post("/rooms/:id/maintenance", h.writeAccess(models.AuditMaintenanceCreated), h.CreateMaintenance)
+	app.Get("/rooms/:id/equipment", h.ListEquipment)
+	app.Post("/rooms/:id/equipment", h.writeAccess(models.AuditEquipmentAdded), h.AddEquipment)
+	app.Get("/rooms/:id/layouts", h.ListLayouts)
+	app.Post("/rooms/:id/layouts", h.writeAccess(models.AuditLayoutAdded), h.AddLayout)
 }
 
 func (h *RoomHandler) CreateRoom(c *fiber.Ctx) error {
 	type reqBody struct {
-		Name     string   `json:"name"`
-		Capacity int      `json:"capacity"`
-		Features []string `json:"features"`
+		Name        string   `json:"name"`
+		Capacity    int      `json:"capacity"`
+		Features    []string `json:"features"`
+		Building    string   `json:"building"`
+		Floor       int      `json:"floor"`
+		RoomNumber  string   `json:"room_number"`
+		Latitude    *float64 `json:"latitude"`
+		Longitude   *float64 `json:"longitude"`
+		Accessible  bool     `json:"accessible"`
+		TimeZone    string   `json:"time_zone"`
+		Description string   `json:"description"`
 	}
 
 	var req reqBody
@@ -67,29 +141,38 @@ func (h *RoomHandler) CreateRoom(c *fiber.Ctx) error {
 	}
 
 	room := models.Room{ // ID autogenerated by DB
-		Name:     strings.TrimSpace(req.Name),
-		Capacity: req.Capacity,
-		Features: models.StringList(req.Features),
+		Name:        strings.TrimSpace(req.Name),
+		Capacity:    req.Capacity,
+		Features:    models.StringList(req.Features),
+		Building:    req.Building,
+		Floor:       req.Floor,
+		RoomNumber:  req.RoomNumber,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		Accessible:  req.Accessible,
+		TimeZone:    req.TimeZone,
+		Description: req.Description,
 	}
 
-	if err := h.service.Create(&room); err != nil {
-		// Handle unique constraint violation (Postgres code 23505)
-		if strings.Contains(strings.ToLower(err.Error()), "duplicate key") || strings.Contains(err.Error(), "23505") {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "room name already exists"})
+	if err := h.serviceFor(c).Create(&room); err != nil {
+		if isValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, ErrDuplicateRoomName) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	c.Locals(auditRoomIDKey, room.ID)
+	c.Set(fiber.HeaderETag, roomETag(&room))
 	return c.Status(fiber.StatusCreated).JSON(room)
 }
 
+// UpdateRoom applies a JSON merge patch (RFC 7396) to a room: members left
+// out are kept and members set to null are cleared. PUT is served the same
+// way for existing clients. An If-Match header makes the update conditional
+// on the room's ETag.
 func (h *RoomHandler) UpdateRoom(c *fiber.Ctx) error {
-	var room models.Room
-	if err := c.BodyParser(&room); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request",
-		})
-	}
-
 	idStr := c.Params("id")
 	uid, err := uuid.Parse(idStr)
 	if err != nil {
@@ -98,16 +181,90 @@ func (h *RoomHandler) UpdateRoom(c *fiber.Ctx) error {
 		})
 	}
 
-	room.ID = uid
-	if err := h.service.Update(&room); err != nil {
+	var ifMatch []int
+	conditional := c.Get(fiber.HeaderIfMatch) != ""
+	if conditional {
+		versions, ok := parseIfMatch(c.Get(fiber.HeaderIfMatch))
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid If-Match header"})
+		}
+		ifMatch = versions
+	}
+
+	room, previous, err := h.serviceFor(c).Patch(uid, c.Body(), ifMatch)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "room not found"})
+		case errors.Is(err, ErrVersionConflict) && conditional:
+			return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrVersionConflict), errors.Is(err, ErrDuplicateRoomName):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case isValidationError(err):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(room)
+	response := roomResponse{Room: room}
+	if room.Capacity < previous.Capacity {
+		response.Warnings = h.capacityWarnings(c.Context(), room, previous.Capacity)
+	}
+
+	c.Set(fiber.HeaderETag, roomETag(room))
+	return c.JSON(response)
+}
+
+// roomResponse is a room with any warnings about the change just made.
+type roomResponse struct {
+	*models.Room
+	Warnings []fiber.Map `json:"warnings,omitempty"`
+}
+
+// capacityWarnings flags upcoming bookings made while the room held more
+// people. Bookings do not record a head count, so every one of them may
+// expect the old capacity.
+func (h *RoomHandler) capacityWarnings(ctx context.Context, room *models.Room, previousCapacity int) []fiber.Map {
+	if h.bookingClient == nil {
+		return []fiber.Map{{
+			"code":    "capacity_reduced",
+			"message": "capacity reduced; upcoming bookings could not be checked because the booking service is unavailable",
+		}}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := h.bookingClient.DisplaceBookings(ctx, &pb.DisplaceBookingsRequest{
+		RoomId: room.ID.String(),
+		Start:  timestamppb.Now(),
+		End:    timestamppb.New(time.Now().AddDate(100, 0, 0)),
+		Action: "list",
+	})
+	if err != nil {
+		log.Printf("Error listing bookings for room %s: %v", room.ID, err)
+		return []fiber.Map{{
+			"code":    "capacity_reduced",
+			"message": "capacity reduced; upcoming bookings could not be checked",
+		}}
+	}
+	if len(resp.Bookings) == 0 {
+		return nil
+	}
+	return []fiber.Map{{
+		"code": "capacity_reduced",
+		"message": fmt.Sprintf("%d upcoming booking(s) were made when the room held %d people; it now holds %d",
+			len(resp.Bookings), previousCapacity, room.Capacity),
+		"bookings": displacedBookings(resp),
+	}}
 }
 
+// DeleteRoom archives a room. Pending and confirmed bookings that have not
+// ended yet block archival with 409 unless force=true, which cancels them and
+// notifies their owners. dry_run=true only reports those bookings.
 func (h *RoomHandler) DeleteRoom(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := uuid.Parse(idStr)
@@ -117,13 +274,145 @@ func (h *RoomHandler) DeleteRoom(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.service.Delete(id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
+	force := c.QueryBool("force")
+	dryRun := c.QueryBool("dry_run")
+	result, err := archiveRoom(c.Context(), h.serviceFor(c), h.bookingClient, id, force, dryRun, func() error {
+		return h.commitChange(c)
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrRoomHasBookings):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":             "room has upcoming bookings; retry with force=true to cancel them",
+			"impacted_bookings": displacedBookings(result.Impacted),
+		})
+	case errors.Is(err, ErrRoomArchived):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrBookingServiceUnavailable):
+		log.Printf("Error checking bookings for room %s: %v", id, err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "failed to check bookings of the room",
 		})
+	default:
+		return respondScheduleError(c, err)
 	}
 
-	return c.SendStatus(fiber.StatusNoContent)
+	if dryRun {
+		c.Locals(auditSkipKey, true)
+		return c.JSON(fiber.Map{
+			"room":              result.Room,
+			"dry_run":           true,
+			"impacted_bookings": displacedBookings(result.Impacted),
+		})
+	}
+	if !force {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	response := fiber.Map{"room": result.Room}
+	if result.CancelErr != nil {
+		log.Printf("Error cancelling bookings for archived room %s: %v", id, result.CancelErr)
+		response["error"] = "failed to cancel impacted bookings"
+	} else {
+		response["cancelled_bookings"] = displacedBookings(result.Cancelled)
+	}
+	return c.JSON(response)
+}
+
+// ImportRooms upserts rooms by name from a CSV (Content-Type text/csv or
+// format=csv) or JSON array body in the export format. Every row is checked
+// first; if any fails, nothing is saved and 422 lists the failing rows.
+// Columns or keys left out keep an existing room's values, and rows naming an
+// archived room fail. dry_run=true reports what would be created or updated.
+func (h *RoomHandler) ImportRooms(c *fiber.Ctx) error {
+	format := c.Query("format")
+	if format == "" && strings.Contains(strings.ToLower(c.Get(fiber.HeaderContentType)), "csv") {
+		format = "csv"
+	}
+
+	var (
+		rows []RoomImportRow
+		err  error
+	)
+	switch format {
+	case "csv":
+		rows, err = parseRoomCSV(bytes.NewReader(c.Body()))
+	case "", "json":
+		rows, err = parseRoomJSON(bytes.NewReader(c.Body()))
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be csv or json"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	report, err := h.serviceFor(c).ImportRooms(rows, dryRun)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrImportRejected):
+		c.Locals(auditSkipKey, true)
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error(), "report": report})
+	case errors.Is(err, ErrInvalidImport):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrDuplicateRoomName), errors.Is(err, ErrVersionConflict):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error() + "; rooms changed during the import, retry it"})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if dryRun {
+		c.Locals(auditSkipKey, true)
+	}
+	return c.JSON(report)
+}
+
+// ExportRooms writes the room catalog as JSON (default) or format=csv, in the
+// format ImportRooms reads. Archived rooms are left out unless
+// include_archived=true.
+func (h *RoomHandler) ExportRooms(c *fiber.Ctx) error {
+	rooms, err := h.serviceFor(c).List(RoomFilter{IncludeArchived: c.QueryBool("include_archived")})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	switch c.Query("format", "json") {
+	case "csv":
+		var buf bytes.Buffer
+		if err := writeRoomCSV(&buf, rooms); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Attachment("rooms.csv")
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		return c.Send(buf.Bytes())
+	case "json":
+		catalog := make([]roomFields, len(rooms))
+		for i := range rooms {
+			catalog[i] = roomFieldsOf(&rooms[i])
+		}
+		c.Attachment("rooms.json")
+		return c.JSON(catalog)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be csv or json"})
+	}
+}
+
+// RestoreRoom makes an archived room listable and bookable again. Bookings
+// cancelled when it was archived stay cancelled.
+func (h *RoomHandler) RestoreRoom(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	room, err := h.serviceFor(c).Restore(id)
+	if err != nil {
+		if errors.Is(err, ErrRoomNotArchived) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return respondScheduleError(c, err)
+	}
+	return c.JSON(room)
 }
 
 func (h *RoomHandler) GetRoom(c *fiber.Ctx) error {
@@ -134,16 +423,53 @@ func (h *RoomHandler) GetRoom(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
 	}
 
-	room, err := h.service.GetByID(id)
+	room, err := h.serviceFor(c).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "room not found"})
 	}
+
+	etag := roomETag(room)
+	c.Set(fiber.HeaderETag, etag)
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
 	return c.JSON(room)
 }
 
+// ListRooms supports q (name, description or room number), building, floor,
+// accessible, min_capacity, group_id (including subgroups), sort (name,
+// capacity, building, floor), order (asc or desc) and include_archived.
 func (h *RoomHandler) ListRooms(c *fiber.Ctx) error {
-	rooms, err := h.service.List()
+	filter := RoomFilter{
+		Query:          c.Query("q"),
+		Building:       strings.TrimSpace(c.Query("building")),
+		AccessibleOnly: c.QueryBool("accessible"),
+		MinCapacity:    c.QueryInt("min_capacity"),
+		SortBy:         c.Query("sort"),
+		Descending:     strings.EqualFold(c.Query("order"), "desc"),
+
+		IncludeArchived: c.QueryBool("include_archived"),
+	}
+	if floorStr := c.Query("floor"); floorStr != "" {
+		floor, err := strconv.Atoi(floorStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "floor must be a number"})
+		}
+		filter.Floors = []int{floor}
+	}
+	if groupStr := c.Query("group_id"); groupStr != "" {
+		groupID, err := uuid.Parse(groupStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "group_id must be a UUID"})
+		}
+		filter.GroupID = &groupID
+	}
+
+	rooms, err := h.serviceFor(c).List(filter)
 	if err != nil {
+		if isValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(rooms)
@@ -163,7 +489,7 @@ func (h *RoomHandler) GetRoomSchedule(c *fiber.Ctx) error {
 	}
 
 	// Check if room exists
-	room, err := h.service.GetByID(id)
+	room, err := h.serviceFor(c).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "room not found"})
 	}
@@ -203,10 +529,965 @@ func (h *RoomHandler) GetRoomSchedule(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"room_id":   roomID,
 		"room_name": room.Name,
 		"date":      date,
+		"time_zone": room.TimeZone,
 		"bookings":  bookings,
+	}
+
+	// Opening hours are owned here, so closed periods are added locally
+	// rather than by the booking service.
+	day := time.Now()
+	if date != "" {
+		if parsed, err := time.Parse(schedule.DateLayout, date); err == nil {
+			day = parsed
+		}
+	}
+	calendar, err := h.serviceFor(c).Calendar(room, day, day)
+	if err != nil {
+		log.Printf("Error loading opening hours for room %s: %v", roomID, err)
+		return c.JSON(response)
+	}
+	loc := calendar.Location()
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	open, _ := calendar.OpenOn(dayStart)
+	response["opening_hours"] = open
+	response["closed_periods"] = calendar.ClosedPeriods(dayStart, dayStart.AddDate(0, 0, 1))
+
+	windows, err := h.serviceFor(c).ListMaintenance(id, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("Error loading maintenance windows for room %s: %v", roomID, err)
+		return c.JSON(response)
+	}
+	response["maintenance"] = windows
+
+	return c.JSON(response)
+}
+
+// CreateMaintenance blocks a room for repairs. action decides what happens to
+// the bookings the window overlaps: list (default) only reports them, cancel
+// cancels them and relocate moves them to another free room. With dry_run the
+// window is not saved and the bookings are only listed.
+func (h *RoomHandler) CreateMaintenance(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req struct {
+		Start  time.Time `json:"start_time"`
+		End    time.Time `json:"end_time"`
+		Reason string    `json:"reason"`
+		Action string    `json:"action"`
+		DryRun bool      `json:"dry_run"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+	switch req.Action {
+	case "", "list", "cancel", "relocate":
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "action must be list, cancel or relocate"})
+	}
+	if req.DryRun {
+		req.Action = "list"
+	}
+
+	window, err := h.serviceFor(c).PlanMaintenance(id, MaintenanceInput{Start: req.Start, End: req.End, Reason: req.Reason})
+	if err != nil {
+		if errors.Is(err, ErrInvalidWindow) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return respondScheduleError(c, err)
+	}
+
+	if h.bookingClient == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "booking service unavailable",
+		})
+	}
+
+	if !req.DryRun {
+		if err := h.serviceFor(c).CreateMaintenance(window); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		// The booking service must see the window before displacing.
+		if err := h.commitChange(c); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	response := fiber.Map{"window": window, "dry_run": req.DryRun}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 30*time.Second)
+	defer cancel()
+
+	resp, err := h.bookingClient.DisplaceBookings(ctx, &pb.DisplaceBookingsRequest{
+		RoomId: id.String(),
+		Start:  timestamppb.New(window.StartsAt),
+		End:    timestamppb.New(window.EndsAt),
+		Action: req.Action,
+		Reason: maintenanceReason(window),
 	})
+	if err != nil {
+		// The window is saved and already blocks new bookings; the caller
+		// can retry the displacement by listing the room's bookings.
+		log.Printf("Error displacing bookings for room %s: %v", id, err)
+		response["error"] = "failed to process affected bookings"
+	} else {
+		response["affected_bookings"] = displacedBookings(resp)
+	}
+
+	if req.DryRun {
+		c.Locals(auditSkipKey, true)
+		return c.JSON(response)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+func displacedBookings(resp *pb.DisplaceBookingsResponse) []fiber.Map {
+	bookings := make([]fiber.Map, len(resp.Bookings))
+	for i, b := range resp.Bookings {
+		bookings[i] = fiber.Map{
+			"booking_id":    b.Booking.GetBookingId(),
+			"user_id":       b.Booking.GetUserId(),
+			"start_time":    b.Booking.GetStart().AsTime(),
+			"end_time":      b.Booking.GetEnd().AsTime(),
+			"status":        b.Booking.GetStatus(),
+			"outcome":       b.Outcome,
+			"new_room_id":   b.NewRoomId,
+			"new_room_name": b.NewRoomName,
+			"error":         b.Error,
+		}
+	}
+	return bookings
+}
+
+func maintenanceReason(window *models.MaintenanceWindow) string {
+	if window.Reason == "" {
+		return "room maintenance"
+	}
+	return "room maintenance: " + window.Reason
+}
+
+// ListMaintenance lists the room's current and upcoming maintenance windows.
+func (h *RoomHandler) ListMaintenance(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	windows, err := h.serviceFor(c).ListMaintenance(id, time.Now(), time.Time{})
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.JSON(fiber.Map{"windows": windows})
+}
+
+// DeleteMaintenance ends a maintenance window early or removes a planned one.
+// Cancelled or relocated bookings are not restored.
+func (h *RoomHandler) DeleteMaintenance(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("windowId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	if err := h.serviceFor(c).DeleteMaintenance(id); err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) ListEquipment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	items, err := h.serviceFor(c).ListEquipment(id)
+	if err != nil {
+		return respondEquipmentError(c, err)
+	}
+	return c.JSON(fiber.Map{"equipment": items})
+}
+
+// AddEquipment records a stock of equipment in the room. Movable items can be
+// reserved as add-ons when booking any room.
+func (h *RoomHandler) AddEquipment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req EquipmentInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	item, err := h.serviceFor(c).AddEquipment(id, req)
+	if err != nil {
+		return respondEquipmentError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(item)
+}
+
+// UpdateEquipment replaces an item's name, quantity, condition, movable flag
+// and notes, e.g. to mark units broken.
+func (h *RoomHandler) UpdateEquipment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("equipmentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req EquipmentInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	item, err := h.serviceFor(c).UpdateEquipment(id, req)
+	if err != nil {
+		return respondEquipmentError(c, err)
+	}
+	c.Locals(auditRoomIDKey, item.RoomID)
+	return c.JSON(item)
+}
+
+func (h *RoomHandler) RemoveEquipment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("equipmentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	item, err := h.serviceFor(c).RemoveEquipment(id)
+	if err != nil {
+		return respondEquipmentError(c, err)
+	}
+	c.Locals(auditRoomIDKey, item.RoomID)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) ListLayouts(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	layouts, err := h.serviceFor(c).ListLayouts(id)
+	if err != nil {
+		return respondLayoutError(c, err)
+	}
+	return c.JSON(fiber.Map{"layouts": layouts})
+}
+
+// AddLayout adds a named layout with its own capacity and setup and teardown
+// time. Room search and bookings can then ask for it by name.
+func (h *RoomHandler) AddLayout(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req LayoutInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	layout, err := h.serviceFor(c).AddLayout(id, req)
+	if err != nil {
+		return respondLayoutError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(layout)
+}
+
+func (h *RoomHandler) UpdateLayout(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("layoutId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req LayoutInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	layout, err := h.serviceFor(c).UpdateLayout(id, req)
+	if err != nil {
+		return respondLayoutError(c, err)
+	}
+	c.Locals(auditRoomIDKey, layout.RoomID)
+	return c.JSON(layout)
+}
+
+func (h *RoomHandler) RemoveLayout(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("layoutId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	layout, err := h.serviceFor(c).RemoveLayout(id)
+	if err != nil {
+		return respondLayoutError(c, err)
+	}
+	c.Locals(auditRoomIDKey, layout.RoomID)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListGroups returns every room group; parent_id links them into a tree.
+func (h *RoomHandler) ListGroups(c *fiber.Ctx) error {
+	groups, err := h.serviceFor(c).ListGroups()
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.JSON(fiber.Map{"groups": groups})
+}
+
+func (h *RoomHandler) CreateGroup(c *fiber.Ctx) error {
+	var req GroupInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	group, err := h.serviceFor(c).CreateGroup(req)
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(group)
+}
+
+// UpdateGroup renames a group or moves it under another parent.
+func (h *RoomHandler) UpdateGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("groupId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req GroupInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	group, err := h.serviceFor(c).UpdateGroup(id, req)
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.JSON(group)
+}
+
+func (h *RoomHandler) DeleteGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("groupId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	if err := h.serviceFor(c).DeleteGroup(id); err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SetRoomGroup moves a room into the group_id of the body, or out of its
+// group when group_id is null.
+func (h *RoomHandler) SetRoomGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req struct {
+		GroupID *uuid.UUID `json:"group_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	room, err := h.serviceFor(c).SetRoomGroup(id, req.GroupID)
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	c.Set(fiber.HeaderETag, roomETag(room))
+	return c.JSON(room)
+}
+
+// GetRoomParts lists the rooms a combined room occupies ("parts") and the
+// combined rooms this room is part of ("part_of").
+func (h *RoomHandler) GetRoomParts(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	parts, partOf, err := h.serviceFor(c).RoomParts(id)
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.JSON(fiber.Map{"parts": parts, "part_of": partOf})
+}
+
+// SetRoomParts makes the room a combined room of the room_ids in the body,
+// so a booking of it blocks each part and a booking of a part blocks it. An
+// empty list makes it a plain room again.
+func (h *RoomHandler) SetRoomParts(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req struct {
+		RoomIDs []uuid.UUID `json:"room_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	parts, err := h.serviceFor(c).SetRoomParts(id, req.RoomIDs)
+	if err != nil {
+		return respondGroupError(c, err)
+	}
+	return c.JSON(fiber.Map{"parts": parts})
+}
+
+// RegisterSensor adds an occupancy sensor to the room. The response carries
+// the sensor's key, which is not shown again.
+func (h *RoomHandler) RegisterSensor(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	sensor, key, err := h.serviceFor(c).RegisterSensor(id, req.Name)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"sensor": sensor, "key": key})
+}
+
+// ListSensors lists the room's sensors. Sensors are managed by admins, so
+// room:write is required like for registering one.
+func (h *RoomHandler) ListSensors(c *fiber.Ctx) error {
+	if _, err := requirePermission(c, authz.PermRoomWrite); err != nil {
+		return respondError(c, err)
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	sensors, err := h.serviceFor(c).ListSensors(id)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.JSON(fiber.Map{"sensors": sensors})
+}
+
+func (h *RoomHandler) RevokeSensor(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("sensorId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	sensor, err := h.serviceFor(c).RevokeSensor(id)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	c.Locals(auditRoomIDKey, sensor.RoomID)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RegisterKiosk adds a display kiosk to the room. The response carries the
+// kiosk's key, which is not shown again.
+func (h *RoomHandler) RegisterKiosk(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	kiosk, key, err := h.serviceFor(c).RegisterKiosk(id, req.Name)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"kiosk": kiosk, "key": key})
+}
+
+// ListKiosks lists the room's kiosks, which like sensors need room:write.
+func (h *RoomHandler) ListKiosks(c *fiber.Ctx) error {
+	if _, err := requirePermission(c, authz.PermRoomWrite); err != nil {
+		return respondError(c, err)
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	kiosks, err := h.serviceFor(c).ListKiosks(id)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.JSON(fiber.Map{"kiosks": kiosks})
+}
+
+func (h *RoomHandler) RevokeKiosk(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("kioskId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	kiosk, err := h.serviceFor(c).RevokeKiosk(id)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	c.Locals(auditRoomIDKey, kiosk.RoomID)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetRoomQRCode returns the room's signed QR code as a PNG (the default), an
+// SVG or, with format=json, the link itself. Codes are printed by admins, so
+// room:write is required.
+func (h *RoomHandler) GetRoomQRCode(c *fiber.Ctx) error {
+	if _, err := requirePermission(c, authz.PermRoomWrite); err != nil {
+		return respondError(c, err)
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+	size := c.QueryInt("size", 256)
+	if size < 64 || size > 2048 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "size must be between 64 and 2048"})
+	}
+
+	code, err := h.serviceFor(c).RoomQRCode(id)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+
+	var image []byte
+	switch format := c.Query("format", "png"); format {
+	case "json":
+		return c.JSON(code)
+	case "png":
+		c.Set(fiber.HeaderContentType, "image/png")
+		image, err = qrPNG(code.URL, size)
+	case "svg":
+		c.Set(fiber.HeaderContentType, "image/svg+xml")
+		image, err = qrSVG(code.URL, size)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be png, svg or json"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(image)
+}
+
+// RotateQRSecret signs the room's QR code with a new secret, so codes printed
+// before stop working. The new link is returned.
+func (h *RoomHandler) RotateQRSecret(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	code, err := h.serviceFor(c).RotateQRSecret(id)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.JSON(code)
+}
+
+// VerifyRoomCode answers 204 if sig is the room's current QR code signature,
+// for the booking service to check scans without holding the secrets. Any
+// signed-in user may ask; the answer only confirms a code they scanned.
+func (h *RoomHandler) VerifyRoomCode(c *fiber.Ctx) error {
+	if _, err := parseJWTClaims(c); err != nil {
+		return respondError(c, err)
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+	var req struct {
+		Signature string `json:"sig"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	if err := h.serviceFor(c).VerifyRoomCode(id, req.Signature); err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// IngestOccupancy accepts readings from a sensor authenticated by its key,
+// sent as X-API-Key or "Authorization: ApiKey <key>". The body is one reading,
+// an array of readings, or {"readings": [...]} for sensors that buffer while
+// offline.
+func (h *RoomHandler) IngestOccupancy(c *fiber.Ctx) error {
+	key := strings.TrimSpace(c.Get("X-API-Key"))
+	if auth := c.Get(fiber.HeaderAuthorization); key == "" && strings.HasPrefix(strings.ToLower(auth), "apikey ") {
+		key = strings.TrimSpace(auth[len("apikey "):])
+	}
+	sensor, err := h.serviceFor(c).AuthenticateSensor(key)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+
+	var readings []OccupancyInput
+	body := bytes.TrimSpace(c.Body())
+	switch {
+	case bytes.HasPrefix(body, []byte("[")):
+		err = json.Unmarshal(body, &readings)
+	case bytes.Contains(body, []byte(`"readings"`)):
+		var batch struct {
+			Readings []OccupancyInput `json:"readings"`
+		}
+		err = json.Unmarshal(body, &batch)
+		readings = batch.Readings
+	default:
+		var reading OccupancyInput
+		err = json.Unmarshal(body, &reading)
+		readings = []OccupancyInput{reading}
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	saved, err := h.serviceFor(c).RecordOccupancy(sensor, readings)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"accepted": len(saved)})
+}
+
+// ListOccupancy returns the current occupancy of the rooms in room_ids
+// (comma-separated), or of every room with readings.
+func (h *RoomHandler) ListOccupancy(c *fiber.Ctx) error {
+	var ids []uuid.UUID
+	for _, idStr := range strings.Split(c.Query("room_ids"), ",") {
+		if idStr = strings.TrimSpace(idStr); idStr == "" {
+			continue
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "room_ids must be comma-separated UUIDs"})
+		}
+		ids = append(ids, id)
+	}
+
+	occupancy, err := h.serviceFor(c).CurrentOccupancy(ids)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.JSON(fiber.Map{"rooms": occupancy})
+}
+
+func (h *RoomHandler) GetOccupancy(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+	if _, err := h.serviceFor(c).GetByID(id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "room not found"})
+	}
+
+	occupancy, err := h.serviceFor(c).CurrentOccupancy([]uuid.UUID{id})
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.JSON(occupancy[0])
+}
+
+// GetOccupancyHistory returns the room's readings between from and to
+// (RFC 3339), by default the last 24 hours. At most 7 days are returned.
+func (h *RoomHandler) GetOccupancyHistory(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	to := time.Now()
+	if toStr := c.Query("to"); toStr != "" {
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to time format"})
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromStr := c.Query("from"); fromStr != "" {
+		if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from time format"})
+		}
+	}
+	if !from.Before(to) || to.Sub(from) > 7*24*time.Hour {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to and at most 7 days earlier"})
+	}
+
+	readings, err := h.serviceFor(c).OccupancyHistory(id, from, to)
+	if err != nil {
+		return respondDeviceError(c, err)
+	}
+	return c.JSON(fiber.Map{"readings": readings})
+}
+
+// scheduleScope reads the room or building a schedule route addresses. Routes
+// with neither address every room.
+func scheduleScope(c *fiber.Ctx) (ScheduleScope, error) {
+	if idStr := c.Params("id"); idStr != "" {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return ScheduleScope{}, fiber.NewError(fiber.StatusBadRequest, "invalid UUID format")
+		}
+		return ScheduleScope{RoomID: &id}, nil
+	}
+	if building := c.Params("building"); building != "" {
+		building, err := url.PathUnescape(building)
+		if err != nil || strings.TrimSpace(building) == "" {
+			return ScheduleScope{}, fiber.NewError(fiber.StatusBadRequest, "invalid building")
+		}
+		return ScheduleScope{Building: strings.TrimSpace(building)}, nil
+	}
+	return ScheduleScope{}, nil
+}
+
+func (h *RoomHandler) GetOpeningHours(c *fiber.Ctx) error {
+	scope, err := scheduleScope(c)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+
+	hours, err := h.serviceFor(c).OpeningHours(scope)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.JSON(fiber.Map{"hours": hoursResponse(hours)})
+}
+
+// SetOpeningHours replaces the weekly hours of a room or building. Send an
+// empty list to inherit from the building or global hours again.
+func (h *RoomHandler) SetOpeningHours(c *fiber.Ctx) error {
+	scope, err := scheduleScope(c)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+
+	var req struct {
+		Hours []HoursInput `json:"hours"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	hours, err := h.serviceFor(c).SetOpeningHours(scope, req.Hours)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.JSON(fiber.Map{"hours": hoursResponse(hours)})
+}
+
+// ListExceptions lists exception dates between from and to (YYYY-MM-DD,
+// default today and 90 days later).
+func (h *RoomHandler) ListExceptions(c *fiber.Ctx) error {
+	scope, err := scheduleScope(c)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+
+	from := time.Now()
+	to := from.AddDate(0, 0, 90)
+	for param, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		if raw := c.Query(param); raw != "" {
+			parsed, err := time.Parse(schedule.DateLayout, raw)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": param + " must be YYYY-MM-DD"})
+			}
+			*dst = parsed
+		}
+	}
+
+	exceptions, err := h.serviceFor(c).ListExceptions(scope, from, to)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+
+	result := make([]fiber.Map, len(exceptions))
+	for i := range exceptions {
+		result[i] = exceptionResponse(&exceptions[i])
+	}
+	return c.JSON(fiber.Map{"exceptions": result})
+}
+
+func (h *RoomHandler) AddException(c *fiber.Ctx) error {
+	scope, err := scheduleScope(c)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+
+	var req ExceptionInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	exception, err := h.serviceFor(c).AddException(scope, req)
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(exceptionResponse(exception))
+}
+
+func (h *RoomHandler) DeleteException(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("exceptionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	if err := h.serviceFor(c).DeleteException(id); err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ImportHolidayCalendar takes an iCalendar (text/calendar) body. The name
+// query parameter identifies the calendar; building limits it to one building.
+func (h *RoomHandler) ImportHolidayCalendar(c *fiber.Ctx) error {
+	calendar, err := h.serviceFor(c).ImportHolidayCalendar(c.Query("name"), c.Query("building"), bytes.NewReader(c.Body()))
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(calendar)
+}
+
+func (h *RoomHandler) ListHolidayCalendars(c *fiber.Ctx) error {
+	calendars, err := h.serviceFor(c).ListHolidayCalendars()
+	if err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.JSON(fiber.Map{"calendars": calendars})
+}
+
+func (h *RoomHandler) DeleteHolidayCalendar(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("calendarId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid UUID format"})
+	}
+
+	if err := h.serviceFor(c).DeleteHolidayCalendar(id); err != nil {
+		return respondScheduleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func hoursResponse(hours []models.OpeningHours) []fiber.Map {
+	result := make([]fiber.Map, len(hours))
+	for i, h := range hours {
+		result[i] = fiber.Map{
+			"id":      h.ID,
+			"weekday": h.Weekday,
+			"opens":   schedule.FormatClock(h.OpensMinute),
+			"closes":  schedule.FormatClock(h.ClosesMinute),
+		}
+	}
+	return result
+}
+
+func exceptionResponse(e *models.ScheduleException) fiber.Map {
+	result := fiber.Map{
+		"id":          e.ID,
+		"room_id":     e.RoomID,
+		"building":    e.Building,
+		"date":        e.Date.Format(schedule.DateLayout),
+		"closed":      e.Closed,
+		"reason":      e.Reason,
+		"calendar_id": e.CalendarID,
+	}
+	if !e.Closed {
+		result["opens"] = schedule.FormatClock(e.OpensMinute)
+		result["closes"] = schedule.FormatClock(e.ClosesMinute)
+	}
+	return result
+}
+
+func respondScheduleError(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &fiberErr):
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "not found"})
+	case errors.Is(err, ErrInvalidSchedule), errors.Is(err, ErrInvalidCalendar):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func respondEquipmentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "not found"})
+	case errors.Is(err, ErrInvalidEquipment):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func respondLayoutError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "not found"})
+	case errors.Is(err, ErrInvalidLayout):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrDuplicateLayout):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func respondGroupError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "not found"})
+	case errors.Is(err, ErrInvalidGroup), errors.Is(err, ErrInvalidCombination):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrGroupNotEmpty):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func respondDeviceError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "not found"})
+	case errors.Is(err, ErrInvalidSensorKey):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidRoomCode):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidSensor), errors.Is(err, ErrInvalidKiosk), errors.Is(err, ErrInvalidReading):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func isValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidPatch) ||
+		errors.Is(err, ErrInvalidRoomName) ||
+		errors.Is(err, ErrInvalidCapacity) ||
+		errors.Is(err, ErrInvalidTimeZone) ||
+		errors.Is(err, ErrInvalidLocation) ||
+		errors.Is(err, ErrInvalidRoomSortBy)
 }
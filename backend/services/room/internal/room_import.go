@@ -0,0 +1,341 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+)
+
+var (
+	ErrInvalidImport = errors.New("invalid room import")
+	// ErrImportRejected means at least one row failed validation; the report
+	// says which. Nothing was saved.
+	ErrImportRejected = errors.New("import rejected: fix the rows listed in the report")
+)
+
+// roomCSVColumns is the header of room exports and CSV imports. Features are
+// separated by semicolons; an empty latitude and longitude mean no location.
+var roomCSVColumns = []string{
+	"name", "capacity", "features", "building", "floor", "room_number",
+	"latitude", "longitude", "accessible", "time_zone", "description",
+}
+
+// Import row outcomes.
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+)
+
+// RoomImportRow is one parsed row. Row is the CSV line or the 1-based index
+// in a JSON array; Err is set when the row could not be parsed. Columns holds
+// the CSV columns or JSON keys the row gave: an existing room keeps its
+// values for the others.
+type RoomImportRow struct {
+	Row     int
+	Fields  roomFields
+	Columns map[string]bool
+	Err     error
+}
+
+// ImportRowResult reports what happened, or would happen, to one row.
+type ImportRowResult struct {
+	Row    int    `json:"row"`
+	Name   string `json:"name"`
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport summarises an import. Counts are only set when every row is
+// valid.
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+// parseRoomCSV reads rooms under a header naming any of roomCSVColumns in
+// any order; name is required.
+func parseRoomCSV(r io.Reader) ([]RoomImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header row", ErrInvalidImport)
+	}
+	index := map[string]int{}
+	columns := map[string]bool{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !containsString(roomCSVColumns, column) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, column)
+		}
+		index[column] = i
+		columns[column] = true
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, fmt.Errorf("%w: name column is required", ErrInvalidImport)
+	}
+
+	var rows []RoomImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, RoomImportRow{Row: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := RoomImportRow{Row: line, Columns: columns}
+		row.Fields, row.Err = csvRoomFields(get)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func csvRoomFields(get func(column string) string) (roomFields, error) {
+	fields := roomFields{
+		Name:        get("name"),
+		Building:    get("building"),
+		RoomNumber:  get("room_number"),
+		TimeZone:    get("time_zone"),
+		Description: get("description"),
+	}
+	var err error
+	if v := get("capacity"); v != "" {
+		if fields.Capacity, err = strconv.Atoi(v); err != nil {
+			return fields, errors.New("capacity must be a whole number")
+		}
+	}
+	if v := get("floor"); v != "" {
+		if fields.Floor, err = strconv.Atoi(v); err != nil {
+			return fields, errors.New("floor must be a whole number")
+		}
+	}
+	if v := get("accessible"); v != "" {
+		if fields.Accessible, err = strconv.ParseBool(v); err != nil {
+			return fields, errors.New("accessible must be true or false")
+		}
+	}
+	for _, feature := range strings.Split(get("features"), ";") {
+		if feature = strings.TrimSpace(feature); feature != "" {
+			fields.Features = append(fields.Features, feature)
+		}
+	}
+	for column, target := range map[string]**float64{"latitude": &fields.Latitude, "longitude": &fields.Longitude} {
+		if v := get(column); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fields, fmt.Errorf("%s must be a number", column)
+			}
+			*target = &f
+		}
+	}
+	return fields, nil
+}
+
+// parseRoomJSON reads a JSON array of rooms in the export format.
+func parseRoomJSON(r io.Reader) ([]RoomImportRow, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, fmt.Errorf("%w: body must be a JSON array of rooms", ErrInvalidImport)
+	}
+
+	rows := make([]RoomImportRow, len(items))
+	for i, item := range items {
+		rows[i].Row = i + 1
+		decoder := json.NewDecoder(bytes.NewReader(item))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rows[i].Fields); err != nil {
+			rows[i].Err = errors.New(strings.TrimPrefix(err.Error(), "json: "))
+			continue
+		}
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(item, &keys); err != nil {
+			rows[i].Err = errors.New("each room must be a JSON object")
+			continue
+		}
+		rows[i].Columns = make(map[string]bool, len(keys))
+		for key := range keys {
+			rows[i].Columns[key] = true
+		}
+	}
+	return rows, nil
+}
+
+// ImportRooms validates every row and upserts the rooms by name in one
+// transaction. Rows only change the columns they give. Any invalid row, or a
+// row naming an archived room, rejects the whole import with
+// ErrImportRejected. With dryRun the report is built but nothing is saved.
+func (s *roomService) ImportRooms(rows []RoomImportRow, dryRun bool) (*ImportReport, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no rooms to import", ErrInvalidImport)
+	}
+
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		names = append(names, strings.TrimSpace(row.Fields.Name))
+	}
+	existing, err := s.repo.GetByNames(names)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*models.Room, len(existing))
+	for i := range existing {
+		byName[existing[i].Name] = &existing[i]
+	}
+
+	report := &ImportReport{DryRun: dryRun, Rows: make([]ImportRowResult, len(rows))}
+	var creates, updates []models.Room
+	seen := map[string]int{}
+	rejected := false
+	for i, row := range rows {
+		result := &report.Rows[i]
+		result.Row = row.Row
+		result.Name = strings.TrimSpace(row.Fields.Name)
+
+		room := models.Room{}
+		current, exists := byName[result.Name]
+		if exists {
+			room = *current
+		}
+		err := row.Err
+		if err == nil && exists && current.ArchivedAt != nil {
+			err = errors.New("room is archived; restore it before importing")
+		}
+		if err == nil {
+			mergeRoomFields(roomFieldsOf(&room), row.Fields, row.Columns).applyTo(&room)
+			err = normalizeRoom(&room)
+		}
+		if err == nil && seen[room.Name] > 0 {
+			err = fmt.Errorf("duplicate of row %d", seen[room.Name])
+		}
+		if err != nil {
+			result.Error = err.Error()
+			rejected = true
+			continue
+		}
+		seen[room.Name] = row.Row
+
+		switch {
+		case !exists:
+			result.Action = ImportCreate
+			creates = append(creates, room)
+		case sameRoomFields(roomFieldsOf(current), roomFieldsOf(&room)):
+			result.Action = ImportUnchanged
+		default:
+			result.Action = ImportUpdate
+			updates = append(updates, room)
+		}
+	}
+	if rejected {
+		return report, ErrImportRejected
+	}
+
+	report.Created, report.Updated = len(creates), len(updates)
+	report.Unchanged = len(rows) - len(creates) - len(updates)
+	if dryRun {
+		return report, nil
+	}
+
+	if err := s.repo.ImportRooms(creates, updates); err != nil {
+		return nil, err
+	}
+	for i := range creates {
+		s.publishRoomEvent(events.RoomCreatedEvent, &creates[i])
+	}
+	for i := range updates {
+		s.publishRoomEvent(events.RoomUpdatedEvent, &updates[i])
+	}
+	return report, nil
+}
+
+// mergeRoomFields overlays the fields named in columns onto the room's
+// current fields, so a column left out of an import does not clear it.
+func mergeRoomFields(current, given roomFields, columns map[string]bool) roomFields {
+	merged := reflect.ValueOf(&current).Elem()
+	src := reflect.ValueOf(given)
+	for i := 0; i < src.NumField(); i++ {
+		if columns[strings.Split(src.Type().Field(i).Tag.Get("json"), ",")[0]] {
+			merged.Field(i).Set(src.Field(i))
+		}
+	}
+	return current
+}
+
+// writeRoomCSV writes rooms in the format parseRoomCSV reads.
+func writeRoomCSV(w io.Writer, rooms []models.Room) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(roomCSVColumns); err != nil {
+		return err
+	}
+	formatFloat := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*f, 'f', -1, 64)
+	}
+	for _, room := range rooms {
+		record := []string{
+			room.Name,
+			strconv.Itoa(room.Capacity),
+			strings.Join(room.Features, ";"),
+			room.Building,
+			strconv.Itoa(room.Floor),
+			room.RoomNumber,
+			formatFloat(room.Latitude),
+			formatFloat(room.Longitude),
+			strconv.FormatBool(room.Accessible),
+			room.TimeZone,
+			room.Description,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// sameRoomFields compares two rooms' attributes, treating no features and an
+// empty feature list alike.
+func sameRoomFields(a, b roomFields) bool {
+	if len(a.Features) == 0 && len(b.Features) == 0 {
+		a.Features, b.Features = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
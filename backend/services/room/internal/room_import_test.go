@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
+)
+
+func TestImportKeepsColumnsLeftOut(t *testing.T) {
+	lat := 13.7
+	current := &models.Room{
+		Name:        "Lab 1",
+		Capacity:    30,
+		Features:    models.StringList{"projector"},
+		Building:    "ENG4",
+		Floor:       3,
+		Latitude:    &lat,
+		Longitude:   &lat,
+		TimeZone:    "Asia/Bangkok",
+		Description: "Teaching lab",
+	}
+
+	tests := []struct {
+		name  string
+		parse func() ([]RoomImportRow, error)
+	}{
+		{"csv", func() ([]RoomImportRow, error) {
+			return parseRoomCSV(strings.NewReader("name,capacity\nLab 1,40\n"))
+		}},
+		{"json", func() ([]RoomImportRow, error) {
+			return parseRoomJSON(strings.NewReader(`[{"name": "Lab 1", "capacity": 40}]`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := tt.parse()
+			if err != nil || len(rows) != 1 || rows[0].Err != nil {
+				t.Fatalf("parse: %v %+v", err, rows)
+			}
+			got := mergeRoomFields(roomFieldsOf(current), rows[0].Fields, rows[0].Columns)
+
+			want := roomFieldsOf(current)
+			want.Capacity = 40
+			if !sameRoomFields(got, want) {
+				t.Errorf("merged = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestImportClearsColumnsGivenEmpty(t *testing.T) {
+	lat := 13.7
+	current := &models.Room{Name: "Lab 1", Capacity: 30, Latitude: &lat, Longitude: &lat, Description: "Teaching lab"}
+
+	rows, err := parseRoomJSON(strings.NewReader(`[{"name": "Lab 1", "latitude": null, "longitude": null, "description": ""}]`))
+	if err != nil || rows[0].Err != nil {
+		t.Fatalf("parse: %v %+v", err, rows)
+	}
+	got := mergeRoomFields(roomFieldsOf(current), rows[0].Fields, rows[0].Columns)
+	if got.Latitude != nil || got.Longitude != nil || got.Description != "" || got.Capacity != 30 {
+		t.Errorf("merged = %+v", got)
+	}
+}
+
+// namedRooms serves GetByNames; the import tests never reach other methods.
+type namedRooms struct {
+	RoomRepository
+	rooms []models.Room
+}
+
+func (r *namedRooms) GetByNames(names []string) ([]models.Room, error) {
+	var found []models.Room
+	for _, room := range r.rooms {
+		if containsString(names, room.Name) {
+			found = append(found, room)
+		}
+	}
+	return found, nil
+}
+
+func TestImportRejectsArchivedRooms(t *testing.T) {
+	archivedAt := time.Now()
+	s := &roomService{repo: &namedRooms{rooms: []models.Room{{Name: "Old lab", Capacity: 10, ArchivedAt: &archivedAt}}}}
+
+	rows, err := parseRoomCSV(strings.NewReader("name,capacity\nOld lab,20\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.ImportRooms(rows, true)
+	if !errors.Is(err, ErrImportRejected) {
+		t.Fatalf("got %v, want ErrImportRejected", err)
+	}
+	if report.Rows[0].Error == "" || report.Rows[0].Action != "" {
+		t.Errorf("archived room not reported as a conflict: %+v", report.Rows[0])
+	}
+}
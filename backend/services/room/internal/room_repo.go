@@ -1,17 +1,128 @@
 package internal
 
 import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/schedule"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RoomRepository interface {
 	Create(room *models.Room) error
-	Update(room *models.Room) error
-	Delete(id uuid.UUID) error
+	Update(room *models.Room, version int) error
+	Archive(id uuid.UUID, at time.Time) error
+	Restore(id uuid.UUID) error
 	GetByID(id uuid.UUID) (*models.Room, error)
-	List() ([]models.Room, error)
+	GetByIDs(ids []uuid.UUID) ([]models.Room, error)
+	GetByNames(names []string) ([]models.Room, error)
+	ImportRooms(creates, updates []models.Room) error
+	List(filter RoomFilter) ([]models.Room, error)
+
+	ListOpeningHours(scope ScheduleScope) ([]models.OpeningHours, error)
+	ReplaceOpeningHours(scope ScheduleScope, hours []models.OpeningHours) error
+	ScheduleRules(room *models.Room, from, to time.Time) ([]models.OpeningHours, []models.ScheduleException, error)
+	CreateException(exception *models.ScheduleException) error
+	ListExceptions(scope ScheduleScope, from, to time.Time) ([]models.ScheduleException, error)
+	DeleteException(id uuid.UUID) error
+	ImportHolidayCalendar(calendar *models.HolidayCalendar, exceptions []models.ScheduleException) error
+	ListHolidayCalendars() ([]models.HolidayCalendar, error)
+	DeleteHolidayCalendar(id uuid.UUID) error
+
+	CreateMaintenance(window *models.MaintenanceWindow) error
+	ListMaintenance(roomID uuid.UUID, from, to time.Time) ([]models.MaintenanceWindow, error)
+	DeleteMaintenance(id uuid.UUID) error
+
+	ListEquipment(roomID uuid.UUID) ([]models.Equipment, error)
+	GetEquipment(id uuid.UUID) (*models.Equipment, error)
+	CreateEquipment(item *models.Equipment) error
+	UpdateEquipment(item *models.Equipment) error
+	DeleteEquipment(id uuid.UUID) error
+
+	ListLayouts(roomID uuid.UUID) ([]models.Layout, error)
+	GetLayout(id uuid.UUID) (*models.Layout, error)
+	CreateLayout(layout *models.Layout) error
+	UpdateLayout(layout *models.Layout) error
+	DeleteLayout(id uuid.UUID) error
+
+	ListGroups() ([]models.RoomGroup, error)
+	GetGroup(id uuid.UUID) (*models.RoomGroup, error)
+	GroupSubtree(id uuid.UUID) ([]uuid.UUID, error)
+	CreateGroup(group *models.RoomGroup) error
+	UpdateGroup(group *models.RoomGroup) error
+	DeleteGroup(id uuid.UUID) error
+	SetRoomGroup(roomID uuid.UUID, groupID *uuid.UUID) error
+	RoomParts(id uuid.UUID) (parts, partOf []models.Room, err error)
+	ReplaceRoomParts(id uuid.UUID, partIDs []uuid.UUID) error
+
+	CreateSensor(sensor *models.RoomSensor) error
+	ListSensors(roomID uuid.UUID) ([]models.RoomSensor, error)
+	RevokeSensor(id uuid.UUID, at time.Time) (*models.RoomSensor, error)
+	GetSensorByKeyHash(hash string) (*models.RoomSensor, error)
+	CreateKiosk(kiosk *models.RoomKiosk) error
+	ListKiosks(roomID uuid.UUID) ([]models.RoomKiosk, error)
+	RevokeKiosk(id uuid.UUID, at time.Time) (*models.RoomKiosk, error)
+	EnsureQRSecret(secret *models.RoomQRSecret) (*models.RoomQRSecret, error)
+	SaveQRSecret(secret *models.RoomQRSecret) error
+	GetQRSecret(roomID uuid.UUID) (*models.RoomQRSecret, error)
+	RecordOccupancy(sensorID uuid.UUID, readings []models.OccupancyReading, at time.Time) (*models.OccupancyReading, error)
+	LatestOccupancy(roomIDs []uuid.UUID) ([]models.OccupancyReading, error)
+	OccupancyHistory(roomID uuid.UUID, from, to time.Time) ([]models.OccupancyReading, error)
+
+	CreateAuditLog(entry *models.RoomAuditLog) error
+	ListAuditLogs(filter AuditFilter) ([]models.RoomAuditLog, int64, error)
+
+	// Begin starts a transaction; the returned repository works inside it.
+	Begin() (RoomTx, error)
+}
+
+// RoomTx is a RoomRepository bound to a transaction.
+type RoomTx interface {
+	RoomRepository
+	Commit() error
+	Rollback() error
+}
+
+// ScheduleScope selects the opening hours and exceptions of one room, one
+// building, or (both empty) every room.
+type ScheduleScope struct {
+	RoomID   *uuid.UUID
+	Building string
+}
+
+func (s ScheduleScope) where(db *gorm.DB) *gorm.DB {
+	if s.RoomID != nil {
+		return db.Where("room_id = ?", *s.RoomID)
+	}
+	return db.Where("room_id IS NULL AND building = ?", s.Building)
+}
+
+// RoomFilter narrows and orders ListRooms. Zero values mean "no filter".
+type RoomFilter struct {
+	Query          string
+	Building       string
+	Floors         []int
+	AccessibleOnly bool
+	MinCapacity    int
+	SortBy         string
+	Descending     bool
+	// GroupID lists the rooms of a group and all its subgroups.
+	GroupID *uuid.UUID
+	// IncludeArchived also lists archived rooms, which are hidden by default.
+	IncludeArchived bool
+}
+
+// roomSortColumns maps the accepted sort keys to ORDER BY columns.
+var roomSortColumns = map[string][]string{
+	"name":     {"name"},
+	"capacity": {"capacity", "name"},
+	"building": {"building", "floor", "room_number", "name"},
+	"floor":    {"floor", "building", "room_number", "name"},
 }
 
 type roomRepository struct {
@@ -19,20 +130,68 @@ type roomRepository struct {
 }
 
 func NewRoomRepository(db *gorm.DB) *roomRepository {
-	db.AutoMigrate(&models.Room{})
 	return &roomRepository{db: db}
 }
 
 func (r *roomRepository) Create(room *models.Room) error {
-	return r.db.Create(room).Error
+	return translateRoomError(r.db.Create(room).Error)
 }
 
-func (r *roomRepository) Update(room *models.Room) error {
-	return r.db.Save(room).Error
+// translateRoomError maps the unique index on rooms.name to
+// ErrDuplicateRoomName.
+func translateRoomError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicateRoomName
+	}
+	return err
 }
 
-func (r *roomRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.Room{}, id).Error
+// Update saves room's attributes if it is still at version, and bumps the
+// version. archived_at only changes through Archive and Restore.
+func (r *roomRepository) Update(room *models.Room, version int) error {
+	room.Version = version + 1
+	result := r.db.Model(room).
+		Where("version = ?", version).
+		Select("name", "capacity", "features", "building", "floor", "room_number",
+			"latitude", "longitude", "accessible", "time_zone", "description", "version").
+		Updates(room)
+	if result.Error != nil {
+		room.Version = version
+		return translateRoomError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		room.Version = version
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// Archive marks an active room archived at the given time.
+func (r *roomRepository) Archive(id uuid.UUID, at time.Time) error {
+	result := r.db.Model(&models.Room{}).
+		Where("id = ? AND archived_at IS NULL", id).
+		Updates(map[string]any{"archived_at": at, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) Restore(id uuid.UUID) error {
+	result := r.db.Model(&models.Room{}).
+		Where("id = ? AND archived_at IS NOT NULL", id).
+		Updates(map[string]any{"archived_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *roomRepository) GetByID(id uuid.UUID) (*models.Room, error) {
@@ -41,8 +200,628 @@ func (r *roomRepository) GetByID(id uuid.UUID) (*models.Room, error) {
 	return &room, err
 }
 
-func (r *roomRepository) List() ([]models.Room, error) {
+// GetByIDs returns the rooms with the given IDs, archived ones included.
+func (r *roomRepository) GetByIDs(ids []uuid.UUID) ([]models.Room, error) {
+	var rooms []models.Room
+	if len(ids) == 0 {
+		return rooms, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&rooms).Error
+	return rooms, err
+}
+
+// GetByNames returns the rooms with the given names, archived ones included.
+func (r *roomRepository) GetByNames(names []string) ([]models.Room, error) {
 	var rooms []models.Room
-	err := r.db.Find(&rooms).Error
+	if len(names) == 0 {
+		return rooms, nil
+	}
+	err := r.db.Where("name IN ?", names).Find(&rooms).Error
 	return rooms, err
 }
+
+// ImportRooms creates and updates rooms in one transaction. Each update must
+// still be at the version it was read at, so a concurrent edit fails the
+// whole import with ErrVersionConflict.
+func (r *roomRepository) ImportRooms(creates, updates []models.Room) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range creates {
+			if err := tx.Create(&creates[i]).Error; err != nil {
+				return translateRoomError(err)
+			}
+		}
+		for i := range updates {
+			if err := (&roomRepository{db: tx}).Update(&updates[i], updates[i].Version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *roomRepository) List(filter RoomFilter) ([]models.Room, error) {
+	query := r.db.Model(&models.Room{})
+
+	if !filter.IncludeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("name ILIKE ? OR description ILIKE ? OR room_number ILIKE ?", like, like, like)
+	}
+	if filter.Building != "" {
+		query = query.Where("building = ?", filter.Building)
+	}
+	if len(filter.Floors) > 0 {
+		query = query.Where("floor IN ?", filter.Floors)
+	}
+	if filter.AccessibleOnly {
+		query = query.Where("accessible = ?", true)
+	}
+	if filter.MinCapacity > 0 {
+		query = query.Where("capacity >= ?", filter.MinCapacity)
+	}
+	if filter.GroupID != nil {
+		query = query.Where("group_id IN (?)", r.groupSubtree(*filter.GroupID))
+	}
+
+	columns, ok := roomSortColumns[filter.SortBy]
+	if !ok {
+		columns = roomSortColumns["name"]
+	}
+	for i, column := range columns {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: filter.Descending && i == 0})
+	}
+
+	var rooms []models.Room
+	err := query.Find(&rooms).Error
+	return rooms, err
+}
+
+func (r *roomRepository) ListOpeningHours(scope ScheduleScope) ([]models.OpeningHours, error) {
+	var hours []models.OpeningHours
+	err := scope.where(r.db).Order("weekday ASC, opens_minute ASC").Find(&hours).Error
+	return hours, err
+}
+
+func (r *roomRepository) ReplaceOpeningHours(scope ScheduleScope, hours []models.OpeningHours) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := scope.where(tx).Delete(&models.OpeningHours{}).Error; err != nil {
+			return err
+		}
+		if len(hours) == 0 {
+			return nil
+		}
+		return tx.Create(&hours).Error
+	})
+}
+
+// ScheduleRules returns every opening hours row and the exceptions between
+// from and to that may apply to room: its own, its building's and global ones.
+func (r *roomRepository) ScheduleRules(room *models.Room, from, to time.Time) ([]models.OpeningHours, []models.ScheduleException, error) {
+	applies := func(db *gorm.DB) *gorm.DB {
+		return db.Where("room_id = ? OR (room_id IS NULL AND building IN ?)", room.ID, []string{room.Building, ""})
+	}
+
+	var hours []models.OpeningHours
+	if err := applies(r.db).Find(&hours).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var exceptions []models.ScheduleException
+	if err := applies(r.db).
+		Where("date BETWEEN ? AND ?", from.Format(schedule.DateLayout), to.Format(schedule.DateLayout)).
+		Find(&exceptions).Error; err != nil {
+		return nil, nil, err
+	}
+	return hours, exceptions, nil
+}
+
+func (r *roomRepository) CreateException(exception *models.ScheduleException) error {
+	return r.db.Create(exception).Error
+}
+
+func (r *roomRepository) ListExceptions(scope ScheduleScope, from, to time.Time) ([]models.ScheduleException, error) {
+	var exceptions []models.ScheduleException
+	err := scope.where(r.db).
+		Where("date BETWEEN ? AND ?", from.Format(schedule.DateLayout), to.Format(schedule.DateLayout)).
+		Order("date ASC").
+		Find(&exceptions).Error
+	return exceptions, err
+}
+
+func (r *roomRepository) DeleteException(id uuid.UUID) error {
+	result := r.db.Where("calendar_id IS NULL").Delete(&models.ScheduleException{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ImportHolidayCalendar stores calendar and its exceptions, replacing an
+// earlier import with the same name and building.
+func (r *roomRepository) ImportHolidayCalendar(calendar *models.HolidayCalendar, exceptions []models.ScheduleException) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ? AND building = ?", calendar.Name, calendar.Building).
+			Delete(&models.HolidayCalendar{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(calendar).Error; err != nil {
+			return err
+		}
+		for i := range exceptions {
+			exceptions[i].CalendarID = &calendar.ID
+		}
+		if len(exceptions) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&exceptions, 200).Error
+	})
+}
+
+func (r *roomRepository) ListHolidayCalendars() ([]models.HolidayCalendar, error) {
+	var calendars []models.HolidayCalendar
+	err := r.db.Order("name ASC").Find(&calendars).Error
+	return calendars, err
+}
+
+func (r *roomRepository) DeleteHolidayCalendar(id uuid.UUID) error {
+	result := r.db.Delete(&models.HolidayCalendar{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) CreateMaintenance(window *models.MaintenanceWindow) error {
+	return r.db.Create(window).Error
+}
+
+// ListMaintenance returns the room's windows overlapping [from, to). A zero to
+// means no upper bound.
+func (r *roomRepository) ListMaintenance(roomID uuid.UUID, from, to time.Time) ([]models.MaintenanceWindow, error) {
+	query := r.db.Where("room_id = ?", roomID).Where("ends_at > ?", from)
+	if !to.IsZero() {
+		query = query.Where("starts_at < ?", to)
+	}
+
+	var windows []models.MaintenanceWindow
+	err := query.Order("starts_at ASC").Find(&windows).Error
+	return windows, err
+}
+
+func (r *roomRepository) DeleteMaintenance(id uuid.UUID) error {
+	result := r.db.Delete(&models.MaintenanceWindow{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) ListEquipment(roomID uuid.UUID) ([]models.Equipment, error) {
+	var items []models.Equipment
+	err := r.db.Where("room_id = ?", roomID).Order("name ASC, condition ASC").Find(&items).Error
+	return items, err
+}
+
+func (r *roomRepository) GetEquipment(id uuid.UUID) (*models.Equipment, error) {
+	var item models.Equipment
+	if err := r.db.First(&item, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *roomRepository) CreateEquipment(item *models.Equipment) error {
+	return r.db.Create(item).Error
+}
+
+func (r *roomRepository) UpdateEquipment(item *models.Equipment) error {
+	result := r.db.Model(item).
+		Select("name", "quantity", "condition", "movable", "notes", "updated_at").
+		Updates(item)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) DeleteEquipment(id uuid.UUID) error {
+	result := r.db.Delete(&models.Equipment{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) ListLayouts(roomID uuid.UUID) ([]models.Layout, error) {
+	var layouts []models.Layout
+	err := r.db.Where("room_id = ?", roomID).Order("capacity DESC, name ASC").Find(&layouts).Error
+	return layouts, err
+}
+
+func (r *roomRepository) GetLayout(id uuid.UUID) (*models.Layout, error) {
+	var layout models.Layout
+	if err := r.db.First(&layout, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+func (r *roomRepository) CreateLayout(layout *models.Layout) error {
+	return translateLayoutError(r.db.Create(layout).Error)
+}
+
+func (r *roomRepository) UpdateLayout(layout *models.Layout) error {
+	result := r.db.Model(layout).
+		Select("name", "capacity", "setup_minutes", "teardown_minutes", "updated_at").
+		Updates(layout)
+	if result.Error != nil {
+		return translateLayoutError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) DeleteLayout(id uuid.UUID) error {
+	result := r.db.Delete(&models.Layout{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// translateLayoutError maps the unique index on a room's layout names to
+// ErrDuplicateLayout.
+func translateLayoutError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicateLayout
+	}
+	return err
+}
+
+func (r *roomRepository) ListGroups() ([]models.RoomGroup, error) {
+	var groups []models.RoomGroup
+	err := r.db.Order("name ASC").Find(&groups).Error
+	return groups, err
+}
+
+func (r *roomRepository) GetGroup(id uuid.UUID) (*models.RoomGroup, error) {
+	var group models.RoomGroup
+	if err := r.db.First(&group, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// groupSubtree selects the IDs of a group and all its descendants.
+func (r *roomRepository) groupSubtree(id uuid.UUID) *gorm.DB {
+	return r.db.Raw(`WITH RECURSIVE subtree AS (
+		SELECT id FROM room_groups WHERE id = ?
+		UNION
+		SELECT room_groups.id FROM room_groups JOIN subtree ON room_groups.parent_id = subtree.id
+	) SELECT id FROM subtree`, id)
+}
+
+func (r *roomRepository) GroupSubtree(id uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.groupSubtree(id).Scan(&ids).Error
+	return ids, err
+}
+
+func (r *roomRepository) CreateGroup(group *models.RoomGroup) error {
+	return r.db.Create(group).Error
+}
+
+func (r *roomRepository) UpdateGroup(group *models.RoomGroup) error {
+	result := r.db.Model(group).
+		Select("name", "parent_id", "description", "updated_at").
+		Updates(group)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteGroup removes a group with no rooms and no subgroups, failing with
+// ErrGroupNotEmpty otherwise.
+func (r *roomRepository) DeleteGroup(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var group models.RoomGroup
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		var rooms, children int64
+		if err := tx.Model(&models.Room{}).Where("group_id = ?", id).Count(&rooms).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RoomGroup{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if rooms > 0 || children > 0 {
+			return ErrGroupNotEmpty
+		}
+
+		return tx.Delete(&group).Error
+	})
+}
+
+// SetRoomGroup changes the group of a room and bumps its version.
+func (r *roomRepository) SetRoomGroup(roomID uuid.UUID, groupID *uuid.UUID) error {
+	result := r.db.Model(&models.Room{}).
+		Where("id = ?", roomID).
+		Updates(map[string]any{"group_id": groupID, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) RoomParts(id uuid.UUID) ([]models.Room, []models.Room, error) {
+	var parts, partOf []models.Room
+	if err := r.db.
+		Where("id IN (?)", r.db.Model(&models.RoomCombination{}).Select("part_room_id").Where("combined_room_id = ?", id)).
+		Order("name ASC").
+		Find(&parts).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := r.db.
+		Where("id IN (?)", r.db.Model(&models.RoomCombination{}).Select("combined_room_id").Where("part_room_id = ?", id)).
+		Order("name ASC").
+		Find(&partOf).Error; err != nil {
+		return nil, nil, err
+	}
+	return parts, partOf, nil
+}
+
+// ReplaceRoomParts sets the parts of a combined room, keeping combinations
+// one level deep: a part cannot have parts and a combined room cannot be a
+// part.
+func (r *roomRepository) ReplaceRoomParts(id uuid.UUID, partIDs []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(partIDs) > 0 {
+			var existing, combined, asPart int64
+			if err := tx.Model(&models.Room{}).Where("id IN ?", partIDs).Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing != int64(len(partIDs)) {
+				return gorm.ErrRecordNotFound
+			}
+			if err := tx.Model(&models.RoomCombination{}).Where("combined_room_id IN ?", partIDs).Count(&combined).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.RoomCombination{}).Where("part_room_id = ?", id).Count(&asPart).Error; err != nil {
+				return err
+			}
+			if combined > 0 || asPart > 0 {
+				return ErrInvalidCombination
+			}
+		}
+
+		if err := tx.Where("combined_room_id = ?", id).Delete(&models.RoomCombination{}).Error; err != nil {
+			return err
+		}
+		if len(partIDs) == 0 {
+			return nil
+		}
+		combinations := make([]models.RoomCombination, len(partIDs))
+		for i, partID := range partIDs {
+			combinations[i] = models.RoomCombination{CombinedRoomID: id, PartRoomID: partID}
+		}
+		return tx.Create(&combinations).Error
+	})
+}
+
+func (r *roomRepository) CreateSensor(sensor *models.RoomSensor) error {
+	return r.db.Create(sensor).Error
+}
+
+func (r *roomRepository) ListSensors(roomID uuid.UUID) ([]models.RoomSensor, error) {
+	var sensors []models.RoomSensor
+	err := r.db.Where("room_id = ?", roomID).Order("created_at ASC").Find(&sensors).Error
+	return sensors, err
+}
+
+func (r *roomRepository) RevokeSensor(id uuid.UUID, at time.Time) (*models.RoomSensor, error) {
+	var sensor models.RoomSensor
+	if err := r.db.First(&sensor, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if sensor.RevokedAt == nil {
+		if err := r.db.Model(&sensor).Update("revoked_at", at).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &sensor, nil
+}
+
+// GetSensorByKeyHash returns the unrevoked sensor with the key hash.
+func (r *roomRepository) GetSensorByKeyHash(hash string) (*models.RoomSensor, error) {
+	var sensor models.RoomSensor
+	if err := r.db.Where("key_hash = ? AND revoked_at IS NULL", hash).First(&sensor).Error; err != nil {
+		return nil, err
+	}
+	return &sensor, nil
+}
+
+func (r *roomRepository) CreateKiosk(kiosk *models.RoomKiosk) error {
+	return r.db.Create(kiosk).Error
+}
+
+func (r *roomRepository) ListKiosks(roomID uuid.UUID) ([]models.RoomKiosk, error) {
+	var kiosks []models.RoomKiosk
+	err := r.db.Where("room_id = ?", roomID).Order("created_at ASC").Find(&kiosks).Error
+	return kiosks, err
+}
+
+func (r *roomRepository) RevokeKiosk(id uuid.UUID, at time.Time) (*models.RoomKiosk, error) {
+	var kiosk models.RoomKiosk
+	if err := r.db.First(&kiosk, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if kiosk.RevokedAt == nil {
+		if err := r.db.Model(&kiosk).Update("revoked_at", at).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &kiosk, nil
+}
+
+// EnsureQRSecret stores secret unless the room already has one, and returns
+// the room's secret.
+func (r *roomRepository) EnsureQRSecret(secret *models.RoomQRSecret) (*models.RoomQRSecret, error) {
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(secret).Error; err != nil {
+		return nil, err
+	}
+	var stored models.RoomQRSecret
+	if err := r.db.First(&stored, "room_id = ?", secret.RoomID).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// SaveQRSecret replaces the room's secret.
+func (r *roomRepository) SaveQRSecret(secret *models.RoomQRSecret) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "rotated_at"}),
+	}).Create(secret).Error
+}
+
+// GetQRSecret returns the room's secret, without creating one.
+func (r *roomRepository) GetQRSecret(roomID uuid.UUID) (*models.RoomQRSecret, error) {
+	var secret models.RoomQRSecret
+	if err := r.db.First(&secret, "room_id = ?", roomID).Error; err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// RecordOccupancy saves readings of one room and marks the sensor seen. It
+// returns the room's latest reading before them, if any. The room row is
+// locked so readings of concurrent sensors are compared in order.
+func (r *roomRepository) RecordOccupancy(sensorID uuid.UUID, readings []models.OccupancyReading, at time.Time) (*models.OccupancyReading, error) {
+	var previous *models.OccupancyReading
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		roomID := readings[0].RoomID
+		var room models.Room
+		if err := tx.Clauses(clause.Locking{Strength: "NO KEY UPDATE"}).Select("id").First(&room, "id = ?", roomID).Error; err != nil {
+			return err
+		}
+
+		var latest []models.OccupancyReading
+		if err := tx.Where("room_id = ?", roomID).Order("recorded_at DESC, id DESC").Limit(1).Find(&latest).Error; err != nil {
+			return err
+		}
+		if len(latest) > 0 {
+			previous = &latest[0]
+		}
+
+		if err := tx.Create(&readings).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RoomSensor{}).Where("id = ?", sensorID).Update("last_seen_at", at).Error
+	})
+	return previous, err
+}
+
+// LatestOccupancy returns the newest reading of each of the rooms, or of every
+// room when roomIDs is empty.
+func (r *roomRepository) LatestOccupancy(roomIDs []uuid.UUID) ([]models.OccupancyReading, error) {
+	query := r.db.Select("DISTINCT ON (room_id) *").Order("room_id, recorded_at DESC, id DESC")
+	if len(roomIDs) > 0 {
+		query = query.Where("room_id IN ?", roomIDs)
+	}
+
+	var readings []models.OccupancyReading
+	err := query.Find(&readings).Error
+	return readings, err
+}
+
+// OccupancyHistory returns the room's readings recorded in [from, to), oldest
+// first.
+func (r *roomRepository) OccupancyHistory(roomID uuid.UUID, from, to time.Time) ([]models.OccupancyReading, error) {
+	var readings []models.OccupancyReading
+	err := r.db.Where("room_id = ?", roomID).
+		Where("recorded_at >= ? AND recorded_at < ?", from, to).
+		Order("recorded_at ASC, id ASC").
+		Find(&readings).Error
+	return readings, err
+}
+
+func (r *roomRepository) Begin() (RoomTx, error) {
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &roomTx{roomRepository{db: tx}}, nil
+}
+
+type roomTx struct {
+	roomRepository
+}
+
+func (t *roomTx) Commit() error {
+	return t.db.Commit().Error
+}
+
+func (t *roomTx) Rollback() error {
+	return t.db.Rollback().Error
+}
+
+func (r *roomRepository) CreateAuditLog(entry *models.RoomAuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *roomRepository) ListAuditLogs(filter AuditFilter) ([]models.RoomAuditLog, int64, error) {
+	query := r.db.Model(&models.RoomAuditLog{})
+	if filter.RoomID != nil {
+		query = query.Where("room_id = ?", *filter.RoomID)
+	}
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.RoomAuditLog
+	err := query.Order("created_at DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&entries).Error
+	return entries, total, err
+}
@@ -1,42 +1,355 @@
 package internal
 
 import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/events"
+	"github.com/JJnvn/Software-Arch-CPRoom/backend/libs/schedule"
 	"github.com/JJnvn/Software-Arch-CPRoom/backend/services/room/models"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type RoomService interface {
 	Create(room *models.Room) error
-	Update(room *models.Room) error
-	Delete(id uuid.UUID) error
+	Patch(id uuid.UUID, patch []byte, ifMatch []int) (updated, previous *models.Room, err error)
+	Archive(id uuid.UUID) (*models.Room, error)
+	Restore(id uuid.UUID) (*models.Room, error)
 	GetByID(id uuid.UUID) (*models.Room, error)
-	List() ([]models.Room, error)
+	GetByIDs(ids []uuid.UUID) ([]models.Room, error)
+	ImportRooms(rows []RoomImportRow, dryRun bool) (*ImportReport, error)
+	List(filter RoomFilter) ([]models.Room, error)
+
+	OpeningHours(scope ScheduleScope) ([]models.OpeningHours, error)
+	SetOpeningHours(scope ScheduleScope, input []HoursInput) ([]models.OpeningHours, error)
+	AddException(scope ScheduleScope, input ExceptionInput) (*models.ScheduleException, error)
+	ListExceptions(scope ScheduleScope, from, to time.Time) ([]models.ScheduleException, error)
+	DeleteException(id uuid.UUID) error
+	ImportHolidayCalendar(name, building string, r io.Reader) (*models.HolidayCalendar, error)
+	ListHolidayCalendars() ([]models.HolidayCalendar, error)
+	DeleteHolidayCalendar(id uuid.UUID) error
+	Calendar(room *models.Room, from, to time.Time) (*schedule.Calendar, error)
+
+	PlanMaintenance(roomID uuid.UUID, input MaintenanceInput) (*models.MaintenanceWindow, error)
+	CreateMaintenance(window *models.MaintenanceWindow) error
+	ListMaintenance(roomID uuid.UUID, from, to time.Time) ([]models.MaintenanceWindow, error)
+	DeleteMaintenance(id uuid.UUID) error
+
+	ListEquipment(roomID uuid.UUID) ([]models.Equipment, error)
+	AddEquipment(roomID uuid.UUID, input EquipmentInput) (*models.Equipment, error)
+	UpdateEquipment(id uuid.UUID, input EquipmentInput) (*models.Equipment, error)
+	RemoveEquipment(id uuid.UUID) (*models.Equipment, error)
+
+	ListLayouts(roomID uuid.UUID) ([]models.Layout, error)
+	AddLayout(roomID uuid.UUID, input LayoutInput) (*models.Layout, error)
+	UpdateLayout(id uuid.UUID, input LayoutInput) (*models.Layout, error)
+	RemoveLayout(id uuid.UUID) (*models.Layout, error)
+
+	ListGroups() ([]models.RoomGroup, error)
+	CreateGroup(input GroupInput) (*models.RoomGroup, error)
+	UpdateGroup(id uuid.UUID, input GroupInput) (*models.RoomGroup, error)
+	DeleteGroup(id uuid.UUID) error
+	SetRoomGroup(roomID uuid.UUID, groupID *uuid.UUID) (*models.Room, error)
+	RoomParts(id uuid.UUID) (parts, partOf []models.Room, err error)
+	SetRoomParts(id uuid.UUID, partIDs []uuid.UUID) ([]models.Room, error)
+
+	RegisterSensor(roomID uuid.UUID, name string) (*models.RoomSensor, string, error)
+	ListSensors(roomID uuid.UUID) ([]models.RoomSensor, error)
+	RevokeSensor(id uuid.UUID) (*models.RoomSensor, error)
+	AuthenticateSensor(key string) (*models.RoomSensor, error)
+	RegisterKiosk(roomID uuid.UUID, name string) (*models.RoomKiosk, string, error)
+	ListKiosks(roomID uuid.UUID) ([]models.RoomKiosk, error)
+	RevokeKiosk(id uuid.UUID) (*models.RoomKiosk, error)
+	RoomQRCode(roomID uuid.UUID) (*RoomQRCode, error)
+	RotateQRSecret(roomID uuid.UUID) (*RoomQRCode, error)
+	VerifyRoomCode(roomID uuid.UUID, signature string) error
+	RecordOccupancy(sensor *models.RoomSensor, input []OccupancyInput) ([]models.OccupancyReading, error)
+	CurrentOccupancy(roomIDs []uuid.UUID) ([]RoomOccupancy, error)
+	OccupancyHistory(roomID uuid.UUID, from, to time.Time) ([]models.OccupancyReading, error)
+
+	BeginChange() (*RoomChange, error)
+	ListAuditLogs(filter AuditFilter) ([]models.RoomAuditLog, int64, error)
+}
+
+var (
+	ErrInvalidTimeZone   = errors.New("time_zone must be an IANA time zone such as Asia/Bangkok")
+	ErrInvalidLocation   = errors.New("latitude must be within [-90, 90] and longitude within [-180, 180], and both must be set together")
+	ErrInvalidRoomSortBy = errors.New("sort must be one of name, capacity, building, floor")
+	ErrInvalidWindow     = errors.New("end_time must be after start_time and in the future")
+	ErrRoomArchived      = errors.New("room is already archived")
+	ErrRoomNotArchived   = errors.New("room is not archived")
+	ErrInvalidRoomName   = errors.New("name is required")
+	ErrInvalidCapacity   = errors.New("capacity must be at least 1")
+	ErrDuplicateRoomName = errors.New("room name already exists")
+	// ErrVersionConflict means the room changed since the version the caller
+	// read, or between reading and saving it.
+	ErrVersionConflict = errors.New("room has been modified; fetch it again and retry")
+)
+
+// MaintenanceInput describes a maintenance window to block a room for.
+type MaintenanceInput struct {
+	Start  time.Time
+	End    time.Time
+	Reason string
 }
 
 type roomService struct {
-	repo RoomRepository
+	repo   RoomRepository
+	events EventPublisher
 }
 
-func NewRoomService(repo *roomRepository) *roomService {
-	return &roomService{repo: repo}
+// NewRoomService wires the service. publisher may be nil, in which case no
+// room events are published.
+func NewRoomService(repo *roomRepository, publisher EventPublisher) *roomService {
+	return &roomService{repo: repo, events: publisher}
 }
 
 func (s *roomService) Create(room *models.Room) error {
-	return s.repo.Create(room)
+	if err := normalizeRoom(room); err != nil {
+		return err
+	}
+	if err := s.repo.Create(room); err != nil {
+		return err
+	}
+	s.publishRoomEvent(events.RoomCreatedEvent, room)
+	return nil
 }
 
-func (s *roomService) Update(room *models.Room) error {
-	return s.repo.Update(room)
+// Patch applies a JSON merge patch to a room. When ifMatch is not empty the
+// room must currently be at one of those versions. The previous state is
+// returned so callers can react to what changed.
+func (s *roomService) Patch(id uuid.UUID, patch []byte, ifMatch []int) (*models.Room, *models.Room, error) {
+	current, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ifMatch) > 0 && !containsVersion(ifMatch, current.Version) {
+		return nil, nil, ErrVersionConflict
+	}
+
+	previous := *current
+	room := *current
+	if err := patchRoom(&room, patch); err != nil {
+		return nil, nil, err
+	}
+	if err := normalizeRoom(&room); err != nil {
+		return nil, nil, err
+	}
+	if err := s.repo.Update(&room, current.Version); err != nil {
+		return nil, nil, err
+	}
+	s.publishRoomEvent(events.RoomUpdatedEvent, &room)
+	return &room, &previous, nil
+}
+
+func containsVersion(versions []int, version int) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Archive hides a room from listings and search and stops new bookings. The
+// row is kept so existing bookings still resolve its name.
+func (s *roomService) Archive(id uuid.UUID) (*models.Room, error) {
+	room, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if room.ArchivedAt != nil {
+		return nil, ErrRoomArchived
+	}
+	if err := s.repo.Archive(id, time.Now().UTC()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoomArchived
+		}
+		return nil, err
+	}
+
+	archived, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.publishRoomEvent(events.RoomArchivedEvent, archived)
+	return archived, nil
 }
 
-func (s *roomService) Delete(id uuid.UUID) error {
-	return s.repo.Delete(id)
+func (s *roomService) Restore(id uuid.UUID) (*models.Room, error) {
+	room, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if room.ArchivedAt == nil {
+		return nil, ErrRoomNotArchived
+	}
+	if err := s.repo.Restore(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoomNotArchived
+		}
+		return nil, err
+	}
+
+	restored, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.publishRoomEvent(events.RoomRestoredEvent, restored)
+	return restored, nil
 }
 
 func (s *roomService) GetByID(id uuid.UUID) (*models.Room, error) {
 	return s.repo.GetByID(id)
 }
 
-func (s *roomService) List() ([]models.Room, error) {
-	return s.repo.List()
+func (s *roomService) GetByIDs(ids []uuid.UUID) ([]models.Room, error) {
+	return s.repo.GetByIDs(ids)
+}
+
+func (s *roomService) List(filter RoomFilter) ([]models.Room, error) {
+	if _, ok := roomSortColumns[filter.SortBy]; filter.SortBy != "" && !ok {
+		return nil, ErrInvalidRoomSortBy
+	}
+	return s.repo.List(filter)
+}
+
+// normalizeRoom trims the descriptive fields and validates name, capacity,
+// time zone and coordinates.
+func normalizeRoom(room *models.Room) error {
+	room.Name = strings.TrimSpace(room.Name)
+	if room.Name == "" {
+		return ErrInvalidRoomName
+	}
+	if room.Capacity < 1 {
+		return ErrInvalidCapacity
+	}
+	room.Building = strings.TrimSpace(room.Building)
+	room.RoomNumber = strings.TrimSpace(room.RoomNumber)
+	room.Description = strings.TrimSpace(room.Description)
+
+	room.TimeZone = strings.TrimSpace(room.TimeZone)
+	if room.TimeZone == "" {
+		room.TimeZone = models.DefaultTimeZone
+	}
+	if _, err := time.LoadLocation(room.TimeZone); err != nil {
+		return ErrInvalidTimeZone
+	}
+
+	if (room.Latitude == nil) != (room.Longitude == nil) {
+		return ErrInvalidLocation
+	}
+	if room.Latitude != nil && (*room.Latitude < -90 || *room.Latitude > 90 || *room.Longitude < -180 || *room.Longitude > 180) {
+		return ErrInvalidLocation
+	}
+	return nil
+}
+
+// PlanMaintenance validates a maintenance window for the room without saving
+// it, so the affected bookings can be reviewed first.
+func (s *roomService) PlanMaintenance(roomID uuid.UUID, input MaintenanceInput) (*models.MaintenanceWindow, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	if !input.End.After(input.Start) || !input.End.After(time.Now()) {
+		return nil, ErrInvalidWindow
+	}
+
+	return &models.MaintenanceWindow{
+		RoomID:   roomID,
+		StartsAt: input.Start,
+		EndsAt:   input.End,
+		Reason:   strings.TrimSpace(input.Reason),
+	}, nil
+}
+
+func (s *roomService) CreateMaintenance(window *models.MaintenanceWindow) error {
+	return s.repo.CreateMaintenance(window)
+}
+
+func (s *roomService) ListMaintenance(roomID uuid.UUID, from, to time.Time) ([]models.MaintenanceWindow, error) {
+	if _, err := s.repo.GetByID(roomID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMaintenance(roomID, from, to)
+}
+
+func (s *roomService) DeleteMaintenance(id uuid.UUID) error {
+	return s.repo.DeleteMaintenance(id)
+}
+
+// RoomChange is a change to rooms in progress. The change and its audit
+// entry are written in one transaction, and room events are held back until
+// it commits, so nothing is saved or announced without being audited.
+type RoomChange struct {
+	tx      RoomTx
+	service *roomService
+	pending *pendingEvents
+	done    bool
+}
+
+func (s *roomService) BeginChange() (*RoomChange, error) {
+	tx, err := s.repo.Begin()
+	if err != nil {
+		return nil, err
+	}
+	change := &RoomChange{tx: tx, service: &roomService{repo: tx}}
+	if s.events != nil {
+		change.pending = &pendingEvents{events: s.events}
+		change.service.events = change.pending
+	}
+	return change, nil
+}
+
+// Service makes the change; it must not be used after Commit or Rollback.
+func (c *RoomChange) Service() RoomService {
+	return c.service
+}
+
+// Done reports whether the change was committed or rolled back.
+func (c *RoomChange) Done() bool {
+	return c.done
+}
+
+// Commit saves entry next to the change and commits both.
+func (c *RoomChange) Commit(entry *models.RoomAuditLog) error {
+	if c.done {
+		return errors.New("room change already finished")
+	}
+	c.done = true
+	if err := c.tx.CreateAuditLog(entry); err != nil {
+		c.tx.Rollback()
+		return err
+	}
+	if err := c.tx.Commit(); err != nil {
+		return err
+	}
+	c.pending.flush()
+	return nil
+}
+
+// Rollback discards the change unless it was already finished, so it can be
+// deferred right after BeginChange.
+func (c *RoomChange) Rollback() {
+	if c.done {
+		return
+	}
+	c.done = true
+	if err := c.tx.Rollback(); err != nil {
+		log.Printf("room-service: failed to roll back room change: %v", err)
+	}
+}
+
+func (s *roomService) ListAuditLogs(filter AuditFilter) ([]models.RoomAuditLog, int64, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 200 {
+		filter.PageSize = 50
+	}
+	return s.repo.ListAuditLogs(filter)
 }
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Room audit actions.
+const (
+	AuditRoomCreated        = "room.created"
+	AuditRoomUpdated        = "room.updated"
+	AuditRoomArchived       = "room.archived"
+	AuditRoomRestored       = "room.restored"
+	AuditRoomsImported      = "rooms.imported"
+	AuditOpeningHoursSet    = "opening_hours.set"
+	AuditExceptionAdded     = "exception.added"
+	AuditExceptionDeleted   = "exception.deleted"
+	AuditCalendarImported   = "holiday_calendar.imported"
+	AuditCalendarDeleted    = "holiday_calendar.deleted"
+	AuditMaintenanceCreated = "maintenance.created"
+	AuditMaintenanceDeleted = "maintenance.deleted"
+	AuditEquipmentAdded     = "equipment.added"
+	AuditEquipmentUpdated   = "equipment.updated"
+	AuditEquipmentRemoved   = "equipment.removed"
+	AuditLayoutAdded        = "layout.added"
+	AuditLayoutUpdated      = "layout.updated"
+	AuditLayoutRemoved      = "layout.removed"
+	AuditGroupCreated       = "group.created"
+	AuditGroupUpdated       = "group.updated"
+	AuditGroupDeleted       = "group.deleted"
+	AuditRoomGroupChanged   = "room.group_changed"
+	AuditRoomPartsSet       = "room.parts_set"
+	AuditSensorRegistered   = "sensor.registered"
+	AuditSensorRevoked      = "sensor.revoked"
+	AuditKioskRegistered    = "kiosk.registered"
+	AuditKioskRevoked       = "kiosk.revoked"
+	AuditQRSecretRotated    = "room.qr_rotated"
+)
+
+// RawJSON stores a JSON document as is in a jsonb column.
+type RawJSON []byte
+
+func (j RawJSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j *RawJSON) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(RawJSON(nil), v...)
+	case string:
+		*j = RawJSON(v)
+	default:
+		return fmt.Errorf("models.RawJSON: unsupported scan type %T", value)
+	}
+	return nil
+}
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// RoomAuditLog records one change made through the room API. RoomID is nil
+// for building-wide and global schedule changes; Path identifies those.
+type RoomAuditLog struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID         *uuid.UUID `gorm:"type:uuid" json:"room_id,omitempty"`
+	Action         string     `json:"action"`
+	ActorID        string     `json:"actor_id"`
+	ImpersonatorID *string    `json:"impersonator_id,omitempty"`
+	Method         string     `json:"method"`
+	Path           string     `json:"path"`
+	Details        RawJSON    `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Equipment conditions. Broken units cannot be reserved.
+const (
+	EquipmentWorking  = "working"
+	EquipmentDegraded = "degraded"
+	EquipmentBroken   = "broken"
+)
+
+// Equipment is a stock of identical items kept in a room, such as four
+// projectors. Units in different conditions are separate rows, so "two of the
+// four projectors are broken" is a working row and a broken row of two each.
+// Movable items can be reserved as add-ons to bookings of any room.
+type Equipment struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID    uuid.UUID `gorm:"type:uuid;not null;index" json:"room_id"`
+	Name      string    `gorm:"size:100;not null" json:"name"`
+	Quantity  int       `gorm:"not null;default:1" json:"quantity"`
+	Condition string    `gorm:"size:20;not null;default:'working'" json:"condition"`
+	Movable   bool      `gorm:"not null;default:false" json:"movable"`
+	Notes     string    `gorm:"type:text;not null;default:''" json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Equipment) TableName() string { return "room_equipment" }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoomGroup is a node in the tree rooms are organised in, such as a building
+// or one of its wings. Rooms in a group's subgroups also belong to it.
+type RoomGroup struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ParentID    *uuid.UUID `gorm:"type:uuid" json:"parent_id,omitempty"`
+	Name        string     `gorm:"size:100;not null" json:"name"`
+	Description string     `gorm:"type:text;not null;default:''" json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// RoomCombination makes PartRoomID one of the rooms CombinedRoomID occupies.
+type RoomCombination struct {
+	CombinedRoomID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	PartRoomID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoomKiosk is a display tablet mounted outside a room. Its key only grants
+// the kiosk API of that room and is only shown when the kiosk is registered.
+type RoomKiosk struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID     uuid.UUID  `gorm:"type:uuid;not null" json:"room_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	KeyPrefix  string     `gorm:"size:16;not null" json:"key_prefix"`
+	KeyHash    string     `gorm:"size:64;not null" json:"-"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
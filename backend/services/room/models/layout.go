@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Layout is a named seating arrangement of a room, such as theater or
+// classroom, with its own capacity. Bookings in the layout also block the room
+// for SetupMinutes before and TeardownMinutes after the booked window.
+type Layout struct {
+	ID              uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID          uuid.UUID `gorm:"type:uuid;not null" json:"room_id"`
+	Name            string    `gorm:"size:50;not null" json:"name"`
+	Capacity        int       `gorm:"not null" json:"capacity"`
+	SetupMinutes    int       `gorm:"not null;default:0" json:"setup_minutes"`
+	TeardownMinutes int       `gorm:"not null;default:0" json:"teardown_minutes"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (Layout) TableName() string { return "room_layouts" }
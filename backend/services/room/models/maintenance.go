@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceWindow blocks a room for repairs. Bookings cannot overlap it.
+type MaintenanceWindow struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID    uuid.UUID `gorm:"type:uuid;not null;index" json:"room_id"`
+	StartsAt  time.Time `gorm:"not null" json:"start_time"`
+	EndsAt    time.Time `gorm:"not null" json:"end_time"`
+	Reason    string    `gorm:"size:200;not null;default:''" json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (MaintenanceWindow) TableName() string { return "room_maintenance_windows" }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoomSensor is an occupancy sensor installed in a room. It sends readings
+// with an API key that is only shown when the sensor is registered.
+type RoomSensor struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID     uuid.UUID  `gorm:"type:uuid;not null" json:"room_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	KeyPrefix  string     `gorm:"size:16;not null" json:"key_prefix"`
+	KeyHash    string     `gorm:"size:64;not null" json:"-"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// OccupancyReading is one report from a sensor. PeopleCount is nil for
+// sensors that only detect presence.
+type OccupancyReading struct {
+	ID          int64     `gorm:"primaryKey" json:"-"`
+	RoomID      uuid.UUID `gorm:"type:uuid;not null" json:"room_id"`
+	SensorID    uuid.UUID `gorm:"type:uuid;not null" json:"sensor_id"`
+	RecordedAt  time.Time `gorm:"not null" json:"recorded_at"`
+	ReceivedAt  time.Time `gorm:"not null;default:now()" json:"received_at"`
+	Occupied    bool      `gorm:"not null" json:"occupied"`
+	PeopleCount *int      `json:"people_count,omitempty"`
+}
+
+func (OccupancyReading) TableName() string { return "room_occupancy_readings" }
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoomQRSecret is the key a room's QR code link is signed with. It is created
+// when the code is first requested and replaced when rotated.
+type RoomQRSecret struct {
+	RoomID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"room_id"`
+	Secret    []byte    `gorm:"not null" json:"-"`
+	RotatedAt time.Time `gorm:"not null" json:"rotated_at"`
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -44,9 +45,25 @@ func (s *StringList) Scan(value any) error {
 	return json.Unmarshal(data, s)
 }
 
+// DefaultTimeZone is used for rooms created without an explicit time zone.
+const DefaultTimeZone = "Asia/Bangkok"
+
+// Room columns are managed by the versioned migrations in config/migrations,
+// not AutoMigrate; keep the gorm tags in step with them.
 type Room struct {
-    ID       uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-    Name     string     `gorm:"uniqueIndex;not null" json:"name"`
-    Capacity int        `json:"capacity"`
-    Features StringList `gorm:"type:jsonb" json:"features"`
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Name        string     `gorm:"uniqueIndex;not null" json:"name"`
+	Capacity    int        `json:"capacity"`
+	Features    StringList `gorm:"type:jsonb" json:"features"`
+	Building    string     `gorm:"size:100;not null;default:''" json:"building"`
+	Floor       int        `gorm:"not null;default:0" json:"floor"`
+	RoomNumber  string     `gorm:"size:20;not null;default:''" json:"room_number"`
+	Latitude    *float64   `json:"latitude,omitempty"`
+	Longitude   *float64   `json:"longitude,omitempty"`
+	Accessible  bool       `gorm:"not null;default:false" json:"accessible"`
+	TimeZone    string     `gorm:"size:64;not null;default:'Asia/Bangkok'" json:"time_zone"`
+	Description string     `gorm:"type:text;not null;default:''" json:"description"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	Version     int        `gorm:"not null;default:1" json:"version"`
+	GroupID     *uuid.UUID `gorm:"type:uuid" json:"group_id,omitempty"`
 }
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OpeningHours opens a room, a building (RoomID nil) or every room (RoomID nil
+// and Building empty) on one weekday. Minutes are counted from local midnight
+// in the room's time zone.
+type OpeningHours struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID       *uuid.UUID `gorm:"type:uuid;index" json:"room_id,omitempty"`
+	Building     string     `gorm:"size:100;not null;default:''" json:"building,omitempty"`
+	Weekday      int        `gorm:"not null" json:"weekday"`
+	OpensMinute  int        `gorm:"not null" json:"-"`
+	ClosesMinute int        `gorm:"not null" json:"-"`
+}
+
+func (OpeningHours) TableName() string { return "room_opening_hours" }
+
+// ScheduleException closes a room, building or every room on one date, or
+// opens it with special hours when Closed is false.
+type ScheduleException struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	RoomID       *uuid.UUID `gorm:"type:uuid;index" json:"room_id,omitempty"`
+	Building     string     `gorm:"size:100;not null;default:''" json:"building,omitempty"`
+	Date         time.Time  `gorm:"type:date;not null;index" json:"-"`
+	Closed       bool       `gorm:"not null;default:true" json:"closed"`
+	OpensMinute  int        `gorm:"not null;default:0" json:"-"`
+	ClosesMinute int        `gorm:"not null;default:0" json:"-"`
+	Reason       string     `gorm:"size:200;not null;default:''" json:"reason"`
+	CalendarID   *uuid.UUID `gorm:"type:uuid" json:"calendar_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (ScheduleException) TableName() string { return "room_schedule_exceptions" }
+
+// HolidayCalendar is an imported iCalendar feed. Its events are stored as
+// ScheduleExceptions and removed with it.
+type HolidayCalendar struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Name       string    `gorm:"size:100;not null" json:"name"`
+	Building   string    `gorm:"size:100;not null;default:''" json:"building,omitempty"`
+	EventCount int       `gorm:"not null;default:0" json:"event_count"`
+	ImportedAt time.Time `gorm:"not null;default:now()" json:"imported_at"`
+}
+
+func (HolidayCalendar) TableName() string { return "room_holiday_calendars" }
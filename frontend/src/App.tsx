@@ -11,6 +11,7 @@ import PreferencesEdit from "./pages/User/PreferencesEdit";
 import RescheduleBooking from "./pages/Bookings/RescheduleBooking";
 import TransferBooking from "./pages/Bookings/TransferBooking";
 import RoomSchedule from "./pages/Rooms/RoomSchedule";
+import ScanRoom from "./pages/Rooms/ScanRoom";
 import SearchAndBook from "./pages/Rooms/SearchAndBook";
 import EditBooking from "./pages/Rooms/EditBooking";
 
@@ -21,6 +22,7 @@ import ApprovedBookings from "./pages/Staff/ApprovedBookings";
 import AuditTrail from "./pages/Staff/AuditTrail";
 import AdminRoomBookings from "./pages/Admin/RoomBookings";
 import NotificationHistory from "./pages/Notifications/NotificationHistory";
+import Inbox from "./pages/Notifications/Inbox";
 import { useAuth } from "./hooks/useAuth";
 
 function AppLayout() {
@@ -73,12 +75,14 @@ export default function App() {
                 {/* Rooms & Bookings */}
                 <Route path="/rooms/search" element={<SearchAndBook />} />
                 <Route path="/rooms/:id/schedule" element={<RoomSchedule />} />
+                <Route path="/rooms/:id/scan" element={<ScanRoom />} />
                 <Route path="/booking-history" element={<BookingHistory />} />
                 <Route path="/bookings/:id/edit" element={<EditBooking />} />
                 <Route path="/bookings/:id/transfer" element={<TransferBooking />} />
 
                 {/* Notifications */}
                 <Route path="/notifications" element={<NotificationHistory />} />
+                <Route path="/notifications/inbox" element={<Inbox />} />
 
                 {/* Staff & Admin */}
                 <Route path="/approvals/pending" element={<PendingApprovals />} />
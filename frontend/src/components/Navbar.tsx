@@ -1,9 +1,33 @@
+import { useEffect, useState } from 'react';
 import { Link } from 'react-router-dom';
 import { useAuth } from '@/hooks/useAuth';
+import { getUnreadCount } from '@/services/notifications';
+
+const UNREAD_POLL_MS = 60_000;
 
 export default function Navbar() {
   const { user, logout } = useAuth();
-  
+  const [unreadCount, setUnreadCount] = useState(0);
+
+  useEffect(() => {
+    if (!user) {
+      setUnreadCount(0);
+      return;
+    }
+
+    const refresh = () => getUnreadCount().then(setUnreadCount).catch(() => {});
+    // The inbox page announces count changes so the badge updates immediately.
+    const onUnread = (e: Event) => setUnreadCount((e as CustomEvent<number>).detail);
+
+    refresh();
+    const timer = window.setInterval(refresh, UNREAD_POLL_MS);
+    window.addEventListener('notifications:unread', onUnread);
+    return () => {
+      window.clearInterval(timer);
+      window.removeEventListener('notifications:unread', onUnread);
+    };
+  }, [user]);
+
   return (
     <header className="bg-white border-b border-gray-200 sticky top-0 z-50">
       <div className="px-6 py-4 flex items-center justify-between">
@@ -23,6 +47,22 @@ export default function Navbar() {
         <div className="flex items-center gap-4">
           {user ? (
             <>
+              {/* Inbox */}
+              <Link
+                to="/notifications/inbox"
+                className="relative p-2 text-gray-600 hover:text-blue-600 hover:bg-gray-100 rounded-lg transition-colors"
+                title="Inbox"
+              >
+                <svg className="w-6 h-6" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M15 17h5l-1.405-1.405A2.032 2.032 0 0118 14.158V11a6.002 6.002 0 00-4-5.659V5a2 2 0 10-4 0v.341C7.67 6.165 6 8.388 6 11v3.159c0 .538-.214 1.055-.595 1.436L4 17h5m6 0v1a3 3 0 11-6 0v-1m6 0H9" />
+                </svg>
+                {unreadCount > 0 && (
+                  <span className="absolute -top-0.5 -right-0.5 min-w-[1.25rem] h-5 px-1 bg-red-600 text-white text-xs font-semibold rounded-full flex items-center justify-center">
+                    {unreadCount > 99 ? '99+' : unreadCount}
+                  </span>
+                )}
+              </Link>
+
               {/* User Info */}
               <div className="flex items-center gap-3 px-3 py-2 bg-gray-50 rounded-lg">
                 <div className="w-8 h-8 bg-blue-100 rounded-full flex items-center justify-center">
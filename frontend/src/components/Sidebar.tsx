@@ -13,6 +13,7 @@ function NavItem({ to, label, icon, collapsed }: NavItemProps) {
   return (
     <NavLink
       to={to}
+      end
       className={({ isActive }) =>
         `flex items-center ${collapsed ? 'justify-center' : 'gap-3'} px-3 py-2.5 rounded-lg transition-all duration-200 group relative ${
           isActive
@@ -202,6 +203,16 @@ export default function Sidebar() {
             Notifications
           </SectionHeader>
           <div className="space-y-1">
+            <NavItem
+              to="/notifications/inbox"
+              label="Inbox"
+              collapsed={collapsed}
+              icon={
+                <svg fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M20 13V6a2 2 0 00-2-2H6a2 2 0 00-2 2v7m16 0v5a2 2 0 01-2 2H6a2 2 0 01-2-2v-5m16 0h-2.586a1 1 0 00-.707.293l-2.414 2.414a1 1 0 01-.707.293h-3.172a1 1 0 01-.707-.293l-2.414-2.414A1 1 0 006.586 13H4" />
+                </svg>
+              }
+            />
             <NavItem
               to="/notifications"
               label="History"
@@ -0,0 +1,252 @@
+import { useEffect, useState } from 'react';
+import * as notifications from '@/services/notifications';
+import { useAuth } from '@/hooks/useAuth';
+
+interface InboxItem {
+  id: string;
+  type: string;
+  message: string;
+  created_at: string;
+  read_at: string | null;
+  archived_at: string | null;
+  metadata?: Record<string, any>;
+}
+
+const filters: { value: notifications.InboxFilter; label: string }[] = [
+  { value: 'all', label: 'All' },
+  { value: 'unread', label: 'Unread' },
+  { value: 'archived', label: 'Archived' },
+];
+
+// Lets the navbar badge follow changes made here without refetching.
+function announceUnread(count: number) {
+  window.dispatchEvent(new CustomEvent('notifications:unread', { detail: count }));
+}
+
+export default function Inbox() {
+  const [items, setItems] = useState<InboxItem[]>([]);
+  const [filter, setFilter] = useState<notifications.InboxFilter>('all');
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [unreadCount, setUnreadCount] = useState(0);
+  const [isLoading, setIsLoading] = useState(true);
+  const [errorMessage, setErrorMessage] = useState<string | null>(null);
+  const { user } = useAuth();
+
+  useEffect(() => {
+    setItems([]);
+    setNextCursor(null);
+    loadPage();
+  }, [user, filter]);
+
+  async function loadPage(cursor?: string) {
+    if (!user) {
+      setIsLoading(false);
+      return;
+    }
+
+    try {
+      setIsLoading(true);
+      setErrorMessage(null);
+      const data = await notifications.getInbox(filter, cursor);
+      const page: InboxItem[] = data.items || [];
+
+      setItems(prev => (cursor ? [...prev, ...page] : page));
+      setNextCursor(data.next_cursor || null);
+      setUnreadCount(data.unread_count ?? 0);
+      announceUnread(data.unread_count ?? 0);
+    } catch (error: any) {
+      console.error('Failed to load inbox:', error);
+      setErrorMessage(error.response?.data?.error || 'Failed to load inbox');
+    } finally {
+      setIsLoading(false);
+    }
+  }
+
+  async function applyAction(item: InboxItem, action: 'read' | 'unread' | 'archive') {
+    try {
+      setErrorMessage(null);
+      let count: number;
+      if (action === 'read') {
+        count = await notifications.markNotificationRead(item.id);
+      } else if (action === 'unread') {
+        count = await notifications.markNotificationUnread(item.id);
+      } else {
+        count = await notifications.archiveNotification(item.id);
+      }
+
+      const now = new Date().toISOString();
+      setItems(prev =>
+        prev
+          .map(i => {
+            if (i.id !== item.id) return i;
+            if (action === 'read') return { ...i, read_at: now };
+            if (action === 'unread') return { ...i, read_at: null };
+            return { ...i, archived_at: now };
+          })
+          // Drop items that no longer match the current filter.
+          .filter(i =>
+            filter === 'archived' ? true :
+            filter === 'unread' ? !i.read_at && !i.archived_at :
+            !i.archived_at
+          )
+      );
+      setUnreadCount(count);
+      announceUnread(count);
+    } catch (error: any) {
+      console.error('Failed to update notification:', error);
+      setErrorMessage(error.response?.data?.error || 'Failed to update notification');
+    }
+  }
+
+  async function markAllRead() {
+    try {
+      setErrorMessage(null);
+      await notifications.markAllNotificationsRead();
+      const now = new Date().toISOString();
+      setItems(prev =>
+        filter === 'unread' ? [] : prev.map(i => (i.read_at || i.archived_at ? i : { ...i, read_at: now }))
+      );
+      setUnreadCount(0);
+      announceUnread(0);
+    } catch (error: any) {
+      console.error('Failed to mark all as read:', error);
+      setErrorMessage(error.response?.data?.error || 'Failed to mark all as read');
+    }
+  }
+
+  function formatDate(dateStr: string) {
+    const date = new Date(dateStr);
+    if (isNaN(date.getTime())) return dateStr;
+    return date.toLocaleString('en-US', {
+      month: 'short',
+      day: 'numeric',
+      hour: '2-digit',
+      minute: '2-digit',
+    });
+  }
+
+  function getNotificationTitle(type: string) {
+    return type
+      .split(/[_.]/)
+      .map(word => word.charAt(0).toUpperCase() + word.slice(1))
+      .join(' ');
+  }
+
+  if (!user) {
+    return (
+      <div className="page">
+        <div className="card text-center py-8">
+          <p className="text-gray-600">Please log in to view your inbox.</p>
+        </div>
+      </div>
+    );
+  }
+
+  return (
+    <div className="page">
+      <div className="max-w-4xl mx-auto">
+        <div className="flex items-center justify-between mb-6">
+          <div>
+            <h1 className="page-title mb-1">Inbox</h1>
+            <p className="text-gray-600">
+              {unreadCount > 0 ? `${unreadCount} unread notification${unreadCount > 1 ? 's' : ''}` : 'You are all caught up'}
+            </p>
+          </div>
+          <button
+            onClick={markAllRead}
+            className="btn-secondary"
+            disabled={unreadCount === 0 || filter === 'archived'}
+          >
+            <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+              <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M5 13l4 4L19 7" />
+            </svg>
+            <span>Mark all as read</span>
+          </button>
+        </div>
+
+        <div className="flex gap-2 mb-4">
+          {filters.map(f => (
+            <button
+              key={f.value}
+              onClick={() => setFilter(f.value)}
+              className={`px-4 py-2 text-sm font-medium rounded-lg transition-colors ${
+                filter === f.value
+                  ? 'bg-blue-600 text-white'
+                  : 'bg-white text-gray-700 border border-gray-200 hover:bg-gray-50'
+              }`}
+            >
+              {f.label}
+            </button>
+          ))}
+        </div>
+
+        {errorMessage && (
+          <div className="alert-error mb-4">
+            <span>{errorMessage}</span>
+          </div>
+        )}
+
+        <div className="space-y-3">
+          {items.length === 0 && !isLoading ? (
+            <div className="card text-center py-12">
+              <svg className="w-16 h-16 mx-auto text-gray-400 mb-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M20 13V6a2 2 0 00-2-2H6a2 2 0 00-2 2v7m16 0v5a2 2 0 01-2 2H6a2 2 0 01-2-2v-5m16 0h-2.586a1 1 0 00-.707.293l-2.414 2.414a1 1 0 01-.707.293h-3.172a1 1 0 01-.707-.293l-2.414-2.414A1 1 0 006.586 13H4" />
+              </svg>
+              <h3 className="text-lg font-medium text-gray-900 mb-2">Nothing here</h3>
+              <p className="text-gray-600">In-app notifications will appear here.</p>
+            </div>
+          ) : (
+            items.map(item => (
+              <div
+                key={item.id}
+                className={`card transition-shadow hover:shadow-md ${!item.read_at && !item.archived_at ? 'border-l-4 border-blue-500' : ''}`}
+              >
+                <div className="flex items-start justify-between gap-4">
+                  <div className="flex-1 min-w-0">
+                    <h3 className={`text-gray-900 ${item.read_at ? 'font-medium' : 'font-semibold'}`}>
+                      {getNotificationTitle(item.type)}
+                    </h3>
+                    <p className="text-sm text-gray-600 mt-1">{item.message}</p>
+                    <p className="text-xs text-gray-500 mt-2">{formatDate(item.created_at)}</p>
+                  </div>
+
+                  {!item.archived_at && (
+                    <div className="flex-shrink-0 flex items-center gap-2">
+                      {item.read_at ? (
+                        <button onClick={() => applyAction(item, 'unread')} className="text-xs text-blue-600 hover:text-blue-700">
+                          Mark unread
+                        </button>
+                      ) : (
+                        <button onClick={() => applyAction(item, 'read')} className="text-xs text-blue-600 hover:text-blue-700">
+                          Mark read
+                        </button>
+                      )}
+                      <button onClick={() => applyAction(item, 'archive')} className="text-xs text-gray-500 hover:text-gray-700">
+                        Archive
+                      </button>
+                    </div>
+                  )}
+                </div>
+              </div>
+            ))
+          )}
+        </div>
+
+        {isLoading && (
+          <div className="flex items-center justify-center py-6">
+            <div className="spinner"></div>
+            <span className="ml-3 text-gray-600">Loading inbox...</span>
+          </div>
+        )}
+
+        {nextCursor && !isLoading && (
+          <div className="flex justify-center mt-6 pt-4 border-t">
+            <button onClick={() => loadPage(nextCursor)} className="btn-secondary">
+              <span>Load more</span>
+            </button>
+          </div>
+        )}
+      </div>
+    </div>
+  );
+}
@@ -100,6 +100,8 @@ export default function NotificationHistory() {
       return <span className="badge-info">📧 Email</span>;
     } else if (channelLower === 'push') {
       return <span className="badge-warning">📱 Push</span>;
+    } else if (channelLower === 'in_app') {
+      return <span className="badge-default">🔔 In-App</span>;
     } else if (channelLower === 'sms') {
       return <span className="badge-success">💬 SMS</span>;
     }
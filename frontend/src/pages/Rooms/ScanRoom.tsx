@@ -0,0 +1,62 @@
+import { useEffect, useRef, useState } from 'react';
+import { Link, useParams, useSearchParams } from 'react-router-dom';
+import * as rooms from '@/services/rooms';
+
+type ScanResult = Awaited<ReturnType<typeof rooms.scanRoomCode>>;
+
+// Landing page of the QR code on a room's door: checks the user in to their
+// booking of the room, or books the free room for them from now.
+export default function ScanRoom() {
+  const { id } = useParams();
+  const [searchParams] = useSearchParams();
+  const sig = searchParams.get('sig') || '';
+  const [result, setResult] = useState<ScanResult | null>(null);
+  const [error, setError] = useState('');
+  const scanned = useRef(false);
+
+  useEffect(() => {
+    if (!id || scanned.current) return;
+    scanned.current = true;
+    (async () => {
+      try {
+        setResult(await rooms.scanRoomCode({ room_id: id, sig }));
+      } catch (err: any) {
+        setError(err?.response?.data?.error || 'Could not use this room code.');
+      }
+    })();
+  }, [id, sig]);
+
+  const formatTime = (isoString: string) =>
+    new Date(isoString).toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit', hour12: false });
+
+  return (
+    <div className="page">
+      <h1 className="page-title">Room Check-in</h1>
+
+      <div className="card">
+        {!result && !error && <div className="text-center text-gray-600 py-8">Checking the room...</div>}
+
+        {error && <div className="text-center text-red-600 py-8">{error}</div>}
+
+        {result && (
+          <div className="text-center py-8 space-y-2">
+            <div className="text-lg font-semibold text-gray-800">
+              {result.action === 'checked_in' ? 'You are checked in.' : 'The room is requested for you and awaits approval.'}
+            </div>
+            <div className="text-gray-600">
+              {formatTime(result.booking.start_time)} - {formatTime(result.booking.end_time)}
+            </div>
+          </div>
+        )}
+
+        {id && (
+          <div className="text-center">
+            <Link to={`/rooms/${id}/schedule`} className="text-sm text-blue-600 hover:underline">
+              View room schedule
+            </Link>
+          </div>
+        )}
+      </div>
+    </div>
+  );
+}
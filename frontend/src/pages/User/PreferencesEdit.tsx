@@ -223,6 +223,33 @@ export default function PreferencesEdit() {
                   />
                 </div>
               </div>
+
+              <div
+                onClick={() => toggleChannel('in_app')}
+                className={`p-4 border-2 rounded-lg cursor-pointer transition-all ${
+                  enabledChannels.includes('in_app')
+                    ? 'border-blue-500 bg-blue-50'
+                    : 'border-gray-200 bg-white hover:border-gray-300'
+                }`}
+              >
+                <div className="flex items-center justify-between">
+                  <div className="flex items-center gap-3">
+                    <svg className="w-6 h-6 text-blue-600" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                      <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M20 13V6a2 2 0 00-2-2H6a2 2 0 00-2 2v7m16 0v5a2 2 0 01-2 2H6a2 2 0 01-2-2v-5m16 0h-2.586a1 1 0 00-.707.293l-2.414 2.414a1 1 0 01-.707.293h-3.172a1 1 0 01-.707-.293l-2.414-2.414A1 1 0 006.586 13H4" />
+                    </svg>
+                    <div>
+                      <div className="font-medium">In-App Inbox</div>
+                      <div className="text-xs text-gray-500">Keep notifications in your CPRoom inbox</div>
+                    </div>
+                  </div>
+                  <input
+                    type="checkbox"
+                    checked={enabledChannels.includes('in_app')}
+                    onChange={() => {}}
+                    className="w-5 h-5"
+                  />
+                </div>
+              </div>
             </div>
           </div>
 
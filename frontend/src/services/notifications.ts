@@ -8,9 +8,40 @@ export async function getNotificationHistory(page: number = 1, pageSize: number
   return data;
 }
 
-// Mark notification as read (if needed in the future)
-export async function markNotificationRead(notificationId: string) {
-  const { data } = await api.put(`/notifications/${notificationId}/read`);
+export type InboxFilter = 'all' | 'unread' | 'archived';
+
+// Get a page of the in-app inbox; pass the previous page's next_cursor to continue
+export async function getInbox(filter: InboxFilter = 'all', cursor?: string, limit: number = 20) {
+  const { data } = await api.get('/notifications/inbox', {
+    params: { filter, cursor, limit }
+  });
+  return data;
+}
+
+// Get the unread badge count
+export async function getUnreadCount(): Promise<number> {
+  const { data } = await api.get('/notifications/inbox/unread-count');
+  return data.unread_count ?? 0;
+}
+
+// The per-item actions resolve to the new unread count
+export async function markNotificationRead(notificationId: string): Promise<number> {
+  const { data } = await api.post(`/notifications/inbox/${notificationId}/read`);
+  return data.unread_count ?? 0;
+}
+
+export async function markNotificationUnread(notificationId: string): Promise<number> {
+  const { data } = await api.post(`/notifications/inbox/${notificationId}/unread`);
+  return data.unread_count ?? 0;
+}
+
+export async function archiveNotification(notificationId: string): Promise<number> {
+  const { data } = await api.post(`/notifications/inbox/${notificationId}/archive`);
+  return data.unread_count ?? 0;
+}
+
+export async function markAllNotificationsRead() {
+  const { data } = await api.post('/notifications/inbox/read-all');
   return data;
 }
 
@@ -58,3 +58,11 @@ export async function updateRoom(id: string, payload: { name?: string; capacity?
   return data;
 }
 
+
+export async function scanRoomCode(payload: { room_id: string; sig: string; duration_minutes?: number; title?: string }) {
+  const { data } = await api.post('/bookings/scan', payload);
+  return data as {
+    action: 'checked_in' | 'requested';
+    booking: { id: string; start_time: string; end_time: string; title?: string; checked_in_at?: string };
+  };
+}